@@ -0,0 +1,149 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+
+	abci "github.com/cometbft/cometbft/abci/types"
+	cmttypes "github.com/cometbft/cometbft/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+var (
+	// ErrEnvoyDataMissing is returned when envoy data expected in a proposal is not there.
+	ErrEnvoyDataMissing = errors.New("envoy data missing from proposal")
+	// ErrEnvoyDataDuplicated is returned when envoy data is included more than once.
+	ErrEnvoyDataDuplicated = errors.New("envoy data duplicated in proposal")
+	// ErrEnvoyDataMalformed is returned when the envoy data is neither the expected data nor a tx.
+	ErrEnvoyDataMalformed = errors.New("malformed envoy data in proposal")
+	// ErrEnvoyDataInconsistent is returned when the proposal is not what envoy would have prepared.
+	ErrEnvoyDataInconsistent = errors.New("proposal inconsistent with envoy state")
+)
+
+// LastCommitVerifier returns the verified extended commit the PrepareProposal
+// handlers were given for a proposal.
+type LastCommitVerifier interface {
+	LastCommit(ctx sdk.Context, req *abci.RequestProcessProposal) (abci.ExtendedCommitInfo, error)
+}
+
+// EnvoyProposalInjector is the ProposalInjector of the envoy tracker. It is a
+// prepended injection, verified by re-deriving the expected envoy data from
+// local state, which the tracker derives to the same bytes on every node.
+type EnvoyProposalInjector struct {
+	prepare   sdk.PrepareProposalHandler
+	txDecoder sdk.TxDecoder
+	commits   LastCommitVerifier
+}
+
+var _ ProposalInjector = (*EnvoyProposalInjector)(nil)
+
+// NewEnvoyProposalInjector returns the injector of the data added by the
+// envoy tracker PrepareProposal handler to an empty proposal. The data is
+// verified against the last commit returned by commits.
+func NewEnvoyProposalInjector(
+	prepare sdk.PrepareProposalHandler,
+	txDecoder sdk.TxDecoder,
+	commits LastCommitVerifier,
+) *EnvoyProposalInjector {
+	return &EnvoyProposalInjector{
+		prepare:   prepare,
+		txDecoder: txDecoder,
+		commits:   commits,
+	}
+}
+
+// PrepareInjection implements ProposalInjector.
+func (e *EnvoyProposalInjector) PrepareInjection(ctx sdk.Context, req *abci.RequestPrepareProposal) ([][]byte, error) {
+	resp, err := e.prepare(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Txs, nil
+}
+
+// ProcessInjection implements ProposalInjector. Each expected item must be in
+// the proposal exactly once, in the order prepare returns them, at the head of
+// items. They are re-derived with the budget and the last commit the proposer
+// prepared them with.
+func (e *EnvoyProposalInjector) ProcessInjection(
+	ctx sdk.Context,
+	req *abci.RequestProcessProposal,
+	items [][]byte,
+	maxBytes int64,
+) (int, error) {
+	commit, err := e.commits.LastCommit(ctx, req)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrEnvoyDataInconsistent, err)
+	}
+
+	expected, err := e.PrepareInjection(ctx, &abci.RequestPrepareProposal{
+		MaxTxBytes:         maxBytes,
+		LocalLastCommit:    commit,
+		Misbehavior:        req.Misbehavior,
+		Height:             req.Height,
+		Time:               req.Time,
+		NextValidatorsHash: req.NextValidatorsHash,
+		ProposerAddress:    req.ProposerAddress,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrEnvoyDataInconsistent, err)
+	}
+
+	counts := make(map[string]int, len(expected))
+	for _, item := range expected {
+		counts[string(item)] = 0
+	}
+
+	for _, item := range req.Txs {
+		if seen, ok := counts[string(item)]; ok {
+			if seen > 0 {
+				return 0, fmt.Errorf("%w: item %X", ErrEnvoyDataDuplicated, cmttypes.Tx(item).Hash())
+			}
+			counts[string(item)] = seen + 1
+		}
+	}
+
+	// whatever sits where the envoy data belongs must at least be a tx
+	for i := 0; i < len(expected) && i < len(items); i++ {
+		if _, ok := counts[string(items[i])]; ok {
+			continue
+		}
+
+		if _, err := e.txDecoder(items[i]); err != nil {
+			return 0, fmt.Errorf("%w: item %X: %w", ErrEnvoyDataMalformed, cmttypes.Tx(items[i]).Hash(), err)
+		}
+	}
+
+	for _, item := range expected {
+		if counts[string(item)] == 0 {
+			return 0, fmt.Errorf("%w: item %X", ErrEnvoyDataMissing, cmttypes.Tx(item).Hash())
+		}
+	}
+
+	for i, item := range expected {
+		if i >= len(items) || string(items[i]) != string(item) {
+			return 0, fmt.Errorf("%w: item %X out of place", ErrEnvoyDataInconsistent, cmttypes.Tx(item).Hash())
+		}
+	}
+
+	return len(expected), nil
+}
+
+// extendedCommitInfo converts the commit info of a proposal to the extended
+// commit info seen by PrepareProposal, without the vote extensions.
+func extendedCommitInfo(commit abci.CommitInfo) abci.ExtendedCommitInfo {
+	votes := make([]abci.ExtendedVoteInfo, len(commit.Votes))
+	for i, vote := range commit.Votes {
+		votes[i] = abci.ExtendedVoteInfo{
+			Validator:   vote.Validator,
+			BlockIdFlag: vote.BlockIdFlag,
+		}
+	}
+
+	return abci.ExtendedCommitInfo{
+		Round: commit.Round,
+		Votes: votes,
+	}
+}
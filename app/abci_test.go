@@ -0,0 +1,223 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	abci "github.com/cometbft/cometbft/abci/types"
+	cmtproto "github.com/cometbft/cometbft/proto/tendermint/types"
+	cmttypes "github.com/cometbft/cometbft/types"
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/log"
+	"cosmossdk.io/math"
+
+	"github.com/cosmos/cosmos-sdk/testutil/mock"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+
+	"github.com/polygon/envoy"
+)
+
+var errNotATx = errors.New("not a tx")
+
+// envoyItem is the data the fake envoy prepare handler injects at a height.
+func envoyItem(height int64) []byte {
+	return []byte(fmt.Sprintf("envoy:%d", height))
+}
+
+// prepareEnvoy prepends the envoy item to the txs, like the tracker does.
+func prepareEnvoy(_ sdk.Context, req *abci.RequestPrepareProposal) (*abci.ResponsePrepareProposal, error) {
+	return &abci.ResponsePrepareProposal{Txs: append([][]byte{envoyItem(req.Height)}, req.Txs...)}, nil
+}
+
+// decodeTx accepts anything prefixed with "tx".
+func decodeTx(bz []byte) (sdk.Tx, error) {
+	if !bytes.HasPrefix(bz, []byte("tx")) {
+		return nil, errNotATx
+	}
+	return nil, nil
+}
+
+// fixedCommit is the last commit of every proposal, or fails with err.
+type fixedCommit struct {
+	commit abci.ExtendedCommitInfo
+	err    error
+}
+
+func (f fixedCommit) LastCommit(sdk.Context, *abci.RequestProcessProposal) (abci.ExtendedCommitInfo, error) {
+	return f.commit, f.err
+}
+
+func TestEnvoyProposalInjector(t *testing.T) {
+	ctx := sdk.Context{}.WithLogger(log.NewNopLogger())
+
+	tx1, tx2 := []byte("tx1"), []byte("tx2")
+	failingPrepare := func(sdk.Context, *abci.RequestPrepareProposal) (*abci.ResponsePrepareProposal, error) {
+		return nil, errors.New("no state")
+	}
+
+	testCases := []struct {
+		name    string
+		prepare sdk.PrepareProposalHandler
+		txs     [][]byte
+		expErr  error
+	}{
+		{
+			name: "valid",
+			txs:  [][]byte{envoyItem(5), tx1, tx2},
+		},
+		{
+			name: "valid without txs",
+			txs:  [][]byte{envoyItem(5)},
+		},
+		{
+			name:   "missing",
+			txs:    [][]byte{tx1, tx2},
+			expErr: ErrEnvoyDataMissing,
+		},
+		{
+			name:   "duplicated",
+			txs:    [][]byte{envoyItem(5), envoyItem(5), tx1},
+			expErr: ErrEnvoyDataDuplicated,
+		},
+		{
+			name:   "duplicated among txs",
+			txs:    [][]byte{envoyItem(5), tx1, envoyItem(5)},
+			expErr: ErrEnvoyDataDuplicated,
+		},
+		{
+			name:   "malformed",
+			txs:    [][]byte{[]byte("garbage"), tx1},
+			expErr: ErrEnvoyDataMalformed,
+		},
+		{
+			name:   "stale",
+			txs:    [][]byte{envoyItem(4), tx1},
+			expErr: ErrEnvoyDataMalformed,
+		},
+		{
+			name:   "out of place",
+			txs:    [][]byte{tx1, envoyItem(5), tx2},
+			expErr: ErrEnvoyDataInconsistent,
+		},
+		{
+			name:    "prepare fails",
+			prepare: failingPrepare,
+			txs:     [][]byte{envoyItem(5), tx1},
+			expErr:  ErrEnvoyDataInconsistent,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prepare := tc.prepare
+			if prepare == nil {
+				prepare = prepareEnvoy
+			}
+
+			injector := NewEnvoyProposalInjector(prepare, decodeTx, fixedCommit{})
+			req := &abci.RequestProcessProposal{Height: 5, Txs: tc.txs}
+			n, err := injector.ProcessInjection(ctx, req, tc.txs, EnvoyMaxBytes)
+			if tc.expErr != nil {
+				require.ErrorIs(t, err, tc.expErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, 1, n)
+		})
+	}
+}
+
+func TestEnvoyProposalInjectorPrepareRequest(t *testing.T) {
+	ctx := sdk.Context{}.WithLogger(log.NewNopLogger())
+	commit := abci.ExtendedCommitInfo{Round: 2, Votes: []abci.ExtendedVoteInfo{{VoteExtension: []byte("attestation")}}}
+
+	var prepareReq *abci.RequestPrepareProposal
+	prepare := func(ctx sdk.Context, req *abci.RequestPrepareProposal) (*abci.ResponsePrepareProposal, error) {
+		prepareReq = req
+		return prepareEnvoy(ctx, req)
+	}
+
+	// the data is re-derived with the budget and the verified last commit
+	injector := NewEnvoyProposalInjector(prepare, decodeTx, fixedCommit{commit: commit})
+	txs := [][]byte{envoyItem(5)}
+	_, err := injector.ProcessInjection(ctx, &abci.RequestProcessProposal{Height: 5, Txs: txs}, txs, 42)
+	require.NoError(t, err)
+	require.Equal(t, int64(42), prepareReq.MaxTxBytes)
+	require.Equal(t, commit, prepareReq.LocalLastCommit)
+
+	// nor without a verified last commit
+	injector = NewEnvoyProposalInjector(prepare, decodeTx, fixedCommit{err: errors.New("forged commit")})
+	_, err = injector.ProcessInjection(ctx, &abci.RequestProcessProposal{Height: 5, Txs: txs}, txs, 42)
+	require.ErrorIs(t, err, ErrEnvoyDataInconsistent)
+}
+
+func TestEnvoyProposalInjectorAcrossNodes(t *testing.T) {
+	// two nodes of the same chain, each with the tracker of its own app
+	pubKey, err := mock.NewPV().GetPubKey()
+	require.NoError(t, err)
+	valSet := cmttypes.NewValidatorSet([]*cmttypes.Validator{cmttypes.NewValidator(pubKey, 1)})
+
+	acc := authtypes.NewBaseAccount(sdk.AccAddress("delegator___________"), nil, 0, 0)
+	balance := banktypes.Balance{
+		Address: acc.GetAddress().String(),
+		Coins:   sdk.NewCoins(sdk.NewCoin(sdk.DefaultBondDenom, math.NewInt(100000000000000))),
+	}
+
+	nodes := []*MiniApp{
+		SetupWithGenesisValSet(t, valSet, []authtypes.GenesisAccount{acc}, balance),
+		SetupWithGenesisValSet(t, valSet, []authtypes.GenesisAccount{acc}, balance),
+	}
+	for _, app := range nodes {
+		ctx := app.NewUncachedContext(false, cmtproto.Header{Height: app.LastBlockHeight() + 1})
+		for i := 0; i < 20; i++ {
+			lock := envoy.Lock{
+				Name:      fmt.Sprintf("lock%d", i),
+				Envoy:     sdk.AccAddress(fmt.Sprintf("holder%014d", i)).String(),
+				AtBlock:   uint64(i),
+				NumBlocks: 100,
+			}
+			require.NoError(t, app.EnvoyKeeper.Locks.Set(ctx, lock.Name, lock))
+		}
+		NextBlock(t, app)
+	}
+	require.Equal(t, nodes[0].LastCommitID(), nodes[1].LastCommitID())
+
+	commit := abci.ExtendedCommitInfo{Votes: []abci.ExtendedVoteInfo{{
+		Validator:   abci.Validator{Address: pubKey.Address(), Power: 1},
+		BlockIdFlag: cmtproto.BlockIDFlagCommit,
+	}}}
+	req := &abci.RequestPrepareProposal{
+		MaxTxBytes:         EnvoyMaxBytes,
+		LocalLastCommit:    commit,
+		Height:             nodes[0].LastBlockHeight() + 1,
+		NextValidatorsHash: valSet.Hash(),
+		ProposerAddress:    pubKey.Address(),
+	}
+
+	// what one node injects is, byte for byte, what the other re-derives, as
+	// many times as it is built
+	for i := 0; i < 10; i++ {
+		var injected [][][]byte
+		for _, app := range nodes {
+			ctx := app.NewContextLegacy(false, cmtproto.Header{Height: req.Height})
+			injector := NewEnvoyProposalInjector(app.EnvoyTracker.PrepareProposal, app.TxConfig().TxDecoder(), fixedCommit{commit: commit})
+			items, err := injector.PrepareInjection(ctx, req)
+			require.NoError(t, err)
+			injected = append(injected, items)
+		}
+		require.Equal(t, injected[0], injected[1])
+
+		ctx := nodes[1].NewContextLegacy(false, cmtproto.Header{Height: req.Height})
+		injector := NewEnvoyProposalInjector(nodes[1].EnvoyTracker.PrepareProposal, nodes[1].TxConfig().TxDecoder(), fixedCommit{commit: commit})
+		processReq := &abci.RequestProcessProposal{Txs: injected[0], Height: req.Height, NextValidatorsHash: req.NextValidatorsHash, ProposerAddress: req.ProposerAddress}
+		n, err := injector.ProcessInjection(ctx, processReq, injected[0], EnvoyMaxBytes)
+		require.NoError(t, err)
+		require.Equal(t, len(injected[0]), n)
+	}
+}
@@ -1,18 +1,27 @@
 package app
 
 import (
+	"bytes"
 	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 
 	dbm "github.com/cosmos/cosmos-db"
 	stakingkeeper "github.com/cosmos/cosmos-sdk/x/staking/keeper"
+	"sigs.k8s.io/yaml"
 
 	"cosmossdk.io/core/appconfig"
 	"cosmossdk.io/depinject"
 	"cosmossdk.io/log"
 	storetypes "cosmossdk.io/store/types"
+	_ "cosmossdk.io/x/evidence" // import for side-effects
+	evidencekeeper "cosmossdk.io/x/evidence/keeper"
+	_ "cosmossdk.io/x/upgrade" // import for side-effects
+	upgradekeeper "cosmossdk.io/x/upgrade/keeper"
 
 	_ "cosmossdk.io/api/cosmos/tx/config/v1" // import for side-effects
 	"github.com/cosmos/cosmos-sdk/baseapp"
@@ -25,10 +34,11 @@ import (
 	"github.com/cosmos/cosmos-sdk/server/config"
 	servertypes "github.com/cosmos/cosmos-sdk/server/types"
 	"github.com/cosmos/cosmos-sdk/types/module"
-	_ "github.com/cosmos/cosmos-sdk/x/auth" // import for side-effects
+	"github.com/cosmos/cosmos-sdk/x/auth"
 	authkeeper "github.com/cosmos/cosmos-sdk/x/auth/keeper"
 	_ "github.com/cosmos/cosmos-sdk/x/auth/tx/config" // import for side-effects
-	_ "github.com/cosmos/cosmos-sdk/x/bank"           // import for side-effects
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	_ "github.com/cosmos/cosmos-sdk/x/bank" // import for side-effects
 	bankkeeper "github.com/cosmos/cosmos-sdk/x/bank/keeper"
 	_ "github.com/cosmos/cosmos-sdk/x/consensus" // import for side-effects
 	consensuskeeper "github.com/cosmos/cosmos-sdk/x/consensus/keeper"
@@ -36,16 +46,34 @@ import (
 	distrkeeper "github.com/cosmos/cosmos-sdk/x/distribution/keeper"
 	"github.com/cosmos/cosmos-sdk/x/genutil"
 	genutiltypes "github.com/cosmos/cosmos-sdk/x/genutil/types"
-	_ "github.com/cosmos/cosmos-sdk/x/mint"    // import for side-effects
+	_ "github.com/cosmos/cosmos-sdk/x/gov" // import for side-effects
+	govkeeper "github.com/cosmos/cosmos-sdk/x/gov/keeper"
+	govtypes "github.com/cosmos/cosmos-sdk/x/gov/types"
+	_ "github.com/cosmos/cosmos-sdk/x/mint" // import for side-effects
+	mintkeeper "github.com/cosmos/cosmos-sdk/x/mint/keeper"
+	_ "github.com/cosmos/cosmos-sdk/x/slashing" // import for side-effects
+	slashingkeeper "github.com/cosmos/cosmos-sdk/x/slashing/keeper"
 	_ "github.com/cosmos/cosmos-sdk/x/staking" // import for side-effects
 
+	"github.com/polygon/envoy"
 	envoykeeper "github.com/polygon/envoy/keeper"
 	envoymodule "github.com/polygon/envoy/module"
+
+	"github.com/polygon/procyon/app/envoysim"
+	"github.com/polygon/procyon/app/leases"
+	"github.com/polygon/procyon/app/params"
 )
 
 // DefaultNodeHome default home directories for the application daemon
 var DefaultNodeHome string
 
+const (
+	// VoteExtensionsMaxBytes is the part of a block reserved for the extended commit.
+	VoteExtensionsMaxBytes = 1 << 20
+	// EnvoyMaxBytes is the part of a block reserved for the envoy data.
+	EnvoyMaxBytes = 64 << 10
+)
+
 //go:embed app.yaml
 var AppConfigYAML []byte
 
@@ -66,13 +94,25 @@ type MiniApp struct {
 
 	// keepers
 	AccountKeeper         authkeeper.AccountKeeper
-	BankKeeper            bankkeeper.Keeper
+	BankKeeper            bankkeeper.BaseKeeper
 	StakingKeeper         *stakingkeeper.Keeper
 	DistrKeeper           distrkeeper.Keeper
+	MintKeeper            mintkeeper.Keeper
+	SlashingKeeper        slashingkeeper.Keeper
+	EvidenceKeeper        evidencekeeper.Keeper
+	GovKeeper             *govkeeper.Keeper
+	UpgradeKeeper         *upgradekeeper.Keeper
 	ConsensusParamsKeeper consensuskeeper.Keeper
 
 	EnvoyKeeper  envoykeeper.Keeper
 	EnvoyTracker *envoymodule.Tracker
+	LeasesKeeper leases.Keeper
+
+	// leasesModule is registered by the app, not by the app config
+	leasesModule leases.AppModule
+
+	// Executors run the jobs of the lockable actions on the local node.
+	Executors *ActionExecutors
 
 	// simulation manager
 	sm *module.SimulationManager
@@ -89,8 +129,18 @@ func init() {
 
 // AppConfig returns the default app config.
 func AppConfig() depinject.Config {
+	appConfigJSON, err := withBech32Prefix(AppConfigYAML, params.Bech32PrefixAccAddr)
+	if err != nil {
+		return depinject.Error(err)
+	}
+
+	appConfigJSON, _, err = withoutLockableActions(appConfigJSON)
+	if err != nil {
+		return depinject.Error(err)
+	}
+
 	return depinject.Configs(
-		appconfig.LoadYAML(AppConfigYAML),
+		appconfig.LoadJSON(appConfigJSON),
 		depinject.Supply(
 			// supply custom module basics
 			map[string]module.AppModuleBasic{
@@ -100,6 +150,97 @@ func AppConfig() depinject.Config {
 	)
 }
 
+// withBech32Prefix returns the app config YAML as JSON, with the bech32 prefix
+// of the auth module, from which the validator and consensus prefixes derive,
+// set to the one of the chain identity.
+func withBech32Prefix(appConfigYAML []byte, prefix string) ([]byte, error) {
+	bz, err := yaml.YAMLToJSON(appConfigYAML)
+	if err != nil {
+		return nil, err
+	}
+
+	var appConfig map[string]any
+	if err := json.Unmarshal(bz, &appConfig); err != nil {
+		return nil, err
+	}
+
+	modules, _ := appConfig["modules"].([]any)
+	for _, m := range modules {
+		mod, _ := m.(map[string]any)
+		if mod["name"] != authtypes.ModuleName {
+			continue
+		}
+
+		modConfig, ok := mod["config"].(map[string]any)
+		if !ok {
+			return nil, errors.New("auth module without config")
+		}
+		modConfig["bech32_prefix"] = prefix
+
+		return json.Marshal(appConfig)
+	}
+
+	return nil, errors.New("no auth module in the app config")
+}
+
+// LockableActions returns the lockable actions declared in the envoy module
+// config of app.yaml.
+func LockableActions() ([]leases.Action, error) {
+	bz, err := yaml.YAMLToJSON(AppConfigYAML)
+	if err != nil {
+		return nil, err
+	}
+
+	_, actions, err := withoutLockableActions(bz)
+	return actions, err
+}
+
+// withoutLockableActions returns the app config JSON without the lockable
+// actions of the envoy module config, and the actions. They are leased by the
+// leases module, and the envoy module config doesn't know them.
+func withoutLockableActions(appConfigJSON []byte) ([]byte, []leases.Action, error) {
+	var appConfig map[string]any
+	if err := json.Unmarshal(appConfigJSON, &appConfig); err != nil {
+		return nil, nil, err
+	}
+
+	modules, _ := appConfig["modules"].([]any)
+	for _, m := range modules {
+		mod, _ := m.(map[string]any)
+		if mod["name"] != envoy.ModuleName {
+			continue
+		}
+
+		modConfig, ok := mod["config"].(map[string]any)
+		if !ok {
+			return nil, nil, errors.New("envoy module without config")
+		}
+
+		var actions []leases.Action
+		if rawActions, ok := modConfig["actions"]; ok {
+			bz, err := json.Marshal(rawActions)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			decoder := json.NewDecoder(bytes.NewReader(bz))
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(&actions); err != nil {
+				return nil, nil, fmt.Errorf("envoy actions: %w", err)
+			}
+			if err := leases.ValidateActions(actions); err != nil {
+				return nil, nil, fmt.Errorf("envoy actions: %w", err)
+			}
+			delete(modConfig, "actions")
+		}
+
+		bz, err := json.Marshal(appConfig)
+		return bz, actions, err
+	}
+
+	return nil, nil, errors.New("no envoy module in the app config")
+}
+
 // NewMiniApp returns a reference to an initialized MiniApp.
 func NewMiniApp(
 	logger log.Logger,
@@ -131,6 +272,11 @@ func NewMiniApp(
 		&app.BankKeeper,
 		&app.StakingKeeper,
 		&app.DistrKeeper,
+		&app.MintKeeper,
+		&app.SlashingKeeper,
+		&app.EvidenceKeeper,
+		&app.GovKeeper,
+		&app.UpgradeKeeper,
 		&app.ConsensusParamsKeeper,
 		&app.EnvoyKeeper,
 		&app.EnvoyTracker,
@@ -139,6 +285,42 @@ func NewMiniApp(
 	}
 	app.App = appBuilder.Build(db, traceStore, baseAppOptions...)
 
+	// the leases module expires the envoy locks, which the envoy module keeps
+	// forever, it is ordered after envoy in app.yaml
+	leasesKey := storetypes.NewKVStoreKey(leases.StoreKey)
+	if err := app.RegisterStores(leasesKey); err != nil {
+		return nil, err
+	}
+	app.LeasesKeeper = leases.NewKeeper(
+		app.appCodec,
+		runtime.NewKVStoreService(leasesKey),
+		app.EnvoyKeeper.Locks,
+		app.StakingKeeper,
+		app.SlashingKeeper,
+		authtypes.NewModuleAddress(govtypes.ModuleName).String(),
+	)
+
+	actions, err := LockableActions()
+	if err != nil {
+		return nil, err
+	}
+	app.leasesModule = leases.NewAppModule(app.LeasesKeeper, actions)
+	if err := app.RegisterModules(app.leasesModule); err != nil {
+		return nil, err
+	}
+
+	// the upgrade module records at genesis the versions of the modules of the
+	// app config only, without the leases module the next upgrade would init
+	// it again
+	app.UpgradeKeeper.SetInitVersionMap(app.ModuleManager.GetVersionMap())
+
+	// the envoy locks are only created by hand in dev builds, and are queued at
+	// the end of the block which creates them, be it by a tx or a gov proposal
+	app.MsgServiceRouter().SetCircuit(leases.NewCircuitBreaker(app.LeasesKeeper))
+
+	// the txs creating a lock by hand are kept out of the mempool
+	app.SetAnteHandler(leases.NewAnteHandler(app.AnteHandler()))
+
 	// register streaming services
 	if err := app.RegisterStreamingServices(appOpts, app.kvStoreKeys()); err != nil {
 		return nil, err
@@ -146,13 +328,77 @@ func NewMiniApp(
 
 	/****  Module Options ****/
 
-	app.SetPrepareProposal(app.EnvoyTracker.PrepareProposal)
+	consAddr, err := loadValidatorAddress(appOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	envoyCfg, err := ReadEnvoyConfig(appOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	// run the job of each lockable action while the local validator holds its
+	// lock, the example job only logs; the runtime would replace the handler if
+	// app.yaml listed prepare_check_staters
+	app.Executors = NewActionExecutors(NewEnvoyLocks(app.EnvoyKeeper), app.StakingKeeper, consAddr, envoyCfg)
+	for _, action := range actions {
+		if err := app.Executors.Register(action.Name, NewLogExecutor(logger.With("module", "executor"))); err != nil {
+			return nil, err
+		}
+	}
+	app.SetPrepareCheckStater(app.Executors.PrepareCheckStater())
+
+	// the votes attest the completion of the running jobs, which the leases
+	// module tracks before each block, after the pre-blockers of app.yaml
+	voteExtHandler := NewVoteExtensionHandler(NewEnvoyLocks(app.EnvoyKeeper), app.StakingKeeper, consAddr, envoyCfg, app.Executors)
+	app.SetExtendVoteHandler(voteExtHandler.ExtendVote())
+	app.SetVerifyVoteExtensionHandler(voteExtHandler.VerifyVoteExtension())
+	app.SetPreBlocker(NewLivenessPreBlocker(app.App.PreBlocker, app.LeasesKeeper, voteExtHandler, app.StakingKeeper))
+
+	// the default handler selects the mempool txs, within what is left of the
+	// block after the vote extensions and envoy data are injected
+	proposalHandler := baseapp.NewDefaultProposalHandler(app.Mempool(), app)
+	proposalChain := NewProposalHandlerChain(
+		proposalHandler.PrepareProposalHandler(),
+		proposalHandler.ProcessProposalHandler(),
+		app.txConfig.TxDecoder(),
+		ProposalInjection{
+			Name:     "vote_extensions",
+			Injector: voteExtHandler,
+			Position: Prepend,
+			MaxBytes: VoteExtensionsMaxBytes,
+		},
+		ProposalInjection{
+			Name:     "envoy",
+			Injector: NewEnvoyProposalInjector(app.EnvoyTracker.PrepareProposal, app.txConfig.TxDecoder(), voteExtHandler),
+			Position: Prepend,
+			MaxBytes: EnvoyMaxBytes,
+		},
+	)
+	app.SetPrepareProposal(proposalChain.PrepareProposal())
+	app.SetProcessProposal(proposalChain.ProcessProposal())
 
 	// create the simulation manager and define the order of the modules for deterministic simulations
 	// NOTE: this is not required apps that don't use the simulator for fuzz testing transactions
-	app.sm = module.NewSimulationManagerFromAppModules(app.ModuleManager.Modules, make(map[string]module.AppModuleSimulation, 0))
+	overrideModules := map[string]module.AppModuleSimulation{
+		// the app has no vesting module, so the genesis accounts are base accounts
+		authtypes.ModuleName: auth.NewAppModule(app.appCodec, app.AccountKeeper, randomGenesisAccounts, nil),
+		envoy.ModuleName:     envoysim.NewAppModule(app.EnvoyKeeper, app.AccountKeeper, app.BankKeeper, app.txConfig),
+	}
+	app.sm = module.NewSimulationManagerFromAppModules(app.ModuleManager.Modules, overrideModules)
 	app.sm.RegisterStoreDecoders()
 
+	// read the genesis of each module from its own file when a genesis directory is set
+	if dir := genesisDir(appOpts); dir != "" {
+		app.SetInitChainer(app.StreamingInitChainer(dir))
+	}
+
+	// register upgrade handlers and, if an upgrade is pending, its store migrations
+	if err := app.registerUpgrades(); err != nil {
+		return nil, err
+	}
+
 	if err := app.Load(loadLatest); err != nil {
 		return nil, err
 	}
@@ -160,11 +406,50 @@ func NewMiniApp(
 	return app, nil
 }
 
+// randomGenesisAccounts returns a base account for each simulation account.
+func randomGenesisAccounts(simState *module.SimulationState) authtypes.GenesisAccounts {
+	genesisAccs := make(authtypes.GenesisAccounts, len(simState.Accounts))
+	for i, acc := range simState.Accounts {
+		genesisAccs[i] = authtypes.NewBaseAccountWithAddress(acc.Address)
+	}
+
+	return genesisAccs
+}
+
+// Close stops the action jobs run by the node, then closes the app.
+func (app *MiniApp) Close() error {
+	app.Executors.Close(app.Logger())
+	return app.App.Close()
+}
+
 // LegacyAmino returns MiniApp's amino codec.
 func (app *MiniApp) LegacyAmino() *codec.LegacyAmino {
 	return app.legacyAmino
 }
 
+// AppCodec returns MiniApp's app codec.
+func (app *MiniApp) AppCodec() codec.Codec {
+	return app.appCodec
+}
+
+// InterfaceRegistry returns MiniApp's InterfaceRegistry.
+func (app *MiniApp) InterfaceRegistry() codectypes.InterfaceRegistry {
+	return app.interfaceRegistry
+}
+
+// TxConfig returns MiniApp's TxConfig.
+func (app *MiniApp) TxConfig() client.TxConfig {
+	return app.txConfig
+}
+
+// DefaultGenesis returns the default genesis of the modules, including the
+// leases module, which the app config doesn't declare.
+func (app *MiniApp) DefaultGenesis() map[string]json.RawMessage {
+	genesis := app.App.DefaultGenesis()
+	genesis[leases.ModuleName] = app.leasesModule.DefaultGenesis(app.appCodec)
+	return genesis
+}
+
 // GetKey returns the KVStoreKey for the provided store key.
 func (app *MiniApp) GetKey(storeKey string) *storetypes.KVStoreKey {
 	sk := app.UnsafeFindStoreKey(storeKey)
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+func TestWithBech32Prefix(t *testing.T) {
+	bz, err := withBech32Prefix(AppConfigYAML, "star")
+	require.NoError(t, err)
+
+	var appConfig struct {
+		Modules []struct {
+			Name   string         `json:"name"`
+			Config map[string]any `json:"config"`
+		} `json:"modules"`
+	}
+	require.NoError(t, json.Unmarshal(bz, &appConfig))
+
+	var found bool
+	for _, mod := range appConfig.Modules {
+		if mod.Name == "auth" {
+			found = true
+			require.Equal(t, "star", mod.Config["bech32_prefix"])
+			require.Equal(t, "cosmos.auth.module.v1.Module", mod.Config["@type"])
+		}
+	}
+	require.True(t, found)
+
+	_, err = withBech32Prefix([]byte("modules: []"), "star")
+	require.Error(t, err)
+}
+
+func TestLockableActions(t *testing.T) {
+	actions, err := LockableActions()
+	require.NoError(t, err)
+	require.NotEmpty(t, actions)
+
+	// the envoy module reads its config without them
+	bz, err := yaml.YAMLToJSON(AppConfigYAML)
+	require.NoError(t, err)
+	bz, _, err = withoutLockableActions(bz)
+	require.NoError(t, err)
+	require.NotContains(t, string(bz), `"actions"`)
+
+	for name, tc := range map[string]struct {
+		appConfig string
+		wantErr   string
+	}{
+		"invalid action": {
+			appConfig: `{"modules":[{"name":"envoy","config":{"actions":[{"name":"checkpoint"}]}}]}`,
+			wantErr:   "no lease blocks",
+		},
+		"unknown field": {
+			appConfig: `{"modules":[{"name":"envoy","config":{"actions":[{"name":"checkpoint","lease_blocks":1,"holder":"alice"}]}}]}`,
+			wantErr:   "unknown field",
+		},
+		"no envoy module": {
+			appConfig: `{"modules":[]}`,
+			wantErr:   "no envoy module",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := withoutLockableActions([]byte(tc.appConfig))
+			require.ErrorContains(t, err, tc.wantErr)
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cast"
+
+	servertypes "github.com/cosmos/cosmos-sdk/server/types"
+)
+
+// EnvoyConfig is the [envoy] section of app.toml, the settings of how the
+// local node takes part in envoy. They only change what the local validator
+// attests, never how the votes and proposals of the others are verified.
+type EnvoyConfig struct {
+	// Enable attests the locks held by the local validator operator in its
+	// votes. A disabled node still verifies the attestations of the others.
+	Enable bool `mapstructure:"enable"`
+	// Actions are the lockable actions the node is willing to take, named as
+	// their locks. Empty means every action.
+	Actions []string `mapstructure:"actions"`
+	// AttestTimeout bounds the lookup of the held locks when extending a vote,
+	// past which the vote carries no attestation. Zero means no bound.
+	AttestTimeout time.Duration `mapstructure:"attest-timeout"`
+}
+
+// DefaultEnvoyConfig returns the envoy config of nodes without an [envoy]
+// section, attesting every lock held.
+func DefaultEnvoyConfig() EnvoyConfig {
+	return EnvoyConfig{
+		Enable: true,
+	}
+}
+
+// Validate checks the envoy config.
+func (c EnvoyConfig) Validate() error {
+	if c.AttestTimeout < 0 {
+		return fmt.Errorf("negative attest timeout %s", c.AttestTimeout)
+	}
+
+	seen := make(map[string]bool, len(c.Actions))
+	for _, action := range c.Actions {
+		if action == "" {
+			return errors.New("empty action")
+		}
+		if seen[action] {
+			return fmt.Errorf("duplicate action %s", action)
+		}
+		seen[action] = true
+	}
+
+	return nil
+}
+
+// Takes returns whether the node is willing to take the action.
+func (c EnvoyConfig) Takes(action string) bool {
+	if len(c.Actions) == 0 {
+		return true
+	}
+
+	for _, a := range c.Actions {
+		if a == action {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ReadEnvoyConfig reads the [envoy] section of app.toml from the app options,
+// keeping the defaults of the missing keys, and validates it.
+func ReadEnvoyConfig(appOpts servertypes.AppOptions) (EnvoyConfig, error) {
+	cfg := DefaultEnvoyConfig()
+
+	var err error
+	if v := appOpts.Get("envoy.enable"); v != nil {
+		if cfg.Enable, err = cast.ToBoolE(v); err != nil {
+			return cfg, fmt.Errorf("invalid envoy.enable: %w", err)
+		}
+	}
+	if v := appOpts.Get("envoy.actions"); v != nil {
+		if cfg.Actions, err = cast.ToStringSliceE(v); err != nil {
+			return cfg, fmt.Errorf("invalid envoy.actions: %w", err)
+		}
+	}
+	if v := appOpts.Get("envoy.attest-timeout"); v != nil {
+		if cfg.AttestTimeout, err = cast.ToDurationE(v); err != nil {
+			return cfg, fmt.Errorf("invalid envoy.attest-timeout: %w", err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid envoy config: %w", err)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,52 @@
+package app
+
+import (
+	"context"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/polygon/envoy"
+	envoykeeper "github.com/polygon/envoy/keeper"
+
+	"github.com/polygon/procyon/app/leases"
+)
+
+// EnvoyLocks is the read-only view of the envoy lock state used by the app.
+type EnvoyLocks interface {
+	// HeldLocks returns the names of the locks held by holder at the given height.
+	HeldLocks(ctx context.Context, holder sdk.AccAddress, height int64) ([]string, error)
+}
+
+// envoyLocks implements EnvoyLocks on top of the envoy keeper.
+type envoyLocks struct {
+	keeper envoykeeper.Keeper
+}
+
+// NewEnvoyLocks returns an EnvoyLocks reading from the envoy keeper.
+func NewEnvoyLocks(keeper envoykeeper.Keeper) EnvoyLocks {
+	return envoyLocks{keeper: keeper}
+}
+
+func (e envoyLocks) HeldLocks(ctx context.Context, holder sdk.AccAddress, height int64) ([]string, error) {
+	var names []string
+	err := e.keeper.Locks.Walk(ctx, nil, func(name string, lock envoy.Lock) (bool, error) {
+		// stop once the caller gave up, e.g. on a vote extension timeout
+		if err := ctx.Err(); err != nil {
+			return true, err
+		}
+
+		if lock.Envoy == holder.String() && lockActive(lock, height) {
+			names = append(names, name)
+		}
+		return false, nil
+	})
+
+	return names, err
+}
+
+// lockActive returns whether the lock covers the given height. The lock
+// heights are unsigned, and its end saturates as the expiry of the leases
+// module does.
+func lockActive(lock envoy.Lock, height int64) bool {
+	return height >= 0 && uint64(height) >= lock.AtBlock && uint64(height) < leases.Expiry(lock)
+}
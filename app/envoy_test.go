@@ -0,0 +1,33 @@
+package app
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/polygon/envoy"
+)
+
+func TestLockActive(t *testing.T) {
+	tests := []struct {
+		name   string
+		lock   envoy.Lock
+		height int64
+		active bool
+	}{
+		{name: "before", lock: envoy.Lock{AtBlock: 10, NumBlocks: 5}, height: 9},
+		{name: "first block", lock: envoy.Lock{AtBlock: 10, NumBlocks: 5}, height: 10, active: true},
+		{name: "last block", lock: envoy.Lock{AtBlock: 10, NumBlocks: 5}, height: 14, active: true},
+		{name: "expired", lock: envoy.Lock{AtBlock: 10, NumBlocks: 5}, height: 15},
+		{name: "endless", lock: envoy.Lock{AtBlock: 10, NumBlocks: math.MaxUint64}, height: math.MaxInt64, active: true},
+		{name: "beyond int64", lock: envoy.Lock{AtBlock: math.MaxUint64 - 1, NumBlocks: 1}, height: math.MaxInt64},
+		{name: "negative height", lock: envoy.Lock{NumBlocks: 5}, height: -1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.active, lockActive(tc.lock, tc.height))
+		})
+	}
+}
@@ -0,0 +1,159 @@
+// Package envoysim implements the simulation of the envoy module, so that lock
+// creation and expiry are fuzzed alongside the SDK modules.
+package envoysim
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/cosmos/cosmos-sdk/baseapp"
+	"github.com/cosmos/cosmos-sdk/client"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/module"
+	simtypes "github.com/cosmos/cosmos-sdk/types/simulation"
+	"github.com/cosmos/cosmos-sdk/x/simulation"
+
+	"github.com/polygon/envoy"
+	envoykeeper "github.com/polygon/envoy/keeper"
+
+	"github.com/polygon/procyon/app/leases"
+)
+
+// Simulation operation weights constants
+const (
+	OpWeightMsgCreateLock = "op_weight_msg_create_lock"
+
+	DefaultWeightMsgCreateLock = 50
+)
+
+const (
+	// numLockNames bounds the lock names, so that operations contend for the same locks.
+	numLockNames = 100
+	// maxLockBlocks bounds the lock durations, so that most locks expire during a simulation.
+	maxLockBlocks = 20
+)
+
+// AppModule is the simulation of the envoy module.
+type AppModule struct {
+	keeper        envoykeeper.Keeper
+	accountKeeper simulation.AccountKeeper
+	bankKeeper    simulation.BankKeeper
+	txConfig      client.TxConfig
+}
+
+var _ module.AppModuleSimulation = AppModule{}
+
+// NewAppModule returns the simulation of the envoy module.
+func NewAppModule(
+	keeper envoykeeper.Keeper,
+	accountKeeper simulation.AccountKeeper,
+	bankKeeper simulation.BankKeeper,
+	txConfig client.TxConfig,
+) AppModule {
+	return AppModule{
+		keeper:        keeper,
+		accountKeeper: accountKeeper,
+		bankKeeper:    bankKeeper,
+		txConfig:      txConfig,
+	}
+}
+
+// GenerateGenesisState creates a randomized genesis with locks held by the
+// simulation accounts, some of them already expired.
+func (AppModule) GenerateGenesisState(simState *module.SimulationState) {
+	var locks []envoy.Lock
+	for i := 0; i < simState.Rand.Intn(numLockNames/2); i++ {
+		holder, _ := simtypes.RandomAcc(simState.Rand, simState.Accounts)
+		locks = append(locks, envoy.Lock{
+			Name:      lockName(i),
+			Envoy:     holder.Address.String(),
+			AtBlock:   uint64(simState.Rand.Intn(maxLockBlocks)),
+			NumBlocks: uint64(1 + simState.Rand.Intn(maxLockBlocks)),
+		})
+	}
+
+	genesis := envoy.GenesisState{
+		Params: envoy.DefaultParams(),
+		Locks:  locks,
+	}
+	simState.GenState[envoy.ModuleName] = simState.Cdc.MustMarshalJSON(&genesis)
+}
+
+// RegisterStoreDecoder registers the decoder of the envoy collections.
+func (am AppModule) RegisterStoreDecoder(sdr simtypes.StoreDecoderRegistry) {
+	sdr[envoy.ModuleName] = simtypes.NewStoreDecoderFuncFromCollectionsSchema(am.keeper.Schema)
+}
+
+// WeightedOperations returns the envoy operations with their weights.
+func (am AppModule) WeightedOperations(simState module.SimulationState) []simtypes.WeightedOperation {
+	var weightMsgCreateLock int
+	simState.AppParams.GetOrGenerate(OpWeightMsgCreateLock, &weightMsgCreateLock, nil, func(_ *rand.Rand) {
+		weightMsgCreateLock = DefaultWeightMsgCreateLock
+	})
+
+	return []simtypes.WeightedOperation{
+		simulation.NewWeightedOperation(
+			weightMsgCreateLock,
+			SimulateMsgCreateLock(am.txConfig, am.accountKeeper, am.bankKeeper, am.keeper),
+		),
+	}
+}
+
+// SimulateMsgCreateLock generates a MsgCreateLock for a random lock name which
+// is not taken, held by a random account from a few blocks ahead. The locks are
+// only created by hand in dev builds.
+func SimulateMsgCreateLock(
+	txConfig client.TxConfig,
+	ak simulation.AccountKeeper,
+	bk simulation.BankKeeper,
+	k envoykeeper.Keeper,
+) simtypes.Operation {
+	return func(
+		r *rand.Rand, app *baseapp.BaseApp, ctx sdk.Context, accs []simtypes.Account, chainID string,
+	) (simtypes.OperationMsg, []simtypes.FutureOperation, error) {
+		msgType := sdk.MsgTypeURL(&envoy.MsgCreateLock{})
+		if !leases.ManualLocks {
+			return simtypes.NoOpMsg(envoy.ModuleName, msgType, "manual locks disabled"), nil, nil
+		}
+
+		name := lockName(r.Intn(numLockNames))
+		taken, err := k.Locks.Has(ctx, name)
+		if err != nil {
+			return simtypes.NoOpMsg(envoy.ModuleName, msgType, "unable to read lock"), nil, err
+		}
+		if taken {
+			return simtypes.NoOpMsg(envoy.ModuleName, msgType, "lock taken"), nil, nil
+		}
+
+		sender, _ := simtypes.RandomAcc(r, accs)
+		holder, _ := simtypes.RandomAcc(r, accs)
+
+		msg := &envoy.MsgCreateLock{
+			Sender:    sender.Address.String(),
+			Name:      name,
+			Envoy:     holder.Address.String(),
+			AtBlock:   uint64(ctx.BlockHeight()) + uint64(r.Intn(maxLockBlocks)),
+			NumBlocks: uint64(1 + r.Intn(maxLockBlocks)),
+		}
+
+		txCtx := simulation.OperationInput{
+			R:             r,
+			App:           app,
+			TxGen:         txConfig,
+			Cdc:           nil,
+			Msg:           msg,
+			Context:       ctx,
+			SimAccount:    sender,
+			AccountKeeper: ak,
+			Bankkeeper:    bk,
+			ModuleName:    envoy.ModuleName,
+		}
+
+		return simulation.GenAndDeliverTxWithRandFees(txCtx)
+	}
+}
+
+// lockName returns the i-th lock name.
+func lockName(i int) string {
+	return fmt.Sprintf("lock%d", i)
+}
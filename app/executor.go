@@ -0,0 +1,258 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"cosmossdk.io/log"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// ExecutorStopTimeout bounds the hand-off of a job, from the time it is asked
+// to stop.
+const ExecutorStopTimeout = 10 * time.Second
+
+// Executor runs the node exclusive job of a lockable action. It is started on
+// the local node once its validator operator holds the lock of the action, and
+// stopped when the lease ends or the lock is lost.
+//
+// The executor is driven from the commit of each block, so Start and Heartbeat
+// must not block: the job runs in its own goroutines.
+type Executor interface {
+	// Start starts the job of the action. The context is canceled once the
+	// job is stopped.
+	Start(ctx context.Context, action string) error
+	// Heartbeat is called at each block committed while the job runs. The job
+	// is stopped on error, and started again at the next block if the lock is
+	// still held.
+	Heartbeat(ctx context.Context, height int64) error
+	// Stop stops the job, and returns once it is stopped or the context is
+	// done. The next holder may start its job from the next block.
+	Stop(ctx context.Context) error
+}
+
+// ActionExecutors runs the executors of the lockable actions while the
+// operator of the local validator holds their locks.
+//
+// A job is started at the commit of the first block of a lease, as the lease
+// also covers the next block, and stopped at the commit of its last block. The
+// jobs of consecutive leases held by different validators are thus never run
+// at the same height.
+type ActionExecutors struct {
+	locks    EnvoyLocks
+	valStore ValidatorStore
+	consAddr sdk.ConsAddress
+	cfg      EnvoyConfig
+
+	mu        sync.Mutex
+	executors map[string]Executor
+	running   map[string]context.CancelFunc
+	// stopping holds the jobs being stopped, their channel is closed once the
+	// job is stopped
+	stopping map[string]chan struct{}
+}
+
+var _ RunningJobs = (*ActionExecutors)(nil)
+
+// NewActionExecutors returns the executors of the validator with the given
+// consensus address, which is nil on nodes without one, for the actions the
+// node takes.
+func NewActionExecutors(locks EnvoyLocks, valStore ValidatorStore, consAddr sdk.ConsAddress, cfg EnvoyConfig) *ActionExecutors {
+	return &ActionExecutors{
+		locks:     locks,
+		valStore:  valStore,
+		consAddr:  consAddr,
+		cfg:       cfg,
+		executors: make(map[string]Executor),
+		running:   make(map[string]context.CancelFunc),
+		stopping:  make(map[string]chan struct{}),
+	}
+}
+
+// Register registers the executor of the action.
+func (e *ActionExecutors) Register(action string, executor Executor) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.executors[action]; ok {
+		return fmt.Errorf("executor of action %s already registered", action)
+	}
+	e.executors[action] = executor
+
+	return nil
+}
+
+// PrepareCheckStater returns the handler starting and stopping the jobs from
+// the state committed by each block.
+func (e *ActionExecutors) PrepareCheckStater() sdk.PrepareCheckStater {
+	return func(ctx sdk.Context) {
+		if err := e.Sync(ctx); err != nil {
+			ctx.Logger().Error("failed to sync the action executors", "height", ctx.BlockHeight(), "err", err)
+		}
+	}
+}
+
+// Sync starts the jobs of the locks held by the local validator over the
+// committed height and the next one, heartbeats the running jobs whose lock is
+// still held at the next height, and stops the others. The jobs are stopped in
+// the background, and a job still stopping is started again at a later block.
+func (e *ActionExecutors) Sync(ctx sdk.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.consAddr == nil || !e.cfg.Enable || len(e.executors) == 0 {
+		return nil
+	}
+
+	height := ctx.BlockHeight()
+	current, err := validatorHeldLocks(ctx, e.locks, e.valStore, e.consAddr, height)
+	if err != nil {
+		return err
+	}
+	next, err := validatorHeldLocks(ctx, e.locks, e.valStore, e.consAddr, height+1)
+	if err != nil {
+		return err
+	}
+
+	for _, action := range e.actions() {
+		executor := e.executors[action]
+		_, running := e.running[action]
+		_, stopping := e.stopping[action]
+		held := e.cfg.Takes(action) && slices.Contains(next, action)
+
+		switch {
+		case running && !held:
+			e.stop(ctx.Logger(), action, executor)
+		case running:
+			if err := executor.Heartbeat(ctx, height); err != nil {
+				ctx.Logger().Error("action job failed", "action", action, "height", height, "err", err)
+				e.stop(ctx.Logger(), action, executor)
+			}
+		case held && !stopping && slices.Contains(current, action):
+			jobCtx, cancel := context.WithCancel(context.Background())
+			if err := executor.Start(jobCtx, action); err != nil {
+				cancel()
+				ctx.Logger().Error("failed to start action job", "action", action, "height", height, "err", err)
+				continue
+			}
+			e.running[action] = cancel
+			ctx.Logger().Info("started action job", "action", action, "height", height)
+		}
+	}
+
+	return nil
+}
+
+// Running implements RunningJobs, returning the actions whose job was started
+// and has not failed its last heartbeat.
+func (e *ActionExecutors) Running() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	running := make([]string, 0, len(e.running))
+	for action := range e.running {
+		running = append(running, action)
+	}
+	slices.Sort(running)
+
+	return running
+}
+
+// Close stops every running job, e.g. when the node shuts down, and waits
+// until they are all stopped.
+func (e *ActionExecutors) Close(logger log.Logger) {
+	e.mu.Lock()
+	for _, action := range e.actions() {
+		if _, ok := e.running[action]; ok {
+			e.stop(logger, action, e.executors[action])
+		}
+	}
+	e.mu.Unlock()
+
+	e.wait()
+}
+
+// stop removes the job of the action from the running ones, and stops it in
+// the background, waiting for at most ExecutorStopTimeout without holding the
+// lock, before canceling its context. It must be called with the lock held.
+func (e *ActionExecutors) stop(logger log.Logger, action string, executor Executor) {
+	cancelJob := e.running[action]
+	delete(e.running, action)
+
+	done := make(chan struct{})
+	e.stopping[action] = done
+
+	go func() {
+		defer close(done)
+
+		ctx, cancel := context.WithTimeout(context.Background(), ExecutorStopTimeout)
+		defer cancel()
+
+		if err := executor.Stop(ctx); err != nil {
+			logger.Error("failed to stop action job", "action", action, "err", err)
+		}
+		cancelJob()
+
+		e.mu.Lock()
+		delete(e.stopping, action)
+		e.mu.Unlock()
+		logger.Info("stopped action job", "action", action)
+	}()
+}
+
+// wait waits until the jobs being stopped are stopped.
+func (e *ActionExecutors) wait() {
+	e.mu.Lock()
+	stopping := make([]chan struct{}, 0, len(e.stopping))
+	for _, done := range e.stopping {
+		stopping = append(stopping, done)
+	}
+	e.mu.Unlock()
+
+	for _, done := range stopping {
+		<-done
+	}
+}
+
+// actions returns the actions with an executor, in a deterministic order.
+func (e *ActionExecutors) actions() []string {
+	actions := make([]string, 0, len(e.executors))
+	for action := range e.executors {
+		actions = append(actions, action)
+	}
+	slices.Sort(actions)
+
+	return actions
+}
+
+// logExecutor is an example executor, whose job logs its lease.
+type logExecutor struct {
+	logger log.Logger
+	action string
+}
+
+// NewLogExecutor returns an executor whose job only logs, to show when the
+// local node runs the job of an action.
+func NewLogExecutor(logger log.Logger) Executor {
+	return &logExecutor{logger: logger}
+}
+
+func (e *logExecutor) Start(_ context.Context, action string) error {
+	e.action = action
+	e.logger.Info("running the job of the action", "action", e.action)
+	return nil
+}
+
+func (e *logExecutor) Heartbeat(_ context.Context, height int64) error {
+	e.logger.Debug("the job of the action is alive", "action", e.action, "height", height)
+	return nil
+}
+
+func (e *logExecutor) Stop(context.Context) error {
+	e.logger.Info("handing off the job of the action", "action", e.action)
+	return nil
+}
@@ -0,0 +1,175 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/log"
+
+	addresscodec "github.com/cosmos/cosmos-sdk/codec/address"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+)
+
+// leaseLocks are the locks held by any holder, by height.
+type leaseLocks map[int64][]string
+
+func (m leaseLocks) HeldLocks(_ context.Context, _ sdk.AccAddress, height int64) ([]string, error) {
+	return m[height], nil
+}
+
+// mockExecutor records the calls to the executor.
+type mockExecutor struct {
+	calls        []string
+	heartbeatErr error
+	jobCtx       context.Context
+}
+
+func (m *mockExecutor) Start(ctx context.Context, action string) error {
+	m.calls = append(m.calls, "start "+action)
+	m.jobCtx = ctx
+	return nil
+}
+
+func (m *mockExecutor) Heartbeat(_ context.Context, height int64) error {
+	m.calls = append(m.calls, fmt.Sprintf("heartbeat %d", height))
+	return m.heartbeatErr
+}
+
+func (m *mockExecutor) Stop(context.Context) error {
+	m.calls = append(m.calls, "stop")
+	return nil
+}
+
+// blockingExecutor is a mockExecutor whose Stop blocks until released.
+type blockingExecutor struct {
+	mockExecutor
+	release chan struct{}
+}
+
+func (m *blockingExecutor) Stop(ctx context.Context) error {
+	<-m.release
+	return m.mockExecutor.Stop(ctx)
+}
+
+// newValidatorExecutors returns the executors of a validator, without any
+// executor registered.
+func newValidatorExecutors(t *testing.T, locks EnvoyLocks, cfg EnvoyConfig) *ActionExecutors {
+	t.Helper()
+
+	consAddr := sdk.ConsAddress("validator___________")
+	valCodec := addresscodec.NewBech32Codec(sdk.GetConfig().GetBech32ValidatorAddrPrefix())
+	valoper, err := valCodec.BytesToString(sdk.AccAddress("operator____________"))
+	require.NoError(t, err)
+
+	valStore := mockValStore{
+		codec:      valCodec,
+		validators: map[string]stakingtypes.Validator{consAddr.String(): {OperatorAddress: valoper}},
+	}
+
+	return NewActionExecutors(locks, valStore, consAddr, cfg)
+}
+
+func newTestExecutors(t *testing.T, locks EnvoyLocks, cfg EnvoyConfig) (*ActionExecutors, *mockExecutor) {
+	t.Helper()
+
+	executors := newValidatorExecutors(t, locks, cfg)
+	executor := &mockExecutor{}
+	require.NoError(t, executors.Register("checkpoint", executor))
+	require.Error(t, executors.Register("checkpoint", executor))
+
+	return executors, executor
+}
+
+// syncHeights syncs the executors at the commit of each height.
+func syncHeights(t *testing.T, executors *ActionExecutors, from, to int64) {
+	t.Helper()
+
+	ctx := sdk.Context{}.WithContext(context.Background()).WithLogger(log.NewNopLogger())
+	for height := from; height <= to; height++ {
+		require.NoError(t, executors.Sync(ctx.WithBlockHeight(height)))
+	}
+}
+
+func TestActionExecutors(t *testing.T) {
+	// the lease covers the heights 5 to 7
+	locks := leaseLocks{5: {"checkpoint"}, 6: {"checkpoint"}, 7: {"checkpoint"}}
+	executors, executor := newTestExecutors(t, locks, DefaultEnvoyConfig())
+
+	syncHeights(t, executors, 1, 5)
+	require.Equal(t, []string{"checkpoint"}, executors.Running())
+
+	// the job is stopped in the background, and no longer reported running
+	syncHeights(t, executors, 6, 10)
+	require.Empty(t, executors.Running())
+	executors.wait()
+	require.Equal(t, []string{"start checkpoint", "heartbeat 6", "stop"}, executor.calls)
+	require.ErrorIs(t, executor.jobCtx.Err(), context.Canceled)
+}
+
+func TestActionExecutorsHeartbeatFailure(t *testing.T) {
+	locks := leaseLocks{5: {"checkpoint"}, 6: {"checkpoint"}, 7: {"checkpoint"}, 8: {"checkpoint"}}
+	executors, executor := newTestExecutors(t, locks, DefaultEnvoyConfig())
+	executor.heartbeatErr = errors.New("unhealthy")
+
+	// the failed job is stopped, and started again at the next block once
+	// stopped
+	syncHeights(t, executors, 5, 6)
+	executors.wait()
+	syncHeights(t, executors, 7, 7)
+	require.Equal(t, []string{"start checkpoint", "heartbeat 6", "stop", "start checkpoint"}, executor.calls)
+
+	// the running jobs are stopped on close
+	executors.Close(log.NewNopLogger())
+	require.Equal(t, "stop", executor.calls[len(executor.calls)-1])
+}
+
+func TestActionExecutorsStopInBackground(t *testing.T) {
+	locks := leaseLocks{5: {"checkpoint"}, 6: {"checkpoint"}, 7: {"checkpoint"}, 8: {"checkpoint"}, 9: {"checkpoint"}}
+	executors := newValidatorExecutors(t, locks, DefaultEnvoyConfig())
+	executor := &blockingExecutor{release: make(chan struct{})}
+	executor.heartbeatErr = errors.New("unhealthy")
+	require.NoError(t, executors.Register("checkpoint", executor))
+
+	// the failed job is stopping, which holds up neither the commits nor the
+	// running jobs, and is not started again until stopped
+	syncHeights(t, executors, 5, 7)
+	require.Empty(t, executors.Running())
+	require.Equal(t, []string{"start checkpoint", "heartbeat 6"}, executor.calls)
+
+	close(executor.release)
+	executors.wait()
+	syncHeights(t, executors, 8, 8)
+	require.Equal(t, []string{"checkpoint"}, executors.Running())
+	require.Equal(t, []string{"start checkpoint", "heartbeat 6", "stop", "start checkpoint"}, executor.calls)
+
+	executors.Close(log.NewNopLogger())
+	require.Empty(t, executors.Running())
+	require.Equal(t, "stop", executor.calls[len(executor.calls)-1])
+}
+
+func TestActionExecutorsEnvoyConfig(t *testing.T) {
+	locks := leaseLocks{5: {"checkpoint"}, 6: {"checkpoint"}}
+
+	for name, cfg := range map[string]EnvoyConfig{
+		"disabled":      {Enable: false},
+		"other actions": {Enable: true, Actions: []string{"other"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			executors, executor := newTestExecutors(t, locks, cfg)
+			syncHeights(t, executors, 1, 10)
+			require.Empty(t, executor.calls)
+		})
+	}
+
+	// a node without validator runs no job
+	executors := NewActionExecutors(locks, mockValStore{}, nil, DefaultEnvoyConfig())
+	executor := &mockExecutor{}
+	require.NoError(t, executors.Register("checkpoint", executor))
+	syncHeights(t, executors, 1, 10)
+	require.Empty(t, executor.calls)
+}
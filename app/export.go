@@ -4,14 +4,20 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"strings"
 
 	storetypes "cosmossdk.io/store/types"
 	tmproto "github.com/cometbft/cometbft/proto/tendermint/types"
 	servertypes "github.com/cosmos/cosmos-sdk/server/types"
 	sdk "github.com/cosmos/cosmos-sdk/types"
+	distrtypes "github.com/cosmos/cosmos-sdk/x/distribution/types"
+	slashingtypes "github.com/cosmos/cosmos-sdk/x/slashing/types"
 	"github.com/cosmos/cosmos-sdk/x/staking"
 	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+
+	"github.com/polygon/envoy"
+
+	"github.com/polygon/procyon/app/leases"
 )
 
 // ExportAppStateAndValidators exports the state of the application for a genesis file.
@@ -20,15 +26,9 @@ func (app *MiniApp) ExportAppStateAndValidators(
 	jailAllowedAddrs []string,
 	modulesToExport []string,
 ) (servertypes.ExportedApp, error) {
-	// as if they could withdraw from the start of the next block
-	ctx := app.NewContextLegacy(true, tmproto.Header{Height: app.LastBlockHeight()})
-
-	// We export at last height + 1, because that's the height at which
-	// CometBFT will start InitChain.
-	height := app.LastBlockHeight() + 1
-	if forZeroHeight {
-		height = 0
-		app.prepForZeroHeightGenesis(ctx, jailAllowedAddrs)
+	ctx, height, err := app.exportContext(forZeroHeight, jailAllowedAddrs)
+	if err != nil {
+		return servertypes.ExportedApp{}, err
 	}
 
 	genState, err := app.ModuleManager.ExportGenesis(ctx, app.appCodec)
@@ -50,57 +50,196 @@ func (app *MiniApp) ExportAppStateAndValidators(
 	}, err
 }
 
-// prepare for fresh start at zero height
-// NOTE zero height genesis is a temporary feature, which will be deprecated in favour of export at a block height
-func (app *MiniApp) prepForZeroHeightGenesis(ctx sdk.Context, jailAllowedAddrs []string) {
-	applyAllowedAddrs := false
+// exportContext returns the context to export the state from, prepared for a
+// zero height genesis if asked, and the height the exported chain starts at.
+func (app *MiniApp) exportContext(forZeroHeight bool, jailAllowedAddrs []string) (sdk.Context, int64, error) {
+	// as if they could withdraw from the start of the next block
+	ctx := app.NewContextLegacy(true, tmproto.Header{Height: app.LastBlockHeight()})
 
-	// check if there is a allowed address list
-	if len(jailAllowedAddrs) > 0 {
-		applyAllowedAddrs = true
+	// We export at last height + 1, because that's the height at which
+	// CometBFT will start InitChain.
+	height := app.LastBlockHeight() + 1
+	if forZeroHeight {
+		height = 0
+		report, err := app.prepForZeroHeightGenesis(ctx, jailAllowedAddrs)
+		if err != nil {
+			return ctx, 0, err
+		}
+		app.logZeroHeightReport(report)
 	}
 
-	allowedAddrsMap := make(map[string]bool)
+	return ctx, height, nil
+}
+
+// ZeroHeightDryRun runs the zero height genesis preparation on a branch of the
+// state which is then discarded, and reports what it would change.
+func (app *MiniApp) ZeroHeightDryRun(jailAllowedAddrs []string) (ZeroHeightReport, error) {
+	ctx, _ := app.NewContextLegacy(true, tmproto.Header{Height: app.LastBlockHeight()}).CacheContext()
+	return app.prepForZeroHeightGenesis(ctx, jailAllowedAddrs)
+}
+
+// ZeroHeightReport reports the changes made to the state for a zero height genesis.
+type ZeroHeightReport struct {
+	Height               int64           `json:"height"`
+	WithdrawnCommissions []string        `json:"withdrawn_commissions"`
+	WithdrawnRewards     int             `json:"withdrawn_rewards"`
+	JailedValidators     []string        `json:"jailed_validators"`
+	EnvoyLocks           EnvoyLockRebase `json:"envoy_locks"`
+}
+
+// ZeroHeightFailure is a failure of one step of the zero height genesis
+// preparation, for a validator or delegation when it applies.
+type ZeroHeightFailure struct {
+	Step      string
+	Validator string
+	Delegator string
+	Err       error
+}
+
+func (f ZeroHeightFailure) Error() string {
+	var b strings.Builder
+	b.WriteString(f.Step)
+	if f.Validator != "" {
+		b.WriteString(" validator=" + f.Validator)
+	}
+	if f.Delegator != "" {
+		b.WriteString(" delegator=" + f.Delegator)
+	}
+
+	return fmt.Sprintf("%s: %v", b.String(), f.Err)
+}
+
+func (f ZeroHeightFailure) Unwrap() error {
+	return f.Err
+}
+
+// ZeroHeightError aggregates the failures of a zero height genesis preparation.
+type ZeroHeightError struct {
+	Failures []ZeroHeightFailure
+}
+
+func (e *ZeroHeightError) Error() string {
+	msgs := make([]string, len(e.Failures))
+	for i, failure := range e.Failures {
+		msgs[i] = failure.Error()
+	}
+
+	return fmt.Sprintf("zero height genesis preparation failed with %d errors:\n%s", len(e.Failures), strings.Join(msgs, "\n"))
+}
+
+func (e *ZeroHeightError) Unwrap() []error {
+	errs := make([]error, len(e.Failures))
+	for i, failure := range e.Failures {
+		errs[i] = failure
+	}
+
+	return errs
+}
+
+// add records a failure.
+func (e *ZeroHeightError) add(step, validator, delegator string, err error) {
+	e.Failures = append(e.Failures, ZeroHeightFailure{
+		Step:      step,
+		Validator: validator,
+		Delegator: delegator,
+		Err:       err,
+	})
+}
+
+// orNil returns the error if any failure was recorded.
+func (e *ZeroHeightError) orNil() error {
+	if len(e.Failures) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+// validateJailAllowedAddrs returns the jail allowlist as a set, or the
+// aggregated error of its invalid addresses.
+func (app *MiniApp) validateJailAllowedAddrs(jailAllowedAddrs []string) (map[string]bool, error) {
+	var (
+		zhErr           = &ZeroHeightError{}
+		allowedAddrsMap = make(map[string]bool)
+	)
 
 	for _, addr := range jailAllowedAddrs {
-		_, err := sdk.ValAddressFromBech32(addr)
-		if err != nil {
-			log.Fatal(err)
+		if _, err := sdk.ValAddressFromBech32(addr); err != nil {
+			zhErr.add("validate jail allowlist", addr, "", err)
+			continue
 		}
 		allowedAddrsMap[addr] = true
 	}
 
+	return allowedAddrsMap, zhErr.orNil()
+}
+
+// prepare for fresh start at zero height
+// NOTE zero height genesis is a temporary feature, which will be deprecated in favour of export at a block height
+//
+// Failures do not stop the preparation, they are all returned in a
+// ZeroHeightError once it is done, except when the allowlist is invalid or
+// the delegations cannot be read, in which case it stops at once.
+func (app *MiniApp) prepForZeroHeightGenesis(ctx sdk.Context, jailAllowedAddrs []string) (ZeroHeightReport, error) {
+	report := ZeroHeightReport{Height: ctx.BlockHeight()}
+	zhErr := &ZeroHeightError{}
+
+	// check if there is a allowed address list
+	applyAllowedAddrs := len(jailAllowedAddrs) > 0
+
+	allowedAddrsMap, err := app.validateJailAllowedAddrs(jailAllowedAddrs)
+	if err != nil {
+		return report, err
+	}
+
 	/* Handle fee distribution state. */
 
 	// withdraw all validator commission
-	app.StakingKeeper.IterateValidators(ctx, func(_ int64, val stakingtypes.ValidatorI) (stop bool) {
+	if err := app.StakingKeeper.IterateValidators(ctx, func(_ int64, val stakingtypes.ValidatorI) (stop bool) {
 		valBz, err := app.StakingKeeper.ValidatorAddressCodec().StringToBytes(val.GetOperator())
 		if err != nil {
-			panic(err)
+			zhErr.add("decode validator address", val.GetOperator(), "", err)
+			return false
+		}
+
+		if _, err := app.DistrKeeper.WithdrawValidatorCommission(ctx, valBz); err != nil {
+			if !errors.Is(err, distrtypes.ErrNoValidatorCommission) {
+				zhErr.add("withdraw validator commission", val.GetOperator(), "", err)
+			}
+			return false
 		}
 
-		_, _ = app.DistrKeeper.WithdrawValidatorCommission(ctx, valBz)
+		report.WithdrawnCommissions = append(report.WithdrawnCommissions, val.GetOperator())
 		return false
-	})
+	}); err != nil {
+		zhErr.add("iterate validators", "", "", err)
+	}
 
 	// withdraw all delegator rewards
 	dels, err := app.StakingKeeper.GetAllDelegations(ctx)
 	if err != nil {
-		panic(err)
+		zhErr.add("get delegations", "", "", err)
+		return report, zhErr
 	}
 
 	for _, delegation := range dels {
 		valAddr, err := sdk.ValAddressFromBech32(delegation.ValidatorAddress)
 		if err != nil {
-			panic(err)
+			zhErr.add("decode validator address", delegation.ValidatorAddress, delegation.DelegatorAddress, err)
+			continue
 		}
 
 		delAddr, err := sdk.AccAddressFromBech32(delegation.DelegatorAddress)
 		if err != nil {
-			panic(err)
+			zhErr.add("decode delegator address", delegation.ValidatorAddress, delegation.DelegatorAddress, err)
+			continue
 		}
 
-		_, _ = app.DistrKeeper.WithdrawDelegationRewards(ctx, delAddr, valAddr)
+		if _, err := app.DistrKeeper.WithdrawDelegationRewards(ctx, delAddr, valAddr); err != nil {
+			zhErr.add("withdraw delegation rewards", delegation.ValidatorAddress, delegation.DelegatorAddress, err)
+			continue
+		}
+		report.WithdrawnRewards++
 	}
 
 	// clear validator slash events
@@ -114,54 +253,61 @@ func (app *MiniApp) prepForZeroHeightGenesis(ctx sdk.Context, jailAllowedAddrs [
 	ctx = ctx.WithBlockHeight(0)
 
 	// reinitialize all validators
-	app.StakingKeeper.IterateValidators(ctx, func(_ int64, val stakingtypes.ValidatorI) (stop bool) {
+	if err := app.StakingKeeper.IterateValidators(ctx, func(_ int64, val stakingtypes.ValidatorI) (stop bool) {
 		valBz, err := app.StakingKeeper.ValidatorAddressCodec().StringToBytes(val.GetOperator())
 		if err != nil {
-			panic(err)
+			zhErr.add("decode validator address", val.GetOperator(), "", err)
+			return false
 		}
 
 		// donate any unwithdrawn outstanding reward fraction tokens to the community pool
 		scraps, err := app.DistrKeeper.GetValidatorOutstandingRewardsCoins(ctx, valBz)
 		if err != nil {
-			panic(err)
+			zhErr.add("get validator outstanding rewards", val.GetOperator(), "", err)
+			return false
 		}
 
 		feePool, err := app.DistrKeeper.FeePool.Get(ctx)
 		if err != nil {
-			panic(err)
+			zhErr.add("get fee pool", val.GetOperator(), "", err)
+			return false
 		}
 
 		feePool.CommunityPool = feePool.CommunityPool.Add(scraps...)
 		if err := app.DistrKeeper.FeePool.Set(ctx, feePool); err != nil {
-			panic(err)
+			zhErr.add("set fee pool", val.GetOperator(), "", err)
+			return false
 		}
 
 		if err := app.DistrKeeper.Hooks().AfterValidatorCreated(ctx, valBz); err != nil {
-			panic(err)
+			zhErr.add("reinitialize validator", val.GetOperator(), "", err)
 		}
 		return false
-	})
+	}); err != nil {
+		zhErr.add("iterate validators", "", "", err)
+	}
 
 	// reinitialize all delegations
 	for _, del := range dels {
 		valAddr, err := sdk.ValAddressFromBech32(del.ValidatorAddress)
 		if err != nil {
-			panic(err)
+			zhErr.add("decode validator address", del.ValidatorAddress, del.DelegatorAddress, err)
+			continue
 		}
 
 		delAddr, err := sdk.AccAddressFromBech32(del.DelegatorAddress)
 		if err != nil {
-			panic(err)
+			zhErr.add("decode delegator address", del.ValidatorAddress, del.DelegatorAddress, err)
+			continue
 		}
 
 		if err := app.DistrKeeper.Hooks().BeforeDelegationCreated(ctx, delAddr, valAddr); err != nil {
-			// never called as BeforeDelegationCreated always returns nil
-			panic(fmt.Errorf("error while incrementing period: %w", err))
+			zhErr.add("increment delegation period", del.ValidatorAddress, del.DelegatorAddress, err)
+			continue
 		}
 
 		if err := app.DistrKeeper.Hooks().AfterDelegationModified(ctx, delAddr, valAddr); err != nil {
-			// never called as AfterDelegationModified always returns nil
-			panic(fmt.Errorf("error while creating a new delegation period record: %w", err))
+			zhErr.add("create delegation period record", del.ValidatorAddress, del.DelegatorAddress, err)
 		}
 	}
 
@@ -171,22 +317,30 @@ func (app *MiniApp) prepForZeroHeightGenesis(ctx sdk.Context, jailAllowedAddrs [
 	/* Handle staking state. */
 
 	// iterate through redelegations, reset creation height
-	app.StakingKeeper.IterateRedelegations(ctx, func(_ int64, red stakingtypes.Redelegation) (stop bool) {
+	if err := app.StakingKeeper.IterateRedelegations(ctx, func(_ int64, red stakingtypes.Redelegation) (stop bool) {
 		for i := range red.Entries {
 			red.Entries[i].CreationHeight = 0
 		}
-		app.StakingKeeper.SetRedelegation(ctx, red)
+		if err := app.StakingKeeper.SetRedelegation(ctx, red); err != nil {
+			zhErr.add("reset redelegation", red.ValidatorSrcAddress, red.DelegatorAddress, err)
+		}
 		return false
-	})
+	}); err != nil {
+		zhErr.add("iterate redelegations", "", "", err)
+	}
 
 	// iterate through unbonding delegations, reset creation height
-	app.StakingKeeper.IterateUnbondingDelegations(ctx, func(_ int64, ubd stakingtypes.UnbondingDelegation) (stop bool) {
+	if err := app.StakingKeeper.IterateUnbondingDelegations(ctx, func(_ int64, ubd stakingtypes.UnbondingDelegation) (stop bool) {
 		for i := range ubd.Entries {
 			ubd.Entries[i].CreationHeight = 0
 		}
-		app.StakingKeeper.SetUnbondingDelegation(ctx, ubd)
+		if err := app.StakingKeeper.SetUnbondingDelegation(ctx, ubd); err != nil {
+			zhErr.add("reset unbonding delegation", ubd.ValidatorAddress, ubd.DelegatorAddress, err)
+		}
 		return false
-	})
+	}); err != nil {
+		zhErr.add("iterate unbonding delegations", "", "", err)
+	}
 
 	// Iterate through validators by power descending, reset bond heights, and
 	// update bond intra-tx counters.
@@ -198,27 +352,189 @@ func (app *MiniApp) prepForZeroHeightGenesis(ctx sdk.Context, jailAllowedAddrs [
 		addr := sdk.ValAddress(stakingtypes.AddressFromValidatorsKey(iter.Key()))
 		validator, err := app.StakingKeeper.GetValidator(ctx, addr)
 		if errors.Is(err, stakingtypes.ErrNoValidatorFound) {
-			panic("expected validator, not found")
+			zhErr.add("get validator", addr.String(), "", errors.New("expected validator, not found"))
+			continue
 		} else if err != nil {
-			panic(err)
+			zhErr.add("get validator", addr.String(), "", err)
+			continue
 		}
 
 		validator.UnbondingHeight = 0
 		if applyAllowedAddrs && !allowedAddrsMap[addr.String()] {
+			// a jailed validator leaves the power index, as when jailed by staking
+			if err := app.StakingKeeper.DeleteValidatorByPowerIndex(ctx, validator); err != nil {
+				zhErr.add("remove jailed validator from power index", addr.String(), "", err)
+			}
 			validator.Jailed = true
+			report.JailedValidators = append(report.JailedValidators, addr.String())
 		}
 
-		app.StakingKeeper.SetValidator(ctx, validator)
+		if err := app.StakingKeeper.SetValidator(ctx, validator); err != nil {
+			zhErr.add("set validator", addr.String(), "", err)
+		}
 		counter++
 	}
 
 	if err := iter.Close(); err != nil {
-		app.Logger().Error("error while closing the key-value store reverse prefix iterator: ", err)
-		return
+		zhErr.add("close validators iterator", "", "", err)
+	}
+
+	if _, err := app.StakingKeeper.ApplyAndReturnValidatorSetUpdates(ctx); err != nil {
+		zhErr.add("apply validator set updates", "", "", err)
+	}
+
+	/* Handle slashing state. */
+
+	// reset start height on signing infos
+	if err := app.SlashingKeeper.IterateValidatorSigningInfos(
+		ctx,
+		func(addr sdk.ConsAddress, info slashingtypes.ValidatorSigningInfo) (stop bool) {
+			info.StartHeight = 0
+			if err := app.SlashingKeeper.SetValidatorSigningInfo(ctx, addr, info); err != nil {
+				zhErr.add("reset signing info", addr.String(), "", err)
+			}
+			return false
+		},
+	); err != nil {
+		zhErr.add("iterate signing infos", "", "", err)
+	}
+
+	/* Handle envoy state. */
+
+	report.EnvoyLocks, err = app.rebaseEnvoyLocks(ctx, height)
+	if err != nil {
+		zhErr.add("rebase envoy locks", "", "", err)
+	}
+	if err := app.clearLeases(ctx, &report.EnvoyLocks); err != nil {
+		zhErr.add("clear leases", "", "", err)
+	}
+
+	return report, zhErr.orNil()
+}
+
+// logZeroHeightReport logs the changes made for a zero height genesis.
+func (app *MiniApp) logZeroHeightReport(report ZeroHeightReport) {
+	for _, lock := range report.EnvoyLocks.Rebased {
+		app.Logger().Info("rebased envoy lock", "name", lock.Name,
+			"at_block", lock.AtBlock, "num_blocks", lock.NumBlocks,
+			"new_at_block", lock.NewAtBlock, "new_num_blocks", lock.NewNumBlocks)
+	}
+
+	app.Logger().Info("prepared zero height genesis",
+		"height", report.Height,
+		"withdrawn_commissions", len(report.WithdrawnCommissions),
+		"withdrawn_rewards", report.WithdrawnRewards,
+		"jailed_validators", len(report.JailedValidators),
+		"rebased_locks", len(report.EnvoyLocks.Rebased),
+		"dropped_locks", len(report.EnvoyLocks.Dropped),
+		"cleared_history", report.EnvoyLocks.ClearedHistory,
+		"cleared_misses", report.EnvoyLocks.ClearedMisses,
+	)
+}
+
+// EnvoyLockRebase reports the changes made to the envoy locks for a zero
+// height genesis.
+type EnvoyLockRebase struct {
+	Rebased []RebasedLock `json:"rebased"`
+	// Dropped lists the names of the expired locks.
+	Dropped []string `json:"dropped"`
+	// ClearedHistory is the number of expired leases removed from the history
+	// of the leases module.
+	ClearedHistory int `json:"cleared_history"`
+	// ClearedMisses is the number of locks whose holder's misses were reset.
+	ClearedMisses int `json:"cleared_misses"`
+}
+
+// RebasedLock is a lock moved to the heights of the new chain.
+type RebasedLock struct {
+	Name         string `json:"name"`
+	AtBlock      uint64 `json:"at_block"`
+	NumBlocks    uint64 `json:"num_blocks"`
+	NewAtBlock   uint64 `json:"new_at_block"`
+	NewNumBlocks uint64 `json:"new_num_blocks"`
+}
+
+// rebaseEnvoyLocks rebases the envoy locks for a chain restarting at height one
+// in place of the height after the export height: expired locks are dropped,
+// and the others keep the blocks they have left from their new start.
+func (app *MiniApp) rebaseEnvoyLocks(ctx sdk.Context, height int64) (EnvoyLockRebase, error) {
+	var (
+		report EnvoyLockRebase
+		locks  []envoy.Lock
+	)
+
+	if err := app.EnvoyKeeper.Locks.Walk(ctx, nil, func(_ string, lock envoy.Lock) (bool, error) {
+		locks = append(locks, lock)
+		return false, nil
+	}); err != nil {
+		return report, err
+	}
+
+	// the lock heights are unsigned, and their end saturates like the expiry
+	// of the leases module
+	next := uint64(height) + 1
+	for _, lock := range locks {
+		start, end := lock.AtBlock, leases.Expiry(lock)
+
+		if end <= next {
+			if err := app.EnvoyKeeper.Locks.Remove(ctx, lock.Name); err != nil {
+				return report, err
+			}
+			report.Dropped = append(report.Dropped, lock.Name)
+			continue
+		}
+
+		start = max(start, next)
+		rebased := lock
+		rebased.AtBlock = start - uint64(height)
+		rebased.NumBlocks = end - start
+
+		if err := app.EnvoyKeeper.Locks.Set(ctx, lock.Name, rebased); err != nil {
+			return report, err
+		}
+
+		report.Rebased = append(report.Rebased, RebasedLock{
+			Name:         lock.Name,
+			AtBlock:      lock.AtBlock,
+			NumBlocks:    lock.NumBlocks,
+			NewAtBlock:   rebased.AtBlock,
+			NewNumBlocks: rebased.NumBlocks,
+		})
 	}
 
-	_, err = app.StakingKeeper.ApplyAndReturnValidatorSetUpdates(ctx)
+	return report, nil
+}
+
+// clearLeases clears the history and the misses of the leases module for a
+// chain restarting at height one: the history holds the expiry heights of the
+// old chain, which the new one doesn't have, and the holders start the new
+// chain without misses.
+func (app *MiniApp) clearLeases(ctx sdk.Context, report *EnvoyLockRebase) error {
+	history, err := app.LeasesKeeper.History.Iterate(ctx, nil)
+	if err != nil {
+		return err
+	}
+	leased, err := history.Keys()
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	if err := app.LeasesKeeper.History.Clear(ctx, nil); err != nil {
+		return err
 	}
+	report.ClearedHistory = len(leased)
+
+	misses, err := app.LeasesKeeper.Misses.Iterate(ctx, nil)
+	if err != nil {
+		return err
+	}
+	missed, err := misses.Keys()
+	if err != nil {
+		return err
+	}
+	if err := app.LeasesKeeper.Misses.Clear(ctx, nil); err != nil {
+		return err
+	}
+	report.ClearedMisses = len(missed)
+
+	return nil
 }
@@ -0,0 +1,429 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	abci "github.com/cometbft/cometbft/abci/types"
+	cmtproto "github.com/cometbft/cometbft/proto/tendermint/types"
+	dbm "github.com/cosmos/cosmos-db"
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/collections"
+	"cosmossdk.io/log"
+	storetypes "cosmossdk.io/store/types"
+
+	"github.com/cosmos/cosmos-sdk/baseapp"
+	servertypes "github.com/cosmos/cosmos-sdk/server/types"
+	simtestutil "github.com/cosmos/cosmos-sdk/testutil/sims"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	simtypes "github.com/cosmos/cosmos-sdk/types/simulation"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	banktestutil "github.com/cosmos/cosmos-sdk/x/bank/testutil"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	"github.com/cosmos/cosmos-sdk/x/simulation"
+	simcli "github.com/cosmos/cosmos-sdk/x/simulation/client/cli"
+	slashingtypes "github.com/cosmos/cosmos-sdk/x/slashing/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+
+	"github.com/polygon/envoy"
+
+	"github.com/polygon/procyon/app/leases"
+)
+
+// exportSkipPrefixes are the store prefixes not carried over by a genesis
+// export, which are rebuilt or left empty on import.
+var exportSkipPrefixes = map[string][][]byte{
+	stakingtypes.StoreKey: {
+		stakingtypes.UnbondingQueueKey, stakingtypes.RedelegationQueueKey, stakingtypes.ValidatorQueueKey,
+		stakingtypes.HistoricalInfoKey, stakingtypes.UnbondingIDKey, stakingtypes.UnbondingIndexKey,
+		stakingtypes.UnbondingTypeKey, stakingtypes.ValidatorUpdatesKey,
+	},
+	slashingtypes.StoreKey: {slashingtypes.ValidatorMissedBlockBitmapKeyPrefix},
+}
+
+// zeroHeightSkipPrefixes are skipped in addition to exportSkipPrefixes after a
+// zero height export, which rebases the envoy locks queued for expiry at the
+// heights of the old chain.
+var zeroHeightSkipPrefixes = map[string][][]byte{
+	leases.StoreKey: {leases.ExpiryQueuePrefix.Bytes(), leases.ExpiryIndexPrefix.Bytes()},
+}
+
+const exportTestBlocks = 300
+
+func TestExportImport(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping export/import round-trip in short mode")
+	}
+
+	app := simulateBlocks(t, exportTestBlocks)
+
+	exported, err := app.ExportAppStateAndValidators(false, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, app.LastBlockHeight()+1, exported.Height)
+
+	newApp, ctxB := importGenesis(t, exported, app.LastBlockHeight())
+	ctxA := app.NewContextLegacy(true, cmtproto.Header{Height: app.LastBlockHeight()})
+
+	requireStoresEqual(t, app, ctxA, newApp, ctxB, exportSkipPrefixes)
+}
+
+func TestExportImportZeroHeight(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping export/import round-trip in short mode")
+	}
+
+	app := simulateBlocks(t, exportTestBlocks)
+	ctxA := app.NewContextLegacy(true, cmtproto.Header{Height: app.LastBlockHeight()})
+
+	validators, err := app.StakingKeeper.GetBondedValidatorsByPower(ctxA)
+	require.NoError(t, err)
+	if len(validators) == 0 {
+		t.Skip("no bonded validators left after the simulation")
+	}
+
+	// only the most powerful validator stays unjailed
+	allowed := validators[0].GetOperator()
+
+	exported, err := app.ExportAppStateAndValidators(true, []string{allowed}, nil)
+	require.NoError(t, err)
+	require.Zero(t, exported.Height)
+
+	newApp, ctxB := importGenesis(t, exported, app.LastBlockHeight())
+
+	// the zero height preparation is written to the check state the export ran on
+	ctxA = app.NewContextLegacy(true, cmtproto.Header{Height: app.LastBlockHeight()})
+
+	skipPrefixes := make(map[string][][]byte)
+	for name, prefixes := range exportSkipPrefixes {
+		skipPrefixes[name] = append(skipPrefixes[name], prefixes...)
+	}
+	for name, prefixes := range zeroHeightSkipPrefixes {
+		skipPrefixes[name] = append(skipPrefixes[name], prefixes...)
+	}
+	requireStoresEqual(t, app, ctxA, newApp, ctxB, skipPrefixes)
+
+	require.NoError(t, newApp.StakingKeeper.IterateValidators(ctxB, func(_ int64, val stakingtypes.ValidatorI) bool {
+		require.Equal(t, val.GetOperator() != allowed, val.IsJailed(), "validator %s", val.GetOperator())
+		return false
+	}))
+
+	// the leases history and misses of the old chain are not carried over
+	gs, err := newApp.LeasesKeeper.ExportGenesis(ctxB)
+	require.NoError(t, err)
+	require.Empty(t, gs.History)
+	require.Empty(t, gs.Misses)
+}
+
+func TestExportImportStreamed(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping export/import round-trip in short mode")
+	}
+
+	app := simulateBlocks(t, exportTestBlocks)
+	ctxA := app.NewContextLegacy(true, cmtproto.Header{Height: app.LastBlockHeight()})
+
+	exported, err := app.ExportAppStateAndValidators(false, nil, nil)
+	require.NoError(t, err)
+
+	// the streamed app state is the one built in memory
+	var buf bytes.Buffer
+	streamed, err := app.ExportAppStateTo(&buf, false, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, exported.Height, streamed.Height)
+	require.Equal(t, exported.Validators, streamed.Validators)
+	requireAppStatesEqual(t, exported.AppState, buf.Bytes())
+
+	dir := t.TempDir()
+	_, err = app.ExportAppStateToDir(dir, false, nil, nil)
+	require.NoError(t, err)
+
+	newApp, err := NewMiniApp(log.NewNopLogger(), dbm.NewMemDB(), nil, true, simAppOptions(t), baseapp.SetChainID(SimAppChainID))
+	require.NoError(t, err)
+
+	ctxB := newApp.NewContextLegacy(true, cmtproto.Header{Height: app.LastBlockHeight()})
+	_, err = newApp.StreamingInitChainer(dir)(ctxB, &abci.RequestInitChain{AppStateBytes: []byte("{}")})
+	require.NoError(t, err)
+	require.NoError(t, newApp.StoreConsensusParams(ctxB, exported.ConsensusParams))
+
+	requireStoresEqual(t, app, ctxA, newApp, ctxB, exportSkipPrefixes)
+
+	// the app state is read from either the genesis file or the directory, not both
+	_, err = newApp.StreamingInitChainer(dir)(ctxB, &abci.RequestInitChain{AppStateBytes: exported.AppState})
+	require.Error(t, err)
+}
+
+func TestExportImportStreamedLists(t *testing.T) {
+	app := Setup(t)
+
+	// more locks than a chunk, and accounts created in the reverse order of
+	// their addresses, each holding two denoms
+	ctx := app.NewUncachedContext(false, cmtproto.Header{Height: app.LastBlockHeight() + 1})
+	coins := sdk.NewCoins(sdk.NewInt64Coin(sdk.DefaultBondDenom, 1000), sdk.NewInt64Coin("other", 10))
+	for i := 2 * genesisChunkSize; i >= 0; i-- {
+		addr := sdk.AccAddress(fmt.Sprintf("addr%016d", i))
+		require.NoError(t, banktestutil.FundAccount(ctx, app.BankKeeper, addr, coins))
+
+		lock := envoy.Lock{Name: fmt.Sprintf("lock%d", i), Envoy: addr.String(), AtBlock: 1, NumBlocks: 100}
+		require.NoError(t, app.EnvoyKeeper.Locks.Set(ctx, lock.Name, lock))
+	}
+	require.NoError(t, app.LeasesKeeper.IndexLocks(ctx))
+	NextBlock(t, app)
+	ctxA := app.NewContextLegacy(true, cmtproto.Header{Height: app.LastBlockHeight()})
+
+	exported, err := app.ExportAppStateAndValidators(false, nil, nil)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	_, err = app.ExportAppStateTo(&buf, false, nil, nil)
+	require.NoError(t, err)
+	requireAppStatesEqual(t, exported.AppState, buf.Bytes())
+
+	dir := t.TempDir()
+	_, err = app.ExportAppStateToDir(dir, false, nil, nil)
+	require.NoError(t, err)
+
+	newApp, err := NewMiniApp(log.NewNopLogger(), dbm.NewMemDB(), nil, true, simAppOptions(t), baseapp.SetChainID(SimAppChainID))
+	require.NoError(t, err)
+
+	ctxB := newApp.NewContextLegacy(true, cmtproto.Header{Height: app.LastBlockHeight()})
+	_, err = newApp.StreamingInitChainer(dir)(ctxB, &abci.RequestInitChain{})
+	require.NoError(t, err)
+	require.NoError(t, newApp.StoreConsensusParams(ctxB, exported.ConsensusParams))
+
+	requireStoresEqual(t, app, ctxA, newApp, ctxB, exportSkipPrefixes)
+
+	// a supply not matching the balances is refused, as by the bank InitGenesis
+	bankFile := ModuleGenesisFile(dir, banktypes.ModuleName)
+	bz, err := os.ReadFile(bankFile)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(bankFile, bytes.Replace(bz, []byte(`"denom":"other","amount":"20010"`), []byte(`"denom":"other","amount":"1"`), 1), 0o600))
+
+	newApp, err = NewMiniApp(log.NewNopLogger(), dbm.NewMemDB(), nil, true, simAppOptions(t), baseapp.SetChainID(SimAppChainID))
+	require.NoError(t, err)
+	_, err = newApp.StreamingInitChainer(dir)(newApp.NewContextLegacy(true, cmtproto.Header{}), &abci.RequestInitChain{})
+	require.ErrorContains(t, err, "genesis supply is incorrect")
+}
+
+// requireAppStatesEqual requires a streamed app state to hold the genesis of
+// the modules exported in memory, the auth accounts being listed by account
+// number rather than by address.
+func requireAppStatesEqual(t *testing.T, expected, actual []byte) {
+	t.Helper()
+
+	var expectedModules, actualModules map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(expected, &expectedModules))
+	require.NoError(t, json.Unmarshal(actual, &actualModules))
+	require.Len(t, actualModules, len(expectedModules))
+
+	for moduleName, genesis := range expectedModules {
+		require.Contains(t, actualModules, moduleName)
+		if moduleName != authtypes.ModuleName {
+			require.JSONEq(t, string(genesis), string(actualModules[moduleName]), "module %s", moduleName)
+			continue
+		}
+
+		var expectedAuth, actualAuth map[string]any
+		require.NoError(t, json.Unmarshal(genesis, &expectedAuth))
+		require.NoError(t, json.Unmarshal(actualModules[moduleName], &actualAuth))
+		require.ElementsMatch(t, expectedAuth["accounts"], actualAuth["accounts"])
+		delete(expectedAuth, "accounts")
+		delete(actualAuth, "accounts")
+		require.Equal(t, expectedAuth, actualAuth)
+	}
+}
+
+// simulateBlocks returns an app on an in-memory database driven through
+// numBlocks blocks of random staking, bank, envoy and other module activity.
+func simulateBlocks(t *testing.T, numBlocks int) *MiniApp {
+	t.Helper()
+
+	config := simcli.NewConfigFromFlags()
+	config.ChainID = SimAppChainID
+	config.NumBlocks = numBlocks
+	config.BlockSize = 50
+	config.Commit = true
+	config.OnOperation = false
+	config.AllInvariants = false
+	config.ExportParamsPath = ""
+	config.ExportStatePath = ""
+	config.ExportStatsPath = ""
+
+	app, err := NewMiniApp(log.NewNopLogger(), dbm.NewMemDB(), nil, true, simAppOptions(t), baseapp.SetChainID(SimAppChainID))
+	require.NoError(t, err)
+
+	stopEarly, _, err := simulation.SimulateFromSeed(
+		t,
+		io.Discard,
+		app.BaseApp,
+		simtestutil.AppStateFn(app.AppCodec(), app.SimulationManager(), app.DefaultGenesis()),
+		simtypes.RandomAccounts,
+		simtestutil.SimulationOperations(app, app.AppCodec(), config),
+		app.BankKeeper.GetBlockedAddresses(),
+		config,
+		app.AppCodec(),
+	)
+	require.NoError(t, err)
+	if stopEarly {
+		t.Skip("simulation stopped early, there is no state to export")
+	}
+
+	return app
+}
+
+// importGenesis initializes a fresh app from the exported genesis and returns
+// it with a context on the imported state.
+func importGenesis(t *testing.T, exported servertypes.ExportedApp, height int64) (*MiniApp, sdk.Context) {
+	t.Helper()
+
+	app, err := NewMiniApp(log.NewNopLogger(), dbm.NewMemDB(), nil, true, simAppOptions(t), baseapp.SetChainID(SimAppChainID))
+	require.NoError(t, err)
+
+	var genesisState map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(exported.AppState, &genesisState))
+
+	ctx := app.NewContextLegacy(true, cmtproto.Header{Height: height})
+	_, err = app.ModuleManager.InitGenesis(ctx, app.AppCodec(), genesisState)
+	require.NoError(t, err)
+	require.NoError(t, app.StoreConsensusParams(ctx, exported.ConsensusParams))
+
+	return app, ctx
+}
+
+// requireStoresEqual compares every KV store of the two apps key by key, and
+// reports the mismatched keys by module store and key prefix.
+func requireStoresEqual(
+	t *testing.T,
+	appA *MiniApp,
+	ctxA sdk.Context,
+	appB *MiniApp,
+	ctxB sdk.Context,
+	skipPrefixes map[string][][]byte,
+) {
+	t.Helper()
+
+	storeKeys := appA.GetStoreKeys()
+	require.NotEmpty(t, storeKeys)
+
+	var report []string
+	for _, keyA := range storeKeys {
+		// only compare kvstores
+		if _, ok := keyA.(*storetypes.KVStoreKey); !ok {
+			continue
+		}
+
+		name := keyA.Name()
+		keyB := appB.GetKey(name)
+		require.NotNil(t, keyB, "store %s missing from imported app", name)
+
+		failedA, failedB := simtestutil.DiffKVStores(ctxA.KVStore(keyA), ctxB.KVStore(keyB), skipPrefixes[name])
+		if len(failedA) == 0 && len(failedB) == 0 {
+			continue
+		}
+
+		prefixes := make(map[string]int)
+		for _, pair := range append(failedA, failedB...) {
+			prefixes[fmt.Sprintf("0x%X", pair.Key[:1])]++
+		}
+
+		var counts []string
+		for prefix, n := range prefixes {
+			counts = append(counts, fmt.Sprintf("%s (%d)", prefix, n))
+		}
+		sort.Strings(counts)
+
+		report = append(report, fmt.Sprintf("%s: prefixes %s\n%s",
+			name, strings.Join(counts, ", "),
+			simtestutil.GetSimulationLog(name, appA.SimulationManager().StoreDecoders, failedA, failedB),
+		))
+	}
+
+	require.Empty(t, report, "mismatched stores after import:\n%s", strings.Join(report, "\n"))
+}
+
+func TestRebaseEnvoyLocks(t *testing.T) {
+	app := Setup(t)
+	ctx := app.NewContextLegacy(false, cmtproto.Header{Height: app.LastBlockHeight() + 1})
+
+	// leave out the locks of the lockable actions, leased at genesis
+	require.NoError(t, app.EnvoyKeeper.Locks.Clear(ctx, nil))
+
+	holder := sdk.AccAddress("holder______________").String()
+	locks := []envoy.Lock{
+		{Name: "expired", Envoy: holder, AtBlock: 10, NumBlocks: 5},
+		{Name: "ending", Envoy: holder, AtBlock: 90, NumBlocks: 11},
+		{Name: "active", Envoy: holder, AtBlock: 95, NumBlocks: 10},
+		{Name: "future", Envoy: holder, AtBlock: 120, NumBlocks: 12},
+		{Name: "endless", Envoy: holder, AtBlock: 1 << 63, NumBlocks: math.MaxUint64},
+	}
+	for _, lock := range locks {
+		require.NoError(t, app.EnvoyKeeper.Locks.Set(ctx, lock.Name, lock))
+	}
+
+	// export at height 100, the new chain starts at 1 in place of 101
+	report, err := app.rebaseEnvoyLocks(ctx, 100)
+	require.NoError(t, err)
+	require.Equal(t, []string{"ending", "expired"}, sortedStrings(report.Dropped))
+	require.Len(t, report.Rebased, 3)
+
+	active, err := app.EnvoyKeeper.Locks.Get(ctx, "active")
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), active.AtBlock)
+	require.Equal(t, uint64(4), active.NumBlocks)
+
+	future, err := app.EnvoyKeeper.Locks.Get(ctx, "future")
+	require.NoError(t, err)
+	require.Equal(t, uint64(20), future.AtBlock)
+	require.Equal(t, uint64(12), future.NumBlocks)
+
+	// the end of a lock past the last height saturates
+	endless, err := app.EnvoyKeeper.Locks.Get(ctx, "endless")
+	require.NoError(t, err)
+	require.Equal(t, uint64(1<<63-100), endless.AtBlock)
+	require.Equal(t, uint64(math.MaxUint64-1<<63), endless.NumBlocks)
+
+	for _, name := range report.Dropped {
+		has, err := app.EnvoyKeeper.Locks.Has(ctx, name)
+		require.NoError(t, err)
+		require.False(t, has, name)
+	}
+}
+
+func TestClearLeases(t *testing.T) {
+	app := Setup(t)
+	ctx := app.NewContextLegacy(false, cmtproto.Header{Height: app.LastBlockHeight() + 1})
+
+	holder := sdk.AccAddress("holder______________").String()
+	for expiry := uint64(6); expiry <= 8; expiry++ {
+		lock := envoy.Lock{Name: "lock1", Envoy: holder, AtBlock: expiry - 5, NumBlocks: 5}
+		require.NoError(t, app.LeasesKeeper.History.Set(ctx, collections.Join(lock.Name, expiry), lock))
+	}
+	require.NoError(t, app.LeasesKeeper.Misses.Set(ctx, "lock1", 3))
+
+	// the leases expired at the heights of the old chain, and the misses are reset
+	var report EnvoyLockRebase
+	require.NoError(t, app.clearLeases(ctx, &report))
+	require.Equal(t, 3, report.ClearedHistory)
+	require.Equal(t, 1, report.ClearedMisses)
+
+	history, err := app.LeasesKeeper.LockHistory(ctx, "lock1")
+	require.NoError(t, err)
+	require.Empty(t, history)
+
+	has, err := app.LeasesKeeper.Misses.Has(ctx, "lock1")
+	require.NoError(t, err)
+	require.False(t, has)
+}
+
+func sortedStrings(s []string) []string {
+	sorted := append([]string(nil), s...)
+	sort.Strings(sorted)
+	return sorted
+}
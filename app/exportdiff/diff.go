@@ -0,0 +1,333 @@
+package exportdiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// ChangeKind is the kind of a change between two exports.
+type ChangeKind string
+
+const (
+	Added    ChangeKind = "added"
+	Removed  ChangeKind = "removed"
+	Modified ChangeKind = "modified"
+)
+
+// identityKeys are the fields identifying the items of a list, tried in
+// order, so that the items are matched by identity rather than by position
+// and an insertion does not show as a change of every following item.
+var identityKeys = []string{"address", "name", "operator_address", "validator_address", "denom", "id"}
+
+// Change is a change of the value at a path of a module genesis. Items of
+// lists are addressed by index, or by identity as in balances[address=...].
+type Change struct {
+	Path string          `json:"path"`
+	Kind ChangeKind      `json:"kind"`
+	Old  json.RawMessage `json:"old,omitempty"`
+	New  json.RawMessage `json:"new,omitempty"`
+}
+
+// ModuleDiff is the diff of the genesis of a module.
+type ModuleDiff struct {
+	Module  string     `json:"module"`
+	Kind    ChangeKind `json:"kind"`
+	OldHash string     `json:"old_sha256,omitempty"`
+	NewHash string     `json:"new_sha256,omitempty"`
+	Changes []Change   `json:"changes,omitempty"`
+}
+
+// Diff returns the diffs of the modules of b from a, sorted by module. The
+// modules with the same hash are not read.
+func Diff(a, b Snapshot) ([]ModuleDiff, error) {
+	names := a.Modules()
+	for _, name := range b.Modules() {
+		if _, ok := a.Manifest.Modules[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var diffs []ModuleDiff
+	for _, name := range names {
+		digestA, inA := a.Manifest.Modules[name]
+		digestB, inB := b.Manifest.Modules[name]
+
+		switch {
+		case !inB:
+			diffs = append(diffs, ModuleDiff{Module: name, Kind: Removed, OldHash: digestA.SHA256})
+		case !inA:
+			diffs = append(diffs, ModuleDiff{Module: name, Kind: Added, NewHash: digestB.SHA256})
+		case digestA.SHA256 != digestB.SHA256:
+			bzA, err := a.Module(name)
+			if err != nil {
+				return nil, err
+			}
+
+			bzB, err := b.Module(name)
+			if err != nil {
+				return nil, err
+			}
+
+			changes, err := DiffJSON(bzA, bzB)
+			if err != nil {
+				return nil, fmt.Errorf("failed to diff module %s: %w", name, err)
+			}
+
+			diffs = append(diffs, ModuleDiff{
+				Module:  name,
+				Kind:    Modified,
+				OldHash: digestA.SHA256,
+				NewHash: digestB.SHA256,
+				Changes: changes,
+			})
+		}
+	}
+
+	return diffs, nil
+}
+
+// DiffJSON returns the changes of the JSON document b from a, by path.
+func DiffJSON(a, b []byte) ([]Change, error) {
+	va, err := decode(a)
+	if err != nil {
+		return nil, err
+	}
+
+	vb, err := decode(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	if err := diffValues("", va, vb, &changes); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+func diffValues(path string, a, b any, changes *[]Change) error {
+	switch va := a.(type) {
+	case map[string]any:
+		if vb, ok := b.(map[string]any); ok {
+			return diffObjects(path, va, vb, changes)
+		}
+	case []any:
+		if vb, ok := b.([]any); ok {
+			return diffLists(path, va, vb, changes)
+		}
+	}
+
+	if reflect.DeepEqual(a, b) {
+		return nil
+	}
+
+	return addChange(changes, path, Modified, a, b)
+}
+
+func diffObjects(path string, a, b map[string]any, changes *[]Change) error {
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		va, inA := a[key]
+		vb, inB := b[key]
+		keyPath := joinPath(path, key)
+
+		var err error
+		switch {
+		case !inB:
+			err = addChange(changes, keyPath, Removed, va, nil)
+		case !inA:
+			err = addChange(changes, keyPath, Added, nil, vb)
+		default:
+			err = diffValues(keyPath, va, vb, changes)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func diffLists(path string, a, b []any, changes *[]Change) error {
+	if key, ok := identityKey(a, b); ok {
+		return diffListsByIdentity(path, key, a, b, changes)
+	}
+
+	for i := 0; i < max(len(a), len(b)); i++ {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+
+		var err error
+		switch {
+		case i >= len(b):
+			err = addChange(changes, itemPath, Removed, a[i], nil)
+		case i >= len(a):
+			err = addChange(changes, itemPath, Added, nil, b[i])
+		default:
+			err = diffValues(itemPath, a[i], b[i], changes)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func diffListsByIdentity(path, key string, a, b []any, changes *[]Change) error {
+	itemsA := indexBy(key, a)
+	itemsB := indexBy(key, b)
+
+	ids := make([]string, 0, len(itemsA)+len(itemsB))
+	for id := range itemsA {
+		ids = append(ids, id)
+	}
+	for id := range itemsB {
+		if _, ok := itemsA[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		va, inA := itemsA[id]
+		vb, inB := itemsB[id]
+		itemPath := fmt.Sprintf("%s[%s=%s]", path, key, id)
+
+		var err error
+		switch {
+		case !inB:
+			err = addChange(changes, itemPath, Removed, va, nil)
+		case !inA:
+			err = addChange(changes, itemPath, Added, nil, vb)
+		default:
+			err = diffValues(itemPath, va, vb, changes)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// identityKey returns the first identity key which every item of both lists
+// has as a unique string.
+func identityKey(a, b []any) (string, bool) {
+	if len(a) == 0 && len(b) == 0 {
+		return "", false
+	}
+
+	for _, key := range identityKeys {
+		if uniqueStrings(key, a) && uniqueStrings(key, b) {
+			return key, true
+		}
+	}
+
+	return "", false
+}
+
+func uniqueStrings(key string, items []any) bool {
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			return false
+		}
+
+		id, ok := obj[key].(string)
+		if !ok || seen[id] {
+			return false
+		}
+		seen[id] = true
+	}
+
+	return true
+}
+
+func indexBy(key string, items []any) map[string]any {
+	index := make(map[string]any, len(items))
+	for _, item := range items {
+		index[item.(map[string]any)[key].(string)] = item
+	}
+
+	return index
+}
+
+func addChange(changes *[]Change, path string, kind ChangeKind, a, b any) error {
+	change := Change{Path: path, Kind: kind}
+
+	var err error
+	if a != nil {
+		if change.Old, err = json.Marshal(a); err != nil {
+			return err
+		}
+	}
+	if b != nil {
+		if change.New, err = json.Marshal(b); err != nil {
+			return err
+		}
+	}
+
+	*changes = append(*changes, change)
+	return nil
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+// WriteText writes the diffs as one line per module and per change.
+func WriteText(w io.Writer, diffs []ModuleDiff) error {
+	for _, diff := range diffs {
+		var hashes string
+		switch diff.Kind {
+		case Added:
+			hashes = diff.NewHash
+		case Removed:
+			hashes = diff.OldHash
+		default:
+			hashes = fmt.Sprintf("%s -> %s, %d changes", diff.OldHash, diff.NewHash, len(diff.Changes))
+		}
+
+		if _, err := fmt.Fprintf(w, "%s: %s (%s)\n", diff.Module, diff.Kind, hashes); err != nil {
+			return err
+		}
+
+		for _, change := range diff.Changes {
+			var line string
+			switch change.Kind {
+			case Added:
+				line = fmt.Sprintf("  + %s: %s\n", change.Path, change.New)
+			case Removed:
+				line = fmt.Sprintf("  - %s: %s\n", change.Path, change.Old)
+			default:
+				line = fmt.Sprintf("  ~ %s: %s -> %s\n", change.Path, change.Old, change.New)
+			}
+
+			if _, err := io.WriteString(w, line); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package exportdiff
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalize(t *testing.T) {
+	a, err := Canonicalize([]byte(`{"b": 1, "a": {"d": [1, 2], "c": 18446744073709551615}}`))
+	require.NoError(t, err)
+	require.Equal(t, `{"a":{"c":18446744073709551615,"d":[1,2]},"b":1}`, string(a))
+
+	b, err := Canonicalize([]byte(`{"a":{"d":[1,2],"c":18446744073709551615},"b":1}`))
+	require.NoError(t, err)
+	require.Equal(t, Digest(a), Digest(b))
+
+	_, err = Canonicalize([]byte(`{"a": 1} {"b": 2}`))
+	require.Error(t, err)
+}
+
+func TestDiffJSON(t *testing.T) {
+	a := `{
+		"params": {"max": "10", "min": "1"},
+		"balances": [{"address": "a1", "coins": "5"}, {"address": "a2", "coins": "7"}],
+		"heights": [1, 2, 3],
+		"removed": true
+	}`
+	b := `{
+		"params": {"max": "20", "min": "1"},
+		"balances": [{"address": "a0", "coins": "1"}, {"address": "a2", "coins": "8"}],
+		"heights": [1, 5],
+		"added": "x"
+	}`
+
+	changes, err := DiffJSON([]byte(a), []byte(b))
+	require.NoError(t, err)
+
+	raw := func(s string) json.RawMessage { return json.RawMessage(s) }
+	require.Equal(t, []Change{
+		{Path: "added", Kind: Added, New: raw(`"x"`)},
+		{Path: "balances[address=a0]", Kind: Added, New: raw(`{"address":"a0","coins":"1"}`)},
+		{Path: "balances[address=a1]", Kind: Removed, Old: raw(`{"address":"a1","coins":"5"}`)},
+		{Path: "balances[address=a2].coins", Kind: Modified, Old: raw(`"7"`), New: raw(`"8"`)},
+		{Path: "heights[1]", Kind: Modified, Old: raw(`2`), New: raw(`5`)},
+		{Path: "heights[2]", Kind: Removed, Old: raw(`3`)},
+		{Path: "params.max", Kind: Modified, Old: raw(`"10"`), New: raw(`"20"`)},
+		{Path: "removed", Kind: Removed, Old: raw(`true`)},
+	}, changes)
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	write := func(dir string, modules map[string]string) Snapshot {
+		w, err := NewWriter(dir)
+		require.NoError(t, err)
+		for name, genesis := range modules {
+			require.NoError(t, w.WriteModule(name, json.RawMessage(genesis)))
+		}
+		require.NoError(t, w.Close(10))
+
+		snapshot, err := Open(dir)
+		require.NoError(t, err)
+		require.Equal(t, int64(10), snapshot.Manifest.Height)
+		return snapshot
+	}
+
+	a := write(t.TempDir(), map[string]string{
+		"auth":  `{"accounts": []}`,
+		"bank":  `{"supply": "10"}`,
+		"envoy": `{"locks": []}`,
+	})
+	b := write(t.TempDir(), map[string]string{
+		"auth": `{ "accounts" : [] }`,
+		"bank": `{"supply": "12"}`,
+		"gov":  `{}`,
+	})
+
+	diffs, err := Diff(a, b)
+	require.NoError(t, err)
+	require.Len(t, diffs, 3)
+
+	require.Equal(t, "bank", diffs[0].Module)
+	require.Equal(t, Modified, diffs[0].Kind)
+	require.Equal(t, []Change{{Path: "supply", Kind: Modified, Old: json.RawMessage(`"10"`), New: json.RawMessage(`"12"`)}}, diffs[0].Changes)
+
+	require.Equal(t, ModuleDiff{Module: "envoy", Kind: Removed, OldHash: a.Manifest.Modules["envoy"].SHA256}, diffs[1])
+	require.Equal(t, ModuleDiff{Module: "gov", Kind: Added, NewHash: b.Manifest.Modules["gov"].SHA256}, diffs[2])
+}
@@ -0,0 +1,212 @@
+// Package exportdiff writes state exports as per-module canonical JSON with a
+// content hash per module, and diffs two such exports module by module and
+// key by key.
+package exportdiff
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	genutiltypes "github.com/cosmos/cosmos-sdk/x/genutil/types"
+)
+
+// ManifestFile is the file of a snapshot directory listing its modules.
+const ManifestFile = "manifest.json"
+
+// Manifest lists the modules of a snapshot with the hash of their canonical genesis.
+type Manifest struct {
+	Height  int64                   `json:"height"`
+	Modules map[string]ModuleDigest `json:"modules"`
+}
+
+// ModuleDigest is the file of a module genesis in a snapshot directory, and
+// the hex encoded SHA-256 of its canonical JSON.
+type ModuleDigest struct {
+	File   string `json:"file"`
+	SHA256 string `json:"sha256"`
+}
+
+// Canonicalize returns the canonical form of a JSON document: object keys
+// sorted, no insignificant whitespace, and numbers kept as written.
+func Canonicalize(bz []byte) ([]byte, error) {
+	v, err := decode(bz)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(v)
+}
+
+// Digest returns the hex encoded SHA-256 of a canonical JSON document.
+func Digest(canonical []byte) string {
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:])
+}
+
+// decode decodes a JSON document into maps, slices and json.Number values,
+// which encoding/json marshals back with sorted keys.
+func decode(bz []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(bz))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	if dec.More() {
+		return nil, errors.New("unexpected data after the JSON document")
+	}
+
+	return v, nil
+}
+
+// Writer writes the modules of a snapshot to a directory.
+type Writer struct {
+	dir      string
+	manifest Manifest
+}
+
+// NewWriter returns a writer of a snapshot in dir, which is created if needed.
+func NewWriter(dir string) (*Writer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &Writer{
+		dir:      dir,
+		manifest: Manifest{Modules: make(map[string]ModuleDigest)},
+	}, nil
+}
+
+// WriteModule writes the canonical genesis of a module and records its hash.
+func (w *Writer) WriteModule(moduleName string, bz json.RawMessage) error {
+	canonical, err := Canonicalize(bz)
+	if err != nil {
+		return fmt.Errorf("failed to canonicalize genesis state of module %s: %w", moduleName, err)
+	}
+
+	digest := ModuleDigest{File: moduleName + ".json", SHA256: Digest(canonical)}
+	if err := os.WriteFile(filepath.Join(w.dir, digest.File), canonical, 0o600); err != nil {
+		return err
+	}
+
+	w.manifest.Modules[moduleName] = digest
+	return nil
+}
+
+// Close writes the manifest of the snapshot, for the export at height.
+func (w *Writer) Close(height int64) error {
+	w.manifest.Height = height
+
+	bz, err := json.MarshalIndent(w.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(w.dir, ManifestFile), bz, 0o600)
+}
+
+// Snapshot is an export whose modules are read one at a time.
+type Snapshot struct {
+	Manifest Manifest
+
+	read func(moduleName string) ([]byte, error)
+}
+
+// Modules returns the names of the modules of the snapshot, sorted.
+func (s Snapshot) Modules() []string {
+	names := make([]string, 0, len(s.Manifest.Modules))
+	for name := range s.Manifest.Modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Module returns the canonical genesis of a module of the snapshot.
+func (s Snapshot) Module(moduleName string) ([]byte, error) {
+	if _, ok := s.Manifest.Modules[moduleName]; !ok {
+		return nil, fmt.Errorf("module %s is not in the snapshot", moduleName)
+	}
+
+	return s.read(moduleName)
+}
+
+// OpenSnapshot opens the snapshot written to dir.
+func OpenSnapshot(dir string) (Snapshot, error) {
+	bz, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(bz, &manifest); err != nil {
+		return Snapshot{}, fmt.Errorf("failed to decode snapshot manifest: %w", err)
+	}
+
+	return Snapshot{
+		Manifest: manifest,
+		read: func(moduleName string) ([]byte, error) {
+			return os.ReadFile(filepath.Join(dir, manifest.Modules[moduleName].File))
+		},
+	}, nil
+}
+
+// LoadGenesisFile returns the app state of a genesis file as a snapshot. The
+// whole app state is held in memory, unlike with a snapshot directory.
+func LoadGenesisFile(path string) (Snapshot, error) {
+	appGenesis, err := genutiltypes.AppGenesisFromFile(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	var appState map[string]json.RawMessage
+	if err := json.Unmarshal(appGenesis.AppState, &appState); err != nil {
+		return Snapshot{}, fmt.Errorf("failed to decode app state: %w", err)
+	}
+
+	manifest := Manifest{
+		Height:  appGenesis.InitialHeight,
+		Modules: make(map[string]ModuleDigest, len(appState)),
+	}
+	canonicals := make(map[string][]byte, len(appState))
+	for moduleName, bz := range appState {
+		canonical, err := Canonicalize(bz)
+		if err != nil {
+			return Snapshot{}, fmt.Errorf("failed to canonicalize genesis state of module %s: %w", moduleName, err)
+		}
+
+		canonicals[moduleName] = canonical
+		manifest.Modules[moduleName] = ModuleDigest{SHA256: Digest(canonical)}
+	}
+
+	return Snapshot{
+		Manifest: manifest,
+		read: func(moduleName string) ([]byte, error) {
+			return canonicals[moduleName], nil
+		},
+	}, nil
+}
+
+// Open opens a snapshot directory, or loads a genesis file.
+func Open(path string) (Snapshot, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	if info.IsDir() {
+		return OpenSnapshot(path)
+	}
+
+	return LoadGenesisFile(path)
+}
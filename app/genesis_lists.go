@@ -0,0 +1,433 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"cosmossdk.io/collections"
+	"cosmossdk.io/math"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/query"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+
+	"github.com/polygon/envoy"
+)
+
+// genesisChunkSize is the number of entries of a streamed list handed at once
+// to the InitGenesis of a module which is only initialized from its genesis.
+const genesisChunkSize = 1000
+
+// A streamedList is the list of a module genesis holding the bulk of the
+// module state. The streaming export writes it one entry at a time as it
+// walks the store, and InitChain reads it back one entry at a time, so the
+// genesis of the module is never held in memory in full.
+type streamedList struct {
+	// field is the JSON name of the list in the genesis of the module.
+	field string
+
+	// genesis returns the genesis of the module without the list.
+	genesis func(ctx sdk.Context) (json.RawMessage, error)
+
+	// walk calls fn with each entry of the list, in the order init expects.
+	walk func(ctx sdk.Context, fn func(entry json.RawMessage) error) error
+
+	// init initializes the module from its genesis without the list and the
+	// entries of the list, which walk hands to fn in order.
+	init func(ctx sdk.Context, genesis json.RawMessage, walk func(fn func(entry json.RawMessage) error) error) error
+}
+
+// streamedLists returns the streamed lists of the modules, by module name.
+func (app *MiniApp) streamedLists() map[string]streamedList {
+	return map[string]streamedList{
+		authtypes.ModuleName: app.authAccounts(),
+		banktypes.ModuleName: app.bankBalances(),
+		envoy.ModuleName:     app.envoyLocks(),
+	}
+}
+
+// bankBalances returns the balances of the bank genesis as a streamed list.
+func (app *MiniApp) bankBalances() streamedList {
+	return streamedList{
+		field: "balances",
+		genesis: func(ctx sdk.Context) (json.RawMessage, error) {
+			supply, _, err := app.BankKeeper.GetPaginatedTotalSupply(ctx, &query.PageRequest{Limit: query.PaginationMaxLimit})
+			if err != nil {
+				return nil, err
+			}
+
+			return app.appCodec.MarshalJSON(banktypes.NewGenesisState(
+				app.BankKeeper.GetParams(ctx),
+				nil,
+				supply,
+				app.BankKeeper.GetAllDenomMetaData(ctx),
+				app.BankKeeper.GetAllSendEnabledEntries(ctx),
+			))
+		},
+		walk: func(ctx sdk.Context, fn func(json.RawMessage) error) error {
+			var (
+				balance banktypes.Balance
+				walked  bool
+			)
+			flush := func() error {
+				if !walked {
+					return nil
+				}
+
+				bz, err := app.appCodec.MarshalJSON(&balance)
+				if err != nil {
+					return err
+				}
+				return fn(bz)
+			}
+
+			// the balances of an account are walked in a row, by denom
+			err := app.BankKeeper.Balances.Walk(ctx, nil, func(key collections.Pair[sdk.AccAddress, string], amount math.Int) (bool, error) {
+				if addr := key.K1().String(); !walked || addr != balance.Address {
+					if err := flush(); err != nil {
+						return true, err
+					}
+					balance = banktypes.Balance{Address: addr}
+					walked = true
+				}
+
+				balance.Coins = append(balance.Coins, sdk.Coin{Denom: key.K2(), Amount: amount})
+				return false, nil
+			})
+			if err != nil {
+				return err
+			}
+
+			return flush()
+		},
+		init: func(ctx sdk.Context, genesis json.RawMessage, walk func(func(json.RawMessage) error) error) error {
+			var genState banktypes.GenesisState
+			if err := app.appCodec.UnmarshalJSON(genesis, &genState); err != nil {
+				return err
+			}
+
+			// set the balances and sum them up as the bank InitGenesis does
+			supply := sdk.NewMapCoins(sdk.Coins{})
+			err := walk(func(bz json.RawMessage) error {
+				var balance banktypes.Balance
+				if err := app.appCodec.UnmarshalJSON(bz, &balance); err != nil {
+					return err
+				}
+
+				addr, err := app.AccountKeeper.AddressCodec().StringToBytes(balance.Address)
+				if err != nil {
+					return err
+				}
+
+				for _, coin := range balance.Coins {
+					if err := app.BankKeeper.Balances.Set(ctx, collections.Join(sdk.AccAddress(addr), coin.Denom), coin.Amount); err != nil {
+						return err
+					}
+				}
+
+				supply.Add(balance.Coins...)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			totalSupply := supply.ToCoins()
+			if !genState.Supply.Empty() && !genState.Supply.Equal(totalSupply) {
+				return fmt.Errorf("genesis supply is incorrect, expected %v, got %v", genState.Supply, totalSupply)
+			}
+
+			// without balances, InitGenesis sets all but the supply
+			genState.Supply = nil
+			app.BankKeeper.InitGenesis(ctx, &genState)
+
+			for _, coin := range totalSupply {
+				if err := app.BankKeeper.Supply.Set(ctx, coin.Denom, coin.Amount); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+}
+
+// authAccounts returns the accounts of the auth genesis as a streamed list,
+// ordered by account number.
+func (app *MiniApp) authAccounts() streamedList {
+	return streamedList{
+		field: "accounts",
+		genesis: func(ctx sdk.Context) (json.RawMessage, error) {
+			return app.appCodec.MarshalJSON(authtypes.NewGenesisState(app.AccountKeeper.GetParams(ctx), nil))
+		},
+		walk: func(ctx sdk.Context, fn func(json.RawMessage) error) error {
+			iter, err := app.AccountKeeper.Accounts.Indexes.Number.Iterate(ctx, nil)
+			if err != nil {
+				return err
+			}
+			defer iter.Close()
+
+			for ; iter.Valid(); iter.Next() {
+				addr, err := iter.PrimaryKey()
+				if err != nil {
+					return err
+				}
+
+				acc, err := app.AccountKeeper.Accounts.Get(ctx, addr)
+				if err != nil {
+					return err
+				}
+
+				bz, err := app.appCodec.MarshalInterfaceJSON(acc)
+				if err != nil {
+					return err
+				}
+
+				if err := fn(bz); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+		init: func(ctx sdk.Context, genesis json.RawMessage, walk func(func(json.RawMessage) error) error) error {
+			var genState authtypes.GenesisState
+			if err := app.appCodec.UnmarshalJSON(genesis, &genState); err != nil {
+				return err
+			}
+
+			if err := app.AccountKeeper.Params.Set(ctx, genState.Params); err != nil {
+				return err
+			}
+
+			// set the accounts as the auth InitGenesis does, which first sorts
+			// them by account number
+			var lastAccNum *uint64
+			err := walk(func(bz json.RawMessage) error {
+				var acc sdk.AccountI
+				if err := app.appCodec.UnmarshalInterfaceJSON(bz, &acc); err != nil {
+					return err
+				}
+
+				accNum := acc.GetAccountNumber()
+				if lastAccNum != nil && *lastAccNum >= accNum {
+					return fmt.Errorf("account %s is not listed by account number", acc.GetAddress())
+				}
+
+				for lastAccNum == nil || *lastAccNum < accNum {
+					n := app.AccountKeeper.NextAccountNumber(ctx)
+					lastAccNum = &n
+				}
+
+				app.AccountKeeper.SetAccount(ctx, acc)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			app.AccountKeeper.GetModuleAccount(ctx, authtypes.FeeCollectorName)
+			return nil
+		},
+	}
+}
+
+// envoyLocks returns the locks of the envoy genesis as a streamed list. They
+// are initialized by the envoy InitGenesis, a chunk of locks at a time.
+func (app *MiniApp) envoyLocks() streamedList {
+	return streamedList{
+		field: "locks",
+		genesis: func(ctx sdk.Context) (json.RawMessage, error) {
+			params, err := app.EnvoyKeeper.Params.Get(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			return app.appCodec.MarshalJSON(&envoy.GenesisState{Params: params})
+		},
+		walk: func(ctx sdk.Context, fn func(json.RawMessage) error) error {
+			return app.EnvoyKeeper.Locks.Walk(ctx, nil, func(_ string, lock envoy.Lock) (bool, error) {
+				bz, err := app.appCodec.MarshalJSON(&lock)
+				if err != nil {
+					return true, err
+				}
+				return false, fn(bz)
+			})
+		},
+		init: func(ctx sdk.Context, genesis json.RawMessage, walk func(func(json.RawMessage) error) error) error {
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(genesis, &fields); err != nil {
+				return err
+			}
+
+			chunk := make([]json.RawMessage, 0, genesisChunkSize)
+			initChunk := func() error {
+				locks, err := json.Marshal(chunk)
+				if err != nil {
+					return err
+				}
+				fields["locks"] = locks
+
+				bz, err := json.Marshal(fields)
+				if err != nil {
+					return err
+				}
+
+				chunk = chunk[:0]
+				_, err = app.initModuleGenesis(ctx, envoy.ModuleName, bz)
+				return err
+			}
+
+			err := walk(func(bz json.RawMessage) error {
+				chunk = append(chunk, bz)
+				if len(chunk) < genesisChunkSize {
+					return nil
+				}
+				return initChunk()
+			})
+			if err != nil {
+				return err
+			}
+
+			// the last chunk, which also initializes a genesis without locks
+			return initChunk()
+		},
+	}
+}
+
+// writeStreamedGenesis writes the genesis of a module to w: the fields of
+// genesis other than the streamed list, then the list one entry at a time.
+func writeStreamedGenesis(w io.Writer, genesis json.RawMessage, field string, walk func(fn func(json.RawMessage) error) error) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(genesis, &fields); err != nil {
+		return err
+	}
+	delete(fields, field)
+
+	bz, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+
+	key, err := json.Marshal(field)
+	if err != nil {
+		return err
+	}
+
+	// the list is the last field of the object
+	bz = bytes.TrimSuffix(bz, []byte("}"))
+	if len(fields) > 0 {
+		bz = append(bz, ',')
+	}
+	if _, err := fmt.Fprintf(w, "%s%s:[", bz, key); err != nil {
+		return err
+	}
+
+	first := true
+	err = walk(func(entry json.RawMessage) error {
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+
+		_, err := w.Write(entry)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "]}")
+	return err
+}
+
+// decodeStreamedGenesis reads the genesis of a module from r and returns its
+// fields other than the streamed list, handing the entries of the list to fn
+// one at a time. The entries are skipped when fn is nil.
+func decodeStreamedGenesis(r io.Reader, field string, fn func(json.RawMessage) error) (json.RawMessage, error) {
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]json.RawMessage)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		key, ok := tok.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected %v in place of a field name", tok)
+		}
+
+		if key != field {
+			var value json.RawMessage
+			if err := dec.Decode(&value); err != nil {
+				return nil, err
+			}
+			fields[key] = value
+			continue
+		}
+
+		tok, err = dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		// a null list has no entries
+		if tok == nil {
+			continue
+		}
+		if tok != json.Delim('[') {
+			return nil, fmt.Errorf("unexpected %v in place of the %s list", tok, field)
+		}
+
+		for dec.More() {
+			var entry json.RawMessage
+			if err := dec.Decode(&entry); err != nil {
+				return nil, err
+			}
+
+			if fn == nil {
+				continue
+			}
+			if err := fn(entry); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := expectDelim(dec, ']'); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(fields)
+}
+
+// expectDelim reads the next token of dec, which must be delim.
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	} else if err != nil {
+		return err
+	}
+
+	if tok != delim {
+		return fmt.Errorf("unexpected %v in place of %v", tok, delim)
+	}
+
+	return nil
+}
@@ -0,0 +1,343 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"cosmossdk.io/core/appmodule"
+	"cosmossdk.io/core/genesis"
+	abci "github.com/cometbft/cometbft/abci/types"
+	"github.com/spf13/cast"
+
+	"github.com/cosmos/cosmos-sdk/client/flags"
+	servertypes "github.com/cosmos/cosmos-sdk/server/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/module"
+	"github.com/cosmos/cosmos-sdk/x/staking"
+
+	"github.com/polygon/procyon/app/exportdiff"
+)
+
+// FlagGenesisDir is the directory holding one genesis file per module, which
+// is written to by a streaming export and read from by InitChain.
+const FlagGenesisDir = "genesis-dir"
+
+// GenesisDocFile is the genesis file written along the module files of a
+// streaming export. Its app state is empty, the modules read theirs from
+// the genesis directory.
+const GenesisDocFile = "genesis.json"
+
+// ModuleGenesisFile returns the genesis file of a module in a genesis directory.
+func ModuleGenesisFile(dir, moduleName string) string {
+	return filepath.Join(dir, moduleName+".json")
+}
+
+// ExportAppStateTo exports the state of the application like
+// ExportAppStateAndValidators, but writes the app state to w one module at a
+// time instead of returning it. The returned ExportedApp has no AppState.
+func (app *MiniApp) ExportAppStateTo(
+	w io.Writer,
+	forZeroHeight bool,
+	jailAllowedAddrs []string,
+	modulesToExport []string,
+) (servertypes.ExportedApp, error) {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString("{"); err != nil {
+		return servertypes.ExportedApp{}, err
+	}
+
+	first := true
+	exported, err := app.exportGenesisStream(forZeroHeight, jailAllowedAddrs, modulesToExport, func(moduleName string, genesis func(io.Writer) error) error {
+		if !first {
+			if _, err := bw.WriteString(","); err != nil {
+				return err
+			}
+		}
+		first = false
+
+		key, err := json.Marshal(moduleName)
+		if err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintf(bw, "\n  %s: ", key); err != nil {
+			return err
+		}
+
+		if err := genesis(bw); err != nil {
+			return err
+		}
+
+		return bw.Flush()
+	})
+	if err != nil {
+		return servertypes.ExportedApp{}, err
+	}
+
+	if _, err := bw.WriteString("\n}\n"); err != nil {
+		return servertypes.ExportedApp{}, err
+	}
+
+	return exported, bw.Flush()
+}
+
+// ExportAppStateToDir exports the state of the application like
+// ExportAppStateAndValidators, but writes the genesis of each module to its
+// own file in dir as it is produced. The returned ExportedApp has no AppState.
+func (app *MiniApp) ExportAppStateToDir(
+	dir string,
+	forZeroHeight bool,
+	jailAllowedAddrs []string,
+	modulesToExport []string,
+) (servertypes.ExportedApp, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return servertypes.ExportedApp{}, err
+	}
+
+	return app.exportGenesisStream(forZeroHeight, jailAllowedAddrs, modulesToExport, func(moduleName string, genesis func(io.Writer) error) error {
+		f, err := os.OpenFile(ModuleGenesisFile(dir, moduleName), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+		if err != nil {
+			return err
+		}
+
+		bw := bufio.NewWriter(f)
+		if err := genesis(bw); err != nil {
+			f.Close()
+			return err
+		}
+
+		if err := bw.Flush(); err != nil {
+			f.Close()
+			return err
+		}
+
+		return f.Close()
+	})
+}
+
+// ExportSnapshot exports the state of the application like ExportAppStateToDir,
+// but writes the genesis of each module as canonical JSON and a manifest of
+// the modules with their hashes, for exports to be compared with exportdiff.
+// Each module is canonicalized in memory.
+func (app *MiniApp) ExportSnapshot(
+	dir string,
+	forZeroHeight bool,
+	jailAllowedAddrs []string,
+	modulesToExport []string,
+) (servertypes.ExportedApp, error) {
+	w, err := exportdiff.NewWriter(dir)
+	if err != nil {
+		return servertypes.ExportedApp{}, err
+	}
+
+	exported, err := app.exportGenesisStream(forZeroHeight, jailAllowedAddrs, modulesToExport, func(moduleName string, genesis func(io.Writer) error) error {
+		var buf bytes.Buffer
+		if err := genesis(&buf); err != nil {
+			return err
+		}
+
+		return w.WriteModule(moduleName, buf.Bytes())
+	})
+	if err != nil {
+		return servertypes.ExportedApp{}, err
+	}
+
+	return exported, w.Close(exported.Height)
+}
+
+// exportGenesisStream exports the genesis of the modules one at a time, in
+// the export order, and hands write a function writing the genesis of each
+// before exporting the next one. The streamed lists of the modules are
+// written one entry at a time as they are walked.
+func (app *MiniApp) exportGenesisStream(
+	forZeroHeight bool,
+	jailAllowedAddrs []string,
+	modulesToExport []string,
+	write func(moduleName string, genesis func(io.Writer) error) error,
+) (servertypes.ExportedApp, error) {
+	ctx, height, err := app.exportContext(forZeroHeight, jailAllowedAddrs)
+	if err != nil {
+		return servertypes.ExportedApp{}, err
+	}
+
+	exportSet := make(map[string]bool, len(modulesToExport))
+	for _, moduleName := range modulesToExport {
+		if _, ok := app.ModuleManager.Modules[moduleName]; !ok {
+			return servertypes.ExportedApp{}, fmt.Errorf("module %s does not exist", moduleName)
+		}
+		exportSet[moduleName] = true
+	}
+
+	streamedLists := app.streamedLists()
+	for _, moduleName := range app.ModuleManager.OrderExportGenesis {
+		if len(exportSet) > 0 && !exportSet[moduleName] {
+			continue
+		}
+
+		if list, ok := streamedLists[moduleName]; ok {
+			genesis, err := list.genesis(ctx)
+			if err != nil {
+				return servertypes.ExportedApp{}, fmt.Errorf("failed to export genesis state of module %s: %w", moduleName, err)
+			}
+
+			err = write(moduleName, func(w io.Writer) error {
+				return writeStreamedGenesis(w, genesis, list.field, func(fn func(json.RawMessage) error) error {
+					return list.walk(ctx, fn)
+				})
+			})
+			if err != nil {
+				return servertypes.ExportedApp{}, fmt.Errorf("failed to write genesis state of module %s: %w", moduleName, err)
+			}
+			continue
+		}
+
+		genState, err := app.ModuleManager.ExportGenesisForModules(ctx, app.appCodec, []string{moduleName})
+		if err != nil {
+			return servertypes.ExportedApp{}, fmt.Errorf("failed to export genesis state of module %s: %w", moduleName, err)
+		}
+
+		// modules without a genesis have nothing to write
+		bz, ok := genState[moduleName]
+		if !ok {
+			continue
+		}
+
+		err = write(moduleName, func(w io.Writer) error {
+			_, err := w.Write(bz)
+			return err
+		})
+		if err != nil {
+			return servertypes.ExportedApp{}, fmt.Errorf("failed to write genesis state of module %s: %w", moduleName, err)
+		}
+	}
+
+	validators, err := staking.WriteValidators(ctx, app.StakingKeeper)
+	return servertypes.ExportedApp{
+		Validators:      validators,
+		Height:          height,
+		ConsensusParams: app.BaseApp.GetConsensusParams(ctx),
+	}, err
+}
+
+// genesisDir returns the genesis directory set in the app options, if any.
+func genesisDir(appOpts servertypes.AppOptions) string {
+	dir := cast.ToString(appOpts.Get(FlagGenesisDir))
+	if dir == "" || filepath.IsAbs(dir) {
+		return dir
+	}
+
+	return filepath.Join(cast.ToString(appOpts.Get(flags.FlagHome)), dir)
+}
+
+// StreamingInitChainer returns an InitChainer which reads the genesis of each
+// module from its file in dir and initializes it before reading the next one,
+// in place of decoding the whole app state of the InitChain request. The
+// streamed lists of the modules are read one entry at a time.
+func (app *MiniApp) StreamingInitChainer(dir string) sdk.InitChainer {
+	return func(ctx sdk.Context, req *abci.RequestInitChain) (*abci.ResponseInitChain, error) {
+		if !emptyAppState(req.AppStateBytes) {
+			return nil, fmt.Errorf("app state is set in both the genesis file and the genesis directory %s", dir)
+		}
+
+		streamedLists := app.streamedLists()
+		var validatorUpdates []abci.ValidatorUpdate
+		for _, moduleName := range app.ModuleManager.OrderInitGenesis {
+			if list, ok := streamedLists[moduleName]; ok {
+				err := initStreamedGenesis(ctx, ModuleGenesisFile(dir, moduleName), list)
+				if errors.Is(err, os.ErrNotExist) {
+					continue
+				} else if err != nil {
+					return nil, fmt.Errorf("failed to initialize genesis state of module %s: %w", moduleName, err)
+				}
+				continue
+			}
+
+			bz, err := os.ReadFile(ModuleGenesisFile(dir, moduleName))
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+
+			ctx.Logger().Debug("running initialization for module", "module", moduleName, "bytes", len(bz))
+			moduleValUpdates, err := app.initModuleGenesis(ctx, moduleName, bz)
+			if err != nil {
+				return nil, fmt.Errorf("failed to initialize genesis state of module %s: %w", moduleName, err)
+			}
+
+			if len(moduleValUpdates) > 0 {
+				if len(validatorUpdates) > 0 {
+					return nil, errors.New("validator InitGenesis updates already set by a previous module")
+				}
+				validatorUpdates = moduleValUpdates
+			}
+		}
+
+		// a chain must initialize with a non-empty validator set
+		if len(validatorUpdates) == 0 {
+			return nil, fmt.Errorf("validator set is empty after InitGenesis, please ensure at least one validator is initialized with a delegator delegation of at least %d power", sdk.DefaultPowerReduction)
+		}
+
+		return &abci.ResponseInitChain{Validators: validatorUpdates}, nil
+	}
+}
+
+// initStreamedGenesis initializes the genesis of a module with a streamed list
+// from its file, which is read twice: once for the genesis without the list,
+// then for the entries of the list.
+func initStreamedGenesis(ctx sdk.Context, path string, list streamedList) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	ctx.Logger().Debug("running streamed initialization for module", "file", path)
+	genesis, err := decodeStreamedGenesis(bufio.NewReader(f), list.field, nil)
+	if err != nil {
+		return err
+	}
+
+	return list.init(ctx, genesis, func(fn func(json.RawMessage) error) error {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		_, err := decodeStreamedGenesis(bufio.NewReader(f), list.field, fn)
+		return err
+	})
+}
+
+// initModuleGenesis initializes the genesis of one module, as the module
+// manager does for each module of the app state.
+func (app *MiniApp) initModuleGenesis(ctx sdk.Context, moduleName string, bz json.RawMessage) ([]abci.ValidatorUpdate, error) {
+	switch mod := app.ModuleManager.Modules[moduleName].(type) {
+	case appmodule.HasGenesis:
+		source, err := genesis.SourceFromRawJSON(bz)
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, mod.InitGenesis(ctx, source)
+	case module.HasGenesis:
+		mod.InitGenesis(ctx, app.appCodec, bz)
+		return nil, nil
+	case module.HasABCIGenesis:
+		return mod.InitGenesis(ctx, app.appCodec, bz), nil
+	default:
+		return nil, nil
+	}
+}
+
+// emptyAppState reports whether the app state of a genesis file is left
+// empty, for the modules to read theirs from a genesis directory.
+func emptyAppState(bz []byte) bool {
+	bz = bytes.TrimSpace(bz)
+	return len(bz) == 0 || bytes.Equal(bz, []byte("{}")) || bytes.Equal(bz, []byte("null"))
+}
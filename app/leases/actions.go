@@ -0,0 +1,95 @@
+package leases
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	collcodec "cosmossdk.io/collections/codec"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// Action is a lockable action, which a single validator node runs at a time.
+// The lock of the same name is leased to an eligible validator for
+// LeaseBlocks blocks, then to the next one.
+type Action struct {
+	Name string `json:"name"`
+	// LeaseBlocks is the number of blocks of each lease.
+	LeaseBlocks uint64      `json:"lease_blocks"`
+	Eligibility Eligibility `json:"eligibility"`
+}
+
+// Eligibility restricts the bonded validators a lock can be leased to.
+type Eligibility struct {
+	// MinPower is the minimum consensus power of the validator.
+	MinPower int64 `json:"min_power,omitempty"`
+	// Validators are the operator addresses of the validators, any bonded
+	// validator when empty.
+	Validators []string `json:"validators,omitempty"`
+}
+
+// Validate checks the action.
+func (a Action) Validate() error {
+	if a.Name == "" {
+		return errors.New("action without name")
+	}
+	if a.LeaseBlocks == 0 {
+		return fmt.Errorf("action %s has no lease blocks", a.Name)
+	}
+	if a.Eligibility.MinPower < 0 {
+		return fmt.Errorf("action %s has a negative min power", a.Name)
+	}
+
+	seen := make(map[string]bool, len(a.Eligibility.Validators))
+	for _, addr := range a.Eligibility.Validators {
+		if _, err := sdk.ValAddressFromBech32(addr); err != nil {
+			return fmt.Errorf("action %s eligible validator %s: %w", a.Name, addr, err)
+		}
+		if seen[addr] {
+			return fmt.Errorf("action %s has duplicate eligible validator %s", a.Name, addr)
+		}
+		seen[addr] = true
+	}
+
+	return nil
+}
+
+// ValidateActions checks the actions, whose names must be unique.
+func ValidateActions(actions []Action) error {
+	names := make(map[string]bool, len(actions))
+	for _, action := range actions {
+		if err := action.Validate(); err != nil {
+			return err
+		}
+		if names[action.Name] {
+			return fmt.Errorf("duplicate action %s", action.Name)
+		}
+		names[action.Name] = true
+	}
+
+	return nil
+}
+
+// actionValue encodes the actions in JSON, they are not protobuf messages.
+type actionValue struct{}
+
+var _ collcodec.ValueCodec[Action] = actionValue{}
+
+func (actionValue) Encode(action Action) ([]byte, error) { return json.Marshal(action) }
+
+func (actionValue) Decode(bz []byte) (Action, error) {
+	var action Action
+	err := json.Unmarshal(bz, &action)
+	return action, err
+}
+
+func (v actionValue) EncodeJSON(action Action) ([]byte, error) { return v.Encode(action) }
+
+func (v actionValue) DecodeJSON(bz []byte) (Action, error) { return v.Decode(bz) }
+
+func (actionValue) Stringify(action Action) string {
+	return fmt.Sprintf("%s(%d blocks)", action.Name, action.LeaseBlocks)
+}
+
+func (actionValue) ValueType() string { return "procyon/leases/action" }
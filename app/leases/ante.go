@@ -0,0 +1,46 @@
+package leases
+
+import (
+	"cosmossdk.io/errors"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/polygon/envoy"
+)
+
+// ErrManualLock is returned for the msgs creating an envoy lock by hand.
+var ErrManualLock = errors.Register(ModuleName, 2, "envoy locks are leased by the chain, they are only created by hand in dev builds")
+
+// ManualLockDecorator rejects the txs creating an envoy lock by hand, unless
+// the binary is a dev build, keeping them out of the mempool. The msgs are
+// rejected by the CircuitBreaker of the msg router, which also sees those of
+// the gov proposals.
+type ManualLockDecorator struct{}
+
+var _ sdk.AnteDecorator = ManualLockDecorator{}
+
+func (ManualLockDecorator) AnteHandle(ctx sdk.Context, tx sdk.Tx, simulate bool, next sdk.AnteHandler) (sdk.Context, error) {
+	if ManualLocks {
+		return next(ctx, tx, simulate)
+	}
+
+	for _, msg := range tx.GetMsgs() {
+		if create, ok := msg.(*envoy.MsgCreateLock); ok {
+			return ctx, errors.Wrapf(ErrManualLock, "lock %s", create.Name)
+		}
+	}
+
+	return next(ctx, tx, simulate)
+}
+
+// NewAnteHandler returns the ante handler running the ManualLockDecorator
+// before the given one, which may be nil.
+func NewAnteHandler(anteHandler sdk.AnteHandler) sdk.AnteHandler {
+	if anteHandler == nil {
+		anteHandler = func(ctx sdk.Context, _ sdk.Tx, _ bool) (sdk.Context, error) { return ctx, nil }
+	}
+
+	return func(ctx sdk.Context, tx sdk.Tx, simulate bool) (sdk.Context, error) {
+		return ManualLockDecorator{}.AnteHandle(ctx, tx, simulate, anteHandler)
+	}
+}
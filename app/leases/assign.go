@@ -0,0 +1,230 @@
+package leases
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"slices"
+
+	"cosmossdk.io/collections"
+	"cosmossdk.io/core/address"
+	"cosmossdk.io/math"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+
+	"github.com/polygon/envoy"
+)
+
+// StakingKeeper is the view of the validators the locks are leased to.
+type StakingKeeper interface {
+	GetBondedValidatorsByPower(ctx context.Context) ([]stakingtypes.Validator, error)
+	GetValidator(ctx context.Context, addr sdk.ValAddress) (stakingtypes.Validator, error)
+	PowerReduction(ctx context.Context) math.Int
+	ValidatorAddressCodec() address.Codec
+}
+
+// AssignLeases leases the lock of every action without one from the given
+// height, to an eligible validator. The actions without any are left without
+// a lock until one is.
+func (k Keeper) AssignLeases(ctx context.Context, height uint64) error {
+	var actions []Action
+	if err := k.Actions.Walk(ctx, nil, func(_ string, action Action) (bool, error) {
+		actions = append(actions, action)
+		return false, nil
+	}); err != nil {
+		return err
+	}
+	if len(actions) == 0 {
+		return nil
+	}
+
+	candidates, err := k.candidates(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, action := range actions {
+		has, err := k.locks.Has(ctx, action.Name)
+		if err != nil {
+			return err
+		}
+		if has {
+			continue
+		}
+
+		holder, ok, err := k.selectHolder(ctx, action, eligible(action, candidates))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			sdk.UnwrapSDKContext(ctx).Logger().Debug("no eligible validator for action", "action", action.Name)
+			continue
+		}
+
+		lock := envoy.Lock{
+			Name:      action.Name,
+			Envoy:     holder.String(),
+			AtBlock:   height,
+			NumBlocks: action.LeaseBlocks,
+		}
+		if err := k.locks.Set(ctx, lock.Name, lock); err != nil {
+			return err
+		}
+		if err := k.queue(ctx, lock); err != nil {
+			return err
+		}
+
+		emitLockEvent(ctx, EventTypeLockAssigned, lock)
+	}
+
+	return nil
+}
+
+// candidate is a bonded validator a lock can be leased to.
+type candidate struct {
+	// holder is the lock holder of the validator, its operator account
+	holder sdk.AccAddress
+	// operator is the bech32 operator address
+	operator string
+	power    int64
+}
+
+// candidates returns the bonded validators which are not jailed, ordered by
+// their holder address.
+func (k Keeper) candidates(ctx context.Context) ([]candidate, error) {
+	validators, err := k.staking.GetBondedValidatorsByPower(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	powerReduction := k.staking.PowerReduction(ctx)
+	candidates := make([]candidate, 0, len(validators))
+	for _, val := range validators {
+		if val.IsJailed() {
+			continue
+		}
+
+		operator, err := k.staking.ValidatorAddressCodec().StringToBytes(val.GetOperator())
+		if err != nil {
+			return nil, err
+		}
+
+		candidates = append(candidates, candidate{
+			holder:   sdk.AccAddress(operator),
+			operator: val.GetOperator(),
+			power:    val.GetConsensusPower(powerReduction),
+		})
+	}
+
+	slices.SortFunc(candidates, func(a, b candidate) int {
+		return bytes.Compare(a.holder, b.holder)
+	})
+
+	return candidates, nil
+}
+
+// eligible returns the candidates eligible for the action.
+func eligible(action Action, candidates []candidate) []candidate {
+	var eligible []candidate
+	for _, c := range candidates {
+		if c.power < action.Eligibility.MinPower {
+			continue
+		}
+		if len(action.Eligibility.Validators) > 0 && !slices.Contains(action.Eligibility.Validators, c.operator) {
+			continue
+		}
+		eligible = append(eligible, c)
+	}
+
+	return eligible
+}
+
+// selectHolder selects the holder of the next lease of the action among the
+// eligible validators, weighted by their power. The draw is seeded from the
+// app hash of the previous block, so every node draws the same holder, which
+// no one knows before that block is committed. The previous holder is left out
+// while another validator is eligible, so that the leases rotate and a large
+// validator cannot hold an action for good.
+func (k Keeper) selectHolder(ctx context.Context, action Action, eligible []candidate) (sdk.AccAddress, bool, error) {
+	previous, err := k.previousHolder(ctx, action.Name)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(eligible) > 1 {
+		eligible = slices.DeleteFunc(slices.Clone(eligible), func(c candidate) bool {
+			return bytes.Equal(c.holder, previous)
+		})
+	}
+	if len(eligible) == 0 {
+		return nil, false, nil
+	}
+
+	sdkCtx := sdk.UnwrapSDKContext(ctx)
+	seed := selectionSeed(sdkCtx.BlockHeader().AppHash, action.Name, sdkCtx.BlockHeight())
+
+	return weightedSelect(eligible, seed).holder, true, nil
+}
+
+// selectionSeed returns the seed of the draw of the action holder at the given
+// height, from the app hash of the previous block.
+func selectionSeed(appHash []byte, action string, height int64) uint64 {
+	h := sha256.New()
+	h.Write(appHash)
+	h.Write([]byte(action))
+	h.Write(sdk.Uint64ToBigEndian(uint64(height)))
+
+	return binary.BigEndian.Uint64(h.Sum(nil))
+}
+
+// weightedSelect returns the candidate drawn by the seed, weighted by power.
+// The candidates must be in a deterministic order, and not empty.
+func weightedSelect(candidates []candidate, seed uint64) candidate {
+	var total uint64
+	for _, c := range candidates {
+		total += uint64(max(c.power, 0))
+	}
+	if total == 0 {
+		// no candidate has any power, they are equally likely
+		return candidates[seed%uint64(len(candidates))]
+	}
+
+	draw := seed % total
+	for _, c := range candidates {
+		weight := uint64(max(c.power, 0))
+		if draw < weight {
+			return c
+		}
+		draw -= weight
+	}
+
+	panic("unreachable")
+}
+
+// previousHolder returns the holder of the last expired lease of the lock, nil
+// if it has none.
+func (k Keeper) previousHolder(ctx context.Context, name string) (sdk.AccAddress, error) {
+	rng := collections.NewPrefixedPairRange[string, uint64](name).Descending()
+	iter, err := k.History.Iterate(ctx, rng)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	if !iter.Valid() {
+		return nil, nil
+	}
+	lock, err := iter.Value()
+	if err != nil {
+		return nil, err
+	}
+
+	holder, err := sdk.AccAddressFromBech32(lock.Envoy)
+	if err != nil {
+		// a lock created by hand may have any holder, the rotation restarts
+		return nil, nil
+	}
+	return holder, nil
+}
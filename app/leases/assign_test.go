@@ -0,0 +1,191 @@
+package leases
+
+import (
+	"bytes"
+	"testing"
+
+	cmtproto "github.com/cometbft/cometbft/proto/tendermint/types"
+	"github.com/stretchr/testify/require"
+	protov2 "google.golang.org/protobuf/proto"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+
+	"github.com/polygon/envoy"
+)
+
+// newValidator returns a bonded validator whose operator address is filled
+// with b, of the given power.
+func newValidator(b byte, power int64, jailed bool) stakingtypes.Validator {
+	return stakingtypes.Validator{
+		OperatorAddress: sdk.ValAddress(bytes.Repeat([]byte{b}, 20)).String(),
+		Jailed:          jailed,
+		Status:          stakingtypes.Bonded,
+		Tokens:          sdk.TokensFromConsensusPower(power, sdk.DefaultPowerReduction),
+	}
+}
+
+// holder returns the lock holder of the validator whose operator address is
+// filled with b.
+func holder(b byte) string {
+	return sdk.AccAddress(bytes.Repeat([]byte{b}, 20)).String()
+}
+
+func TestAssignLeases(t *testing.T) {
+	ctx, k, locks := setupKeeper(t,
+		newValidator(3, 10, false),
+		newValidator(1, 10, false),
+		newValidator(2, 1, false),
+		newValidator(4, 10, true),
+	)
+
+	require.NoError(t, k.InitGenesis(ctx, GenesisState{Actions: []Action{
+		{Name: "checkpoint", LeaseBlocks: 5, Eligibility: Eligibility{MinPower: 5}},
+	}}))
+
+	// the lock is leased from the next height to the eligible validators,
+	// skipping the jailed and low power ones, never twice in a row
+	var previous string
+	for _, height := range []int64{1, 6, 11, 16} {
+		events := endBlock(t, ctx, k, height)
+		require.Equal(t, EventTypeLockAssigned, events[len(events)-1].Type)
+
+		lock, err := locks.Get(ctx, "checkpoint")
+		require.NoError(t, err)
+		require.Contains(t, []string{holder(1), holder(3)}, lock.Envoy)
+		require.NotEqual(t, previous, lock.Envoy, "height %d", height)
+		require.Equal(t, uint64(height+1), lock.AtBlock)
+		require.Equal(t, uint64(5), lock.NumBlocks)
+		previous = lock.Envoy
+
+		// the lease is kept until it expires
+		require.Empty(t, endBlock(t, ctx, k, height+1))
+	}
+
+	history, err := k.LockHistory(ctx, "checkpoint")
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+}
+
+func TestSelectHolderIsDeterministic(t *testing.T) {
+	validators := []stakingtypes.Validator{
+		newValidator(1, 10, false),
+		newValidator(2, 20, false),
+		newValidator(3, 30, false),
+	}
+	ctx, k, _ := setupKeeper(t, validators...)
+	ctx = ctx.WithBlockHeader(cmtproto.Header{Height: 10, AppHash: []byte("app hash")})
+
+	candidates, err := k.candidates(ctx)
+	require.NoError(t, err)
+
+	action := Action{Name: "checkpoint", LeaseBlocks: 5}
+	first, ok, err := k.selectHolder(ctx, action, candidates)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	for i := 0; i < 10; i++ {
+		holder, _, err := k.selectHolder(ctx, action, candidates)
+		require.NoError(t, err)
+		require.Equal(t, first, holder)
+	}
+}
+
+func TestWeightedSelect(t *testing.T) {
+	candidates := []candidate{
+		{holder: sdk.AccAddress("small"), power: 1},
+		{holder: sdk.AccAddress("large"), power: 9},
+		{holder: sdk.AccAddress("none"), power: 0},
+	}
+
+	// the candidates are drawn in proportion to their power
+	counts := make(map[string]int)
+	const draws = 10000
+	for i := 0; i < draws; i++ {
+		seed := selectionSeed([]byte("app hash"), "checkpoint", int64(i))
+		counts[string(weightedSelect(candidates, seed).holder)]++
+	}
+	require.InDelta(t, draws/10, counts["small"], draws/50)
+	require.InDelta(t, draws*9/10, counts["large"], draws/50)
+	require.Zero(t, counts["none"])
+
+	// without power, any candidate may be drawn
+	noPower := []candidate{{holder: sdk.AccAddress("a")}, {holder: sdk.AccAddress("b")}}
+	require.Equal(t, noPower[1], weightedSelect(noPower, 3))
+}
+
+func TestAssignLeasesEligibility(t *testing.T) {
+	ctx, k, locks := setupKeeper(t, newValidator(1, 10, false), newValidator(2, 10, false))
+
+	require.NoError(t, k.InitGenesis(ctx, GenesisState{Actions: []Action{
+		{Name: "allowed", LeaseBlocks: 5, Eligibility: Eligibility{
+			Validators: []string{sdk.ValAddress(bytes.Repeat([]byte{2}, 20)).String()},
+		}},
+		{Name: "none", LeaseBlocks: 5, Eligibility: Eligibility{MinPower: 100}},
+	}}))
+	endBlock(t, ctx, k, 1)
+
+	lock, err := locks.Get(ctx, "allowed")
+	require.NoError(t, err)
+	require.Equal(t, holder(2), lock.Envoy)
+
+	// without eligible validator, the action is left without a lock
+	has, err := locks.Has(ctx, "none")
+	require.NoError(t, err)
+	require.False(t, has)
+}
+
+// mockTx is a tx of the given messages.
+type mockTx []sdk.Msg
+
+func (tx mockTx) GetMsgs() []sdk.Msg { return tx }
+
+func (mockTx) GetMsgsV2() ([]protov2.Message, error) { return nil, nil }
+
+func TestManualLockDecorator(t *testing.T) {
+	ctx, _, _ := setupKeeper(t)
+
+	var called bool
+	anteHandler := NewAnteHandler(func(ctx sdk.Context, _ sdk.Tx, _ bool) (sdk.Context, error) {
+		called = true
+		return ctx, nil
+	})
+
+	_, err := anteHandler(ctx, mockTx{&stakingtypes.MsgDelegate{}}, false)
+	require.NoError(t, err)
+	require.True(t, called)
+
+	called = false
+	_, err = anteHandler(ctx, mockTx{&envoy.MsgCreateLock{Name: "lock1"}}, false)
+	if ManualLocks {
+		require.NoError(t, err)
+		require.True(t, called)
+	} else {
+		require.ErrorIs(t, err, ErrManualLock)
+		require.False(t, called)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx, k, _ := setupKeeper(t)
+	cb := NewCircuitBreaker(k)
+
+	allowed, err := cb.IsAllowed(ctx, sdk.MsgTypeURL(&stakingtypes.MsgDelegate{}))
+	require.NoError(t, err)
+	require.True(t, allowed)
+
+	// the lock created by hand, be it by a tx or a gov proposal, is only
+	// allowed in dev builds, which flag the block for the end blocker
+	allowed, err = cb.IsAllowed(ctx, sdk.MsgTypeURL(&envoy.MsgCreateLock{}))
+	if ManualLocks {
+		require.NoError(t, err)
+		require.True(t, allowed)
+	} else {
+		require.ErrorIs(t, err, ErrManualLock)
+		require.False(t, allowed)
+	}
+
+	created, err := k.Created.Has(ctx)
+	require.NoError(t, err)
+	require.Equal(t, ManualLocks, created)
+}
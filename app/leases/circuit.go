@@ -0,0 +1,39 @@
+package leases
+
+import (
+	"context"
+
+	"github.com/cosmos/cosmos-sdk/baseapp"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/polygon/envoy"
+)
+
+// CircuitBreaker is the circuit breaker of the msg router, which sees the msgs
+// of the txs and of the gov proposals alike. It rejects the envoy locks created
+// by hand, unless the binary is a dev build, and flags the blocks creating one
+// so the end blocker queues the lock without walking them all in every block.
+type CircuitBreaker struct {
+	keeper Keeper
+}
+
+var _ baseapp.CircuitBreaker = CircuitBreaker{}
+
+// NewCircuitBreaker returns the circuit breaker flagging the locks created by
+// hand in the state of the keeper.
+func NewCircuitBreaker(k Keeper) CircuitBreaker {
+	return CircuitBreaker{keeper: k}
+}
+
+// IsAllowed allows every msg but the MsgCreateLock outside dev builds, flagging
+// the block when it creates a lock.
+func (cb CircuitBreaker) IsAllowed(ctx context.Context, typeURL string) (bool, error) {
+	if typeURL != sdk.MsgTypeURL(&envoy.MsgCreateLock{}) {
+		return true, nil
+	}
+	if !ManualLocks {
+		return false, ErrManualLock
+	}
+
+	return true, cb.keeper.Created.Set(ctx, true)
+}
@@ -0,0 +1,202 @@
+package leases
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"cosmossdk.io/collections"
+
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/client/flags"
+	"github.com/cosmos/cosmos-sdk/types/kv"
+
+	"github.com/polygon/envoy"
+)
+
+// GetQueryCmd returns the query commands of the module.
+func GetQueryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                        ModuleName,
+		Short:                      "Querying commands for the envoy lock leases",
+		DisableFlagParsing:         true,
+		SuggestionsMinimumDistance: 2,
+		RunE:                       client.ValidateCmd,
+	}
+
+	cmd.AddCommand(
+		GetLockHistoryCmd(),
+		GetParamsCmd(),
+	)
+
+	return cmd
+}
+
+// GetLockHistoryCmd returns the command querying the last expired leases of a
+// lock name, oldest first.
+func GetLockHistoryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "history [name]",
+		Short:   "Query the last expired leases of a lock",
+		Example: "procyon query leases history lock1",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clientCtx, err := client.GetClientQueryContext(cmd)
+			if err != nil {
+				return err
+			}
+
+			prefix, err := historyPrefix(args[0])
+			if err != nil {
+				return err
+			}
+
+			// the module has no gRPC service, the history is read from its store
+			bz, _, err := clientCtx.QueryWithData(fmt.Sprintf("/store/%s/subspace", StoreKey), prefix)
+			if err != nil {
+				return err
+			}
+
+			pairs, err := decodeKVPairs(bz)
+			if err != nil {
+				return err
+			}
+
+			keyCodec := collections.PairKeyCodec(collections.StringKey, collections.Uint64Key)
+			history := make([]Lease, 0, len(pairs))
+			for _, pair := range pairs {
+				_, key, err := keyCodec.Decode(pair.Key[len(HistoryPrefix):])
+				if err != nil {
+					return err
+				}
+
+				var lock envoy.Lock
+				if err := clientCtx.Codec.Unmarshal(pair.Value, &lock); err != nil {
+					return err
+				}
+				history = append(history, NewLease(lock, key.K2()))
+			}
+
+			bz, err = json.Marshal(struct {
+				History []Lease `json:"history"`
+			}{history})
+			if err != nil {
+				return err
+			}
+			return clientCtx.PrintRaw(bz)
+		},
+	}
+
+	flags.AddQueryFlagsToCmd(cmd)
+
+	return cmd
+}
+
+// GetParamsCmd returns the command querying the penalties of the lock holders
+// missing their action.
+func GetParamsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "params",
+		Short:   "Query the penalties of the lock holders missing their action",
+		Example: "procyon query leases params",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			clientCtx, err := client.GetClientQueryContext(cmd)
+			if err != nil {
+				return err
+			}
+
+			bz, _, err := clientCtx.QueryStore(ParamsPrefix.Bytes(), StoreKey)
+			if err != nil {
+				return err
+			}
+
+			var params Params
+			if err := clientCtx.Codec.Unmarshal(bz, &params); err != nil {
+				return err
+			}
+
+			bz, err = json.Marshal(struct {
+				Params Params `json:"params"`
+			}{params})
+			if err != nil {
+				return err
+			}
+			return clientCtx.PrintRaw(bz)
+		},
+	}
+
+	flags.AddQueryFlagsToCmd(cmd)
+
+	return cmd
+}
+
+// historyPrefix returns the store prefix of the history of a lock name.
+func historyPrefix(name string) ([]byte, error) {
+	buf := make([]byte, collections.StringKey.SizeNonTerminal(name))
+	if _, err := collections.StringKey.EncodeNonTerminal(buf, name); err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, HistoryPrefix.Bytes()...), buf...), nil
+}
+
+// decodeKVPairs decodes the key-value pairs returned by a subspace store query,
+// whose proto type is internal to the store module.
+func decodeKVPairs(bz []byte) ([]kv.Pair, error) {
+	var pairs []kv.Pair
+	for len(bz) > 0 {
+		num, typ, n := protowire.ConsumeTag(bz)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		bz = bz[n:]
+
+		if num != 1 || typ != protowire.BytesType {
+			if n = protowire.ConsumeFieldValue(num, typ, bz); n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			bz = bz[n:]
+			continue
+		}
+
+		msg, n := protowire.ConsumeBytes(bz)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		bz = bz[n:]
+
+		var pair kv.Pair
+		for len(msg) > 0 {
+			num, typ, n := protowire.ConsumeTag(msg)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			msg = msg[n:]
+
+			if typ != protowire.BytesType || (num != 1 && num != 2) {
+				if n = protowire.ConsumeFieldValue(num, typ, msg); n < 0 {
+					return nil, protowire.ParseError(n)
+				}
+				msg = msg[n:]
+				continue
+			}
+
+			value, n := protowire.ConsumeBytes(msg)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			msg = msg[n:]
+
+			if num == 1 {
+				pair.Key = value
+			} else {
+				pair.Value = value
+			}
+		}
+		pairs = append(pairs, pair)
+	}
+
+	return pairs, nil
+}
@@ -0,0 +1,175 @@
+package leases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"cosmossdk.io/collections"
+
+	"github.com/polygon/envoy"
+)
+
+// Lease is an expired lock, as exported and queried.
+type Lease struct {
+	Name      string `json:"name"`
+	Envoy     string `json:"envoy"`
+	AtBlock   uint64 `json:"at_block,string"`
+	NumBlocks uint64 `json:"num_blocks,string"`
+	// ExpiredAt is the first height the lock did not cover.
+	ExpiredAt uint64 `json:"expired_at,string"`
+}
+
+// NewLease returns the lease of the lock which expired at the given height.
+func NewLease(lock envoy.Lock, expiredAt uint64) Lease {
+	return Lease{
+		Name:      lock.Name,
+		Envoy:     lock.Envoy,
+		AtBlock:   lock.AtBlock,
+		NumBlocks: lock.NumBlocks,
+		ExpiredAt: expiredAt,
+	}
+}
+
+// Lock returns the lock of the lease.
+func (l Lease) Lock() envoy.Lock {
+	return envoy.Lock{
+		Name:      l.Name,
+		Envoy:     l.Envoy,
+		AtBlock:   l.AtBlock,
+		NumBlocks: l.NumBlocks,
+	}
+}
+
+// Misses are the blocks missed in a row by the holder of a lock.
+type Misses struct {
+	Name  string `json:"name"`
+	Count uint64 `json:"count,string"`
+}
+
+// GenesisState is the genesis of the module. The expiry queue is not part of
+// it, as it is rebuilt from the envoy locks.
+type GenesisState struct {
+	Params Params `json:"params"`
+	// Actions are the lockable actions, seeded from the app config.
+	Actions []Action `json:"actions"`
+	History []Lease  `json:"history"`
+	Misses  []Misses `json:"misses"`
+}
+
+// DefaultGenesis returns a genesis of the given actions with the default
+// params, without history.
+func DefaultGenesis(actions []Action) *GenesisState {
+	if actions == nil {
+		actions = []Action{}
+	}
+	return &GenesisState{Params: DefaultParams(), Actions: actions, History: []Lease{}, Misses: []Misses{}}
+}
+
+// Validate checks the params and the actions, that the history has no
+// duplicate leases and at most HistoryLength leases for each lock name, and
+// that the misses are counted once for each lock name.
+func (gs GenesisState) Validate() error {
+	if err := gs.Params.Validate(); err != nil {
+		return err
+	}
+	if err := ValidateActions(gs.Actions); err != nil {
+		return err
+	}
+
+	counted := make(map[string]bool, len(gs.Misses))
+	for _, misses := range gs.Misses {
+		if misses.Name == "" {
+			return errors.New("misses without lock name")
+		}
+		if counted[misses.Name] {
+			return fmt.Errorf("duplicate misses of lock %s", misses.Name)
+		}
+		counted[misses.Name] = true
+	}
+
+	type leaseKey struct {
+		name      string
+		expiredAt uint64
+	}
+
+	seen := make(map[leaseKey]bool, len(gs.History))
+	count := make(map[string]int)
+	for _, lease := range gs.History {
+		if lease.Name == "" {
+			return errors.New("lease without lock name")
+		}
+
+		key := leaseKey{lease.Name, lease.ExpiredAt}
+		if seen[key] {
+			return fmt.Errorf("duplicate lease of lock %s expired at %d", lease.Name, lease.ExpiredAt)
+		}
+		seen[key] = true
+
+		count[lease.Name]++
+		if count[lease.Name] > HistoryLength {
+			return fmt.Errorf("lock %s has more than %d leases", lease.Name, HistoryLength)
+		}
+	}
+
+	return nil
+}
+
+// InitGenesis sets the params, seeds the actions, imports the history and the
+// misses, and queues the envoy locks, which must be imported first.
+func (k Keeper) InitGenesis(ctx context.Context, gs GenesisState) error {
+	if err := k.Params.Set(ctx, gs.Params); err != nil {
+		return err
+	}
+
+	for _, action := range gs.Actions {
+		if err := k.Actions.Set(ctx, action.Name, action); err != nil {
+			return err
+		}
+	}
+
+	for _, lease := range gs.History {
+		if err := k.History.Set(ctx, collections.Join(lease.Name, lease.ExpiredAt), lease.Lock()); err != nil {
+			return err
+		}
+	}
+
+	for _, misses := range gs.Misses {
+		if err := k.Misses.Set(ctx, misses.Name, misses.Count); err != nil {
+			return err
+		}
+	}
+
+	return k.IndexLocks(ctx)
+}
+
+// ExportGenesis exports the params, the actions, the history and the misses.
+func (k Keeper) ExportGenesis(ctx context.Context) (*GenesisState, error) {
+	params, err := k.Params.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	gs := DefaultGenesis(nil)
+	gs.Params = params
+	if err := k.Actions.Walk(ctx, nil, func(_ string, action Action) (bool, error) {
+		gs.Actions = append(gs.Actions, action)
+		return false, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := k.History.Walk(ctx, nil, func(key collections.Pair[string, uint64], lock envoy.Lock) (bool, error) {
+		gs.History = append(gs.History, NewLease(lock, key.K2()))
+		return false, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	err = k.Misses.Walk(ctx, nil, func(name string, count uint64) (bool, error) {
+		gs.Misses = append(gs.Misses, Misses{Name: name, Count: count})
+		return false, nil
+	})
+
+	return gs, err
+}
@@ -0,0 +1,318 @@
+// Package leases expires the envoy locks once the blocks they cover have
+// passed, and keeps the last expired leases of each lock name. The locks of
+// the lockable actions registered in its state are leased to bonded
+// validators drawn by stake, and forfeited by the holders which miss their
+// action, who are penalised.
+//
+// The envoy module keeps a lock until it is replaced, so without it a lock and
+// its holder stay in state forever.
+package leases
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+
+	"cosmossdk.io/collections"
+	"cosmossdk.io/core/store"
+
+	"github.com/cosmos/cosmos-sdk/codec"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/polygon/envoy"
+)
+
+const (
+	// ModuleName is the name of the module, and of its store.
+	ModuleName = "leases"
+	// StoreKey is the store key of the module.
+	StoreKey = ModuleName
+
+	// HistoryLength is the number of expired leases kept for each lock name.
+	HistoryLength = 10
+)
+
+// Event types and attributes emitted when a lock expires, is assigned or is
+// forfeited.
+const (
+	EventTypeLockExpired   = "lock_expired"
+	EventTypeLockAssigned  = "lock_assigned"
+	EventTypeLockForfeited = "lock_forfeited"
+
+	AttributeKeyName      = "name"
+	AttributeKeyEnvoy     = "envoy"
+	AttributeKeyAtBlock   = "at_block"
+	AttributeKeyNumBlocks = "num_blocks"
+	AttributeKeyMisses    = "misses"
+)
+
+var (
+	ExpiryQueuePrefix = collections.NewPrefix(0)
+	ExpiryIndexPrefix = collections.NewPrefix(1)
+	HistoryPrefix     = collections.NewPrefix(2)
+	CreatedPrefix     = collections.NewPrefix(3)
+	ActionsPrefix     = collections.NewPrefix(4)
+	ParamsPrefix      = collections.NewPrefix(5)
+	MissesPrefix      = collections.NewPrefix(6)
+)
+
+// Keeper expires the envoy locks, and leases the locks of the lockable actions.
+type Keeper struct {
+	// locks are the locks of the envoy keeper
+	locks    collections.Map[string, envoy.Lock]
+	staking  StakingKeeper
+	slashing SlashingKeeper
+	// authority is the account allowed to update the params
+	authority string
+
+	Schema collections.Schema
+	// ExpiryQueue holds the locks by the first height they do not cover.
+	ExpiryQueue collections.KeySet[collections.Pair[uint64, string]]
+	// ExpiryIndex holds the height each lock is queued at.
+	ExpiryIndex collections.Map[string, uint64]
+	// History holds the expired locks by name and expiry height.
+	History collections.Map[collections.Pair[string, uint64], envoy.Lock]
+	// Actions is the registry of the lockable actions by name.
+	Actions collections.Map[string, Action]
+	// Params are the penalties of the holders missing their action.
+	Params collections.Item[Params]
+	// Misses holds the blocks missed in a row by the holder of each lock.
+	Misses collections.Map[string, uint64]
+	// Created is set in the blocks in which a lock may have been created by
+	// hand, so the end blocker indexes it.
+	Created collections.Item[bool]
+}
+
+// NewKeeper returns a keeper expiring the given envoy locks, leasing them to
+// the validators of the staking keeper, and penalising the holders with the
+// slashing keeper. Its params are updated by the given authority.
+func NewKeeper(
+	cdc codec.BinaryCodec,
+	storeService store.KVStoreService,
+	locks collections.Map[string, envoy.Lock],
+	staking StakingKeeper,
+	slashing SlashingKeeper,
+	authority string,
+) Keeper {
+	sb := collections.NewSchemaBuilder(storeService)
+	k := Keeper{
+		locks:     locks,
+		staking:   staking,
+		slashing:  slashing,
+		authority: authority,
+		ExpiryQueue: collections.NewKeySet(
+			sb, ExpiryQueuePrefix, "expiry_queue",
+			collections.PairKeyCodec(collections.Uint64Key, collections.StringKey),
+		),
+		ExpiryIndex: collections.NewMap(
+			sb, ExpiryIndexPrefix, "expiry_index",
+			collections.StringKey, collections.Uint64Value,
+		),
+		History: collections.NewMap(
+			sb, HistoryPrefix, "history",
+			collections.PairKeyCodec(collections.StringKey, collections.Uint64Key),
+			codec.CollValue[envoy.Lock](cdc),
+		),
+		Actions: collections.NewMap(
+			sb, ActionsPrefix, "actions",
+			collections.StringKey, actionValue{},
+		),
+		Params: collections.NewItem(sb, ParamsPrefix, "params", codec.CollValue[Params](cdc)),
+		Misses: collections.NewMap(
+			sb, MissesPrefix, "misses",
+			collections.StringKey, collections.Uint64Value,
+		),
+		Created: collections.NewItem(sb, CreatedPrefix, "created", collections.BoolValue),
+	}
+
+	schema, err := sb.Build()
+	if err != nil {
+		panic(err)
+	}
+	k.Schema = schema
+
+	return k
+}
+
+// EndBlock queues the locks created by hand in the block, expires those which
+// do not cover the next height, and leases the locks of the actions left
+// without one from it.
+func (k Keeper) EndBlock(ctx context.Context) error {
+	created, err := k.Created.Has(ctx)
+	if err != nil {
+		return err
+	}
+	if created {
+		if err := k.IndexLocks(ctx); err != nil {
+			return err
+		}
+		if err := k.Created.Remove(ctx); err != nil {
+			return err
+		}
+	}
+
+	next := uint64(sdk.UnwrapSDKContext(ctx).BlockHeight()) + 1
+	if err := k.ExpireLocks(ctx, next); err != nil {
+		return err
+	}
+	return k.AssignLeases(ctx, next)
+}
+
+// IndexLocks queues every lock which is not queued at its expiry height yet.
+//
+// The envoy module doesn't notify the app of the locks it creates, so they are
+// found by walking them all. The leased locks are queued when assigned, the
+// walk is only needed for the genesis locks and those created by hand. The
+// locks are queued as they are walked, queuing only writing the leases store,
+// so that a genesis of many locks is not held in memory.
+func (k Keeper) IndexLocks(ctx context.Context) error {
+	return k.locks.Walk(ctx, nil, func(_ string, lock envoy.Lock) (bool, error) {
+		return false, k.queue(ctx, lock)
+	})
+}
+
+// queue queues the lock at its expiry height, moving it if it was queued at
+// another height.
+func (k Keeper) queue(ctx context.Context, lock envoy.Lock) error {
+	expiry := Expiry(lock)
+
+	queued, err := k.ExpiryIndex.Get(ctx, lock.Name)
+	switch {
+	case err == nil && queued == expiry:
+		return nil
+	case err == nil:
+		if err := k.ExpiryQueue.Remove(ctx, collections.Join(queued, lock.Name)); err != nil {
+			return err
+		}
+	case !errors.Is(err, collections.ErrNotFound):
+		return err
+	}
+
+	if err := k.ExpiryQueue.Set(ctx, collections.Join(expiry, lock.Name)); err != nil {
+		return err
+	}
+	return k.ExpiryIndex.Set(ctx, lock.Name, expiry)
+}
+
+// ExpireLocks removes the locks which do not cover the given height, records
+// them in their history, and emits an event for each of them.
+func (k Keeper) ExpireLocks(ctx context.Context, height uint64) error {
+	// the queue entries are collected first, as they are removed while expiring
+	var expired []collections.Pair[uint64, string]
+	if err := k.ExpiryQueue.Walk(ctx, nil, func(key collections.Pair[uint64, string]) (bool, error) {
+		if key.K1() > height {
+			return true, nil
+		}
+		expired = append(expired, key)
+		return false, nil
+	}); err != nil {
+		return err
+	}
+
+	for _, key := range expired {
+		if err := k.expire(ctx, key.K2(), key.K1()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// expire removes the lock queued at the given expiry height.
+func (k Keeper) expire(ctx context.Context, name string, expiry uint64) error {
+	if err := k.ExpiryQueue.Remove(ctx, collections.Join(expiry, name)); err != nil {
+		return err
+	}
+	if err := k.ExpiryIndex.Remove(ctx, name); err != nil {
+		return err
+	}
+	if err := k.Misses.Remove(ctx, name); err != nil {
+		return err
+	}
+
+	lock, err := k.locks.Get(ctx, name)
+	if errors.Is(err, collections.ErrNotFound) {
+		// removed since it was queued
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if err := k.locks.Remove(ctx, name); err != nil {
+		return err
+	}
+	if err := k.record(ctx, lock, expiry); err != nil {
+		return err
+	}
+
+	emitLockEvent(ctx, EventTypeLockExpired, lock)
+	return nil
+}
+
+// emitLockEvent emits an event of the given type for the lock, with the
+// extra attributes.
+func emitLockEvent(ctx context.Context, eventType string, lock envoy.Lock, attrs ...sdk.Attribute) {
+	sdk.UnwrapSDKContext(ctx).EventManager().EmitEvent(sdk.NewEvent(
+		eventType,
+		append([]sdk.Attribute{
+			sdk.NewAttribute(AttributeKeyName, lock.Name),
+			sdk.NewAttribute(AttributeKeyEnvoy, lock.Envoy),
+			sdk.NewAttribute(AttributeKeyAtBlock, strconv.FormatUint(lock.AtBlock, 10)),
+			sdk.NewAttribute(AttributeKeyNumBlocks, strconv.FormatUint(lock.NumBlocks, 10)),
+		}, attrs...)...,
+	))
+}
+
+// record adds the lock to the history of its name, dropping the oldest leases
+// beyond HistoryLength.
+func (k Keeper) record(ctx context.Context, lock envoy.Lock, expiry uint64) error {
+	if err := k.History.Set(ctx, collections.Join(lock.Name, expiry), lock); err != nil {
+		return err
+	}
+
+	iter, err := k.History.Iterate(ctx, collections.NewPrefixedPairRange[string, uint64](lock.Name))
+	if err != nil {
+		return err
+	}
+	keys, err := iter.Keys()
+	if err != nil {
+		return err
+	}
+
+	for len(keys) > HistoryLength {
+		if err := k.History.Remove(ctx, keys[0]); err != nil {
+			return err
+		}
+		keys = keys[1:]
+	}
+
+	return nil
+}
+
+// LockHistory returns the expired leases of the lock name, oldest first.
+func (k Keeper) LockHistory(ctx context.Context, name string) ([]Lease, error) {
+	iter, err := k.History.Iterate(ctx, collections.NewPrefixedPairRange[string, uint64](name))
+	if err != nil {
+		return nil, err
+	}
+	kvs, err := iter.KeyValues()
+	if err != nil {
+		return nil, err
+	}
+
+	leases := make([]Lease, 0, len(kvs))
+	for _, kv := range kvs {
+		leases = append(leases, NewLease(kv.Value, kv.Key.K2()))
+	}
+
+	return leases, nil
+}
+
+// Expiry returns the first height the lock does not cover.
+func Expiry(lock envoy.Lock) uint64 {
+	if lock.NumBlocks > math.MaxUint64-lock.AtBlock {
+		return math.MaxUint64
+	}
+	return lock.AtBlock + lock.NumBlocks
+}
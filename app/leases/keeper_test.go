@@ -0,0 +1,348 @@
+package leases
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/collections"
+	"cosmossdk.io/core/address"
+	sdkmath "cosmossdk.io/math"
+	storetypes "cosmossdk.io/store/types"
+
+	"github.com/cosmos/cosmos-sdk/codec"
+	addresscodec "github.com/cosmos/cosmos-sdk/codec/address"
+	"github.com/cosmos/cosmos-sdk/runtime"
+	"github.com/cosmos/cosmos-sdk/testutil"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	moduletestutil "github.com/cosmos/cosmos-sdk/types/module/testutil"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+
+	"github.com/polygon/envoy"
+)
+
+// setupKeeper returns a keeper expiring the locks of a standalone envoy lock
+// map, in place of the envoy keeper, leasing them to the given validators, and
+// penalising them with a mockSlashing.
+func setupKeeper(t *testing.T, validators ...stakingtypes.Validator) (sdk.Context, Keeper, collections.Map[string, envoy.Lock]) {
+	t.Helper()
+
+	key := storetypes.NewKVStoreKey(StoreKey)
+	envoyKey := storetypes.NewKVStoreKey(envoy.ModuleName)
+	ctx := testutil.DefaultContextWithKeys(map[string]*storetypes.KVStoreKey{
+		StoreKey:         key,
+		envoy.ModuleName: envoyKey,
+	}, nil, nil)
+
+	cdc := moduletestutil.MakeTestEncodingConfig().Codec
+	locks := collections.NewMap(
+		collections.NewSchemaBuilder(runtime.NewKVStoreService(envoyKey)),
+		collections.NewPrefix(0), "locks", collections.StringKey, codec.CollValue[envoy.Lock](cdc),
+	)
+
+	k := NewKeeper(cdc, runtime.NewKVStoreService(key), locks, mockStaking{validators}, &mockSlashing{}, authority)
+	require.NoError(t, k.Params.Set(ctx, DefaultParams()))
+
+	return ctx, k, locks
+}
+
+// authority is the authority of the keeper params.
+var authority = sdk.AccAddress("gov_________________").String()
+
+// mockStaking is a staking keeper of a fixed validator set.
+type mockStaking struct {
+	validators []stakingtypes.Validator
+}
+
+func (m mockStaking) GetBondedValidatorsByPower(context.Context) ([]stakingtypes.Validator, error) {
+	return m.validators, nil
+}
+
+func (m mockStaking) GetValidator(_ context.Context, addr sdk.ValAddress) (stakingtypes.Validator, error) {
+	for _, validator := range m.validators {
+		if validator.OperatorAddress == addr.String() {
+			return validator, nil
+		}
+	}
+	return stakingtypes.Validator{}, stakingtypes.ErrNoValidatorFound
+}
+
+func (mockStaking) PowerReduction(context.Context) sdkmath.Int {
+	return sdk.DefaultPowerReduction
+}
+
+func (mockStaking) ValidatorAddressCodec() address.Codec {
+	return addresscodec.NewBech32Codec(sdk.GetConfig().GetBech32ValidatorAddrPrefix())
+}
+
+// mockSlashing records the penalties of the validators, by consensus address.
+type mockSlashing struct {
+	slashed map[string]sdkmath.LegacyDec
+	jailed  map[string]time.Time
+}
+
+func (m *mockSlashing) Slash(_ context.Context, consAddr sdk.ConsAddress, fraction sdkmath.LegacyDec, _, _ int64) error {
+	if m.slashed == nil {
+		m.slashed = make(map[string]sdkmath.LegacyDec)
+	}
+	m.slashed[consAddr.String()] = fraction
+	return nil
+}
+
+func (m *mockSlashing) Jail(_ context.Context, consAddr sdk.ConsAddress) error {
+	if m.jailed == nil {
+		m.jailed = make(map[string]time.Time)
+	}
+	m.jailed[consAddr.String()] = time.Time{}
+	return nil
+}
+
+func (m *mockSlashing) JailUntil(_ context.Context, consAddr sdk.ConsAddress, jailTime time.Time) error {
+	m.jailed[consAddr.String()] = jailTime
+	return nil
+}
+
+func (*mockSlashing) DowntimeJailDuration(context.Context) (time.Duration, error) {
+	return 10 * time.Minute, nil
+}
+
+// createLock creates the lock as the envoy MsgCreateLock does, flagging the
+// block as the circuit breaker of the msg router does in dev builds.
+func createLock(t *testing.T, ctx sdk.Context, k Keeper, locks collections.Map[string, envoy.Lock], lock envoy.Lock) {
+	t.Helper()
+
+	require.NoError(t, k.Created.Set(ctx, true))
+	require.NoError(t, locks.Set(ctx, lock.Name, lock))
+}
+
+// endBlock runs the end-blocker at the given height, and returns its events.
+func endBlock(t *testing.T, ctx sdk.Context, k Keeper, height int64) sdk.Events {
+	t.Helper()
+
+	ctx = ctx.WithBlockHeight(height).WithEventManager(sdk.NewEventManager())
+	require.NoError(t, k.EndBlock(ctx))
+
+	return ctx.EventManager().Events()
+}
+
+func TestEndBlockExpiresLocks(t *testing.T) {
+	ctx, k, locks := setupKeeper(t)
+
+	for _, lock := range []envoy.Lock{
+		{Name: "short", Envoy: "alice", AtBlock: 1, NumBlocks: 3},
+		{Name: "long", Envoy: "bob", AtBlock: 5, NumBlocks: 10},
+		{Name: "forever", Envoy: "bob", AtBlock: 1, NumBlocks: math.MaxUint64},
+	} {
+		createLock(t, ctx, k, locks, lock)
+	}
+
+	// short covers heights 1 to 3, and is expired at the end of height 3
+	require.Empty(t, endBlock(t, ctx, k, 2))
+	events := endBlock(t, ctx, k, 3)
+	require.Equal(t, sdk.Events{sdk.NewEvent(
+		EventTypeLockExpired,
+		sdk.NewAttribute(AttributeKeyName, "short"),
+		sdk.NewAttribute(AttributeKeyEnvoy, "alice"),
+		sdk.NewAttribute(AttributeKeyAtBlock, "1"),
+		sdk.NewAttribute(AttributeKeyNumBlocks, "3"),
+	)}, events)
+
+	has, err := locks.Has(ctx, "short")
+	require.NoError(t, err)
+	require.False(t, has)
+
+	history, err := k.LockHistory(ctx, "short")
+	require.NoError(t, err)
+	require.Equal(t, []Lease{{Name: "short", Envoy: "alice", AtBlock: 1, NumBlocks: 3, ExpiredAt: 4}}, history)
+
+	// long is renewed before it expires, and is moved in the queue
+	createLock(t, ctx, k, locks, envoy.Lock{Name: "long", Envoy: "alice", AtBlock: 5, NumBlocks: 20})
+	require.Empty(t, endBlock(t, ctx, k, 14))
+	require.Len(t, endBlock(t, ctx, k, 24), 1)
+
+	history, err = k.LockHistory(ctx, "long")
+	require.NoError(t, err)
+	require.Equal(t, []Lease{{Name: "long", Envoy: "alice", AtBlock: 5, NumBlocks: 20, ExpiredAt: 25}}, history)
+
+	// the lock overflowing the heights never expires
+	has, err = locks.Has(ctx, "forever")
+	require.NoError(t, err)
+	require.True(t, has)
+
+	expiry, err := k.ExpiryIndex.Get(ctx, "forever")
+	require.NoError(t, err)
+	require.Equal(t, uint64(math.MaxUint64), expiry)
+}
+
+func TestEndBlockIndexesCreatedLocks(t *testing.T) {
+	ctx, k, locks := setupKeeper(t)
+
+	// a lock set without a msg, as the leases are, is not walked to
+	require.NoError(t, locks.Set(ctx, "lock1", envoy.Lock{Name: "lock1", Envoy: "alice", AtBlock: 1, NumBlocks: 5}))
+	endBlock(t, ctx, k, 1)
+
+	has, err := k.ExpiryIndex.Has(ctx, "lock1")
+	require.NoError(t, err)
+	require.False(t, has)
+
+	// the block creating a lock queues every lock, and is not flagged anymore
+	createLock(t, ctx, k, locks, envoy.Lock{Name: "lock2", Envoy: "bob", AtBlock: 2, NumBlocks: 5})
+	endBlock(t, ctx, k, 2)
+
+	for name, expiry := range map[string]uint64{"lock1": 6, "lock2": 7} {
+		queued, err := k.ExpiryIndex.Get(ctx, name)
+		require.NoError(t, err)
+		require.Equal(t, expiry, queued)
+	}
+
+	created, err := k.Created.Has(ctx)
+	require.NoError(t, err)
+	require.False(t, created)
+}
+
+func TestEndBlockRemovedLock(t *testing.T) {
+	ctx, k, locks := setupKeeper(t)
+
+	createLock(t, ctx, k, locks, envoy.Lock{Name: "lock1", Envoy: "alice", AtBlock: 1, NumBlocks: 5})
+	require.Empty(t, endBlock(t, ctx, k, 1))
+
+	// a lock removed since it was queued leaves no history
+	require.NoError(t, locks.Remove(ctx, "lock1"))
+	require.Empty(t, endBlock(t, ctx, k, 5))
+
+	history, err := k.LockHistory(ctx, "lock1")
+	require.NoError(t, err)
+	require.Empty(t, history)
+
+	has, err := k.ExpiryIndex.Has(ctx, "lock1")
+	require.NoError(t, err)
+	require.False(t, has)
+}
+
+func TestHistoryIsBounded(t *testing.T) {
+	ctx, k, locks := setupKeeper(t)
+
+	const leases = HistoryLength + 3
+	for h := uint64(1); h <= leases; h++ {
+		createLock(t, ctx, k, locks, envoy.Lock{Name: "lock1", Envoy: "alice", AtBlock: h, NumBlocks: 1})
+		require.Len(t, endBlock(t, ctx, k, int64(h)), 1)
+	}
+
+	history, err := k.LockHistory(ctx, "lock1")
+	require.NoError(t, err)
+	require.Len(t, history, HistoryLength)
+
+	// the oldest leases are dropped
+	require.Equal(t, uint64(leases-HistoryLength+2), history[0].ExpiredAt)
+	require.Equal(t, uint64(leases+1), history[HistoryLength-1].ExpiredAt)
+}
+
+func TestGenesis(t *testing.T) {
+	ctx, k, locks := setupKeeper(t)
+
+	require.NoError(t, locks.Set(ctx, "lock1", envoy.Lock{Name: "lock1", Envoy: "alice", AtBlock: 10, NumBlocks: 5}))
+
+	gs := GenesisState{
+		Params:  Params{MissThreshold: 5, SlashFraction: sdkmath.LegacyNewDecWithPrec(5, 1)},
+		Actions: []Action{{Name: "checkpoint", LeaseBlocks: 10, Eligibility: Eligibility{MinPower: 1}}},
+		History: []Lease{
+			{Name: "lock1", Envoy: "bob", AtBlock: 1, NumBlocks: 5, ExpiredAt: 6},
+			{Name: "lock2", Envoy: "alice", AtBlock: 2, NumBlocks: 2, ExpiredAt: 4},
+		},
+		Misses: []Misses{{Name: "lock1", Count: 3}},
+	}
+	require.NoError(t, k.InitGenesis(ctx, gs))
+
+	// the imported locks are queued
+	expiry, err := k.ExpiryIndex.Get(ctx, "lock1")
+	require.NoError(t, err)
+	require.Equal(t, uint64(15), expiry)
+
+	exported, err := k.ExportGenesis(ctx)
+	require.NoError(t, err)
+	require.Equal(t, &gs, exported)
+}
+
+func TestGenesisValidate(t *testing.T) {
+	history := func(n int) []Lease {
+		leases := make([]Lease, n)
+		for i := range leases {
+			leases[i] = Lease{Name: "lock1", ExpiredAt: uint64(i + 1)}
+		}
+		return leases
+	}
+	params := DefaultParams()
+
+	tests := []struct {
+		name    string
+		genesis GenesisState
+		wantErr string
+	}{
+		{name: "default", genesis: *DefaultGenesis(nil)},
+		{name: "full history", genesis: GenesisState{Params: params, History: history(HistoryLength)}},
+		{
+			name:    "history too long",
+			genesis: GenesisState{Params: params, History: history(HistoryLength + 1)},
+			wantErr: "more than",
+		},
+		{
+			name:    "duplicate lease",
+			genesis: GenesisState{Params: params, History: append(history(2), history(1)...)},
+			wantErr: "duplicate lease",
+		},
+		{
+			name:    "no name",
+			genesis: GenesisState{Params: params, History: []Lease{{ExpiredAt: 1}}},
+			wantErr: "without lock name",
+		},
+		{
+			name:    "action without lease blocks",
+			genesis: GenesisState{Params: params, Actions: []Action{{Name: "checkpoint"}}},
+			wantErr: "no lease blocks",
+		},
+		{
+			name: "duplicate action",
+			genesis: GenesisState{Params: params, Actions: []Action{
+				{Name: "checkpoint", LeaseBlocks: 10},
+				{Name: "checkpoint", LeaseBlocks: 20},
+			}},
+			wantErr: "duplicate action",
+		},
+		{
+			name: "invalid eligible validator",
+			genesis: GenesisState{Params: params, Actions: []Action{
+				{Name: "checkpoint", LeaseBlocks: 10, Eligibility: Eligibility{Validators: []string{"alice"}}},
+			}},
+			wantErr: "eligible validator alice",
+		},
+		{
+			name:    "no params",
+			genesis: GenesisState{},
+			wantErr: "slash fraction not set",
+		},
+		{
+			name:    "slash fraction above one",
+			genesis: GenesisState{Params: Params{SlashFraction: sdkmath.LegacyNewDec(2)}},
+			wantErr: "not between 0 and 1",
+		},
+		{
+			name:    "duplicate misses",
+			genesis: GenesisState{Params: params, Misses: []Misses{{Name: "lock1"}, {Name: "lock1"}}},
+			wantErr: "duplicate misses",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.genesis.Validate()
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
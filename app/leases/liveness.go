@@ -0,0 +1,178 @@
+package leases
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+
+	"cosmossdk.io/collections"
+	sdkmath "cosmossdk.io/math"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+
+	"github.com/polygon/envoy"
+)
+
+// SlashingKeeper penalises the validators whose operator misses the action of
+// the lock it holds.
+type SlashingKeeper interface {
+	Slash(ctx context.Context, consAddr sdk.ConsAddress, fraction sdkmath.LegacyDec, power, distributionHeight int64) error
+	Jail(ctx context.Context, consAddr sdk.ConsAddress) error
+	JailUntil(ctx context.Context, consAddr sdk.ConsAddress, jailTime time.Time) error
+	DowntimeJailDuration(ctx context.Context) (time.Duration, error)
+}
+
+// TrackLiveness counts the blocks the holders of the action locks missed at
+// the given height, from the actions each holder completed at it, and
+// penalises the holders missing more than the miss threshold in a row.
+//
+// The holders absent from the completions didn't vote for the block, which
+// x/slashing penalises: their misses are left as they are, neither counted nor
+// reset.
+//
+// A holder starts the job of its action at the end of the first block of its
+// lease, so the completions are only expected from the next block.
+func (k Keeper) TrackLiveness(ctx context.Context, height uint64, completed map[string][]string) error {
+	params, err := k.Params.Get(ctx)
+	if err != nil {
+		return err
+	}
+
+	var actions []Action
+	if err := k.Actions.Walk(ctx, nil, func(_ string, action Action) (bool, error) {
+		actions = append(actions, action)
+		return false, nil
+	}); err != nil {
+		return err
+	}
+
+	for _, action := range actions {
+		name := action.Name
+		lock, err := k.locks.Get(ctx, name)
+		if errors.Is(err, collections.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if height <= lock.AtBlock || height >= Expiry(lock) {
+			continue
+		}
+
+		actions, voted := completed[lock.Envoy]
+		if !voted {
+			continue
+		}
+		if slices.Contains(actions, name) {
+			if err := k.Misses.Remove(ctx, name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		misses, err := k.Misses.Get(ctx, name)
+		if err != nil && !errors.Is(err, collections.ErrNotFound) {
+			return err
+		}
+		misses++
+
+		if params.MissThreshold == 0 || misses <= params.MissThreshold {
+			if err := k.Misses.Set(ctx, name, misses); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := k.penalise(ctx, params, action, lock, height); err != nil {
+			return err
+		}
+		if err := k.forfeit(ctx, lock, height+1, misses); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// penalise slashes and jails the validator of the lock holder, as set by the
+// params, for the action it missed at the given height. A holder which is no
+// longer a validator, or no longer listed in the validators of the action when
+// it lists them, is not penalised.
+func (k Keeper) penalise(ctx context.Context, params Params, action Action, lock envoy.Lock, height uint64) error {
+	holder, err := sdk.AccAddressFromBech32(lock.Envoy)
+	if err != nil {
+		return err
+	}
+
+	validator, err := k.staking.GetValidator(ctx, sdk.ValAddress(holder))
+	if errors.Is(err, stakingtypes.ErrNoValidatorFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if len(action.Eligibility.Validators) > 0 && !slices.Contains(action.Eligibility.Validators, validator.GetOperator()) {
+		return nil
+	}
+
+	consAddr, err := validator.GetConsAddr()
+	if err != nil {
+		return err
+	}
+
+	if params.SlashFraction.IsPositive() {
+		power := validator.GetConsensusPower(k.staking.PowerReduction(ctx))
+		if err := k.slashing.Slash(ctx, consAddr, params.SlashFraction, power, int64(height)); err != nil {
+			return err
+		}
+	}
+
+	if params.Jail && !validator.IsJailed() {
+		if err := k.slashing.Jail(ctx, consAddr); err != nil {
+			return err
+		}
+
+		duration, err := k.slashing.DowntimeJailDuration(ctx)
+		if err != nil {
+			return err
+		}
+		jailTime := sdk.UnwrapSDKContext(ctx).BlockHeader().Time.Add(duration)
+		if err := k.slashing.JailUntil(ctx, consAddr, jailTime); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// forfeit ends the lease of the lock before the given height, recording it in
+// its history. The lock of the action is leased to another validator by the
+// end-blocker.
+func (k Keeper) forfeit(ctx context.Context, lock envoy.Lock, height, misses uint64) error {
+	queued, err := k.ExpiryIndex.Get(ctx, lock.Name)
+	switch {
+	case err == nil:
+		if err := k.ExpiryQueue.Remove(ctx, collections.Join(queued, lock.Name)); err != nil {
+			return err
+		}
+		if err := k.ExpiryIndex.Remove(ctx, lock.Name); err != nil {
+			return err
+		}
+	case !errors.Is(err, collections.ErrNotFound):
+		return err
+	}
+
+	if err := k.locks.Remove(ctx, lock.Name); err != nil {
+		return err
+	}
+	if err := k.Misses.Remove(ctx, lock.Name); err != nil {
+		return err
+	}
+	if err := k.record(ctx, lock, height); err != nil {
+		return err
+	}
+
+	emitLockEvent(ctx, EventTypeLockForfeited, lock, sdk.NewAttribute(AttributeKeyMisses, strconv.FormatUint(misses, 10)))
+	return nil
+}
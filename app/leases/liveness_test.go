@@ -0,0 +1,248 @@
+package leases
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	cmtproto "github.com/cometbft/cometbft/proto/tendermint/types"
+	"github.com/stretchr/testify/require"
+
+	sdkmath "cosmossdk.io/math"
+
+	"github.com/cosmos/cosmos-sdk/baseapp"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	"github.com/cosmos/cosmos-sdk/crypto/keys/ed25519"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	moduletestutil "github.com/cosmos/cosmos-sdk/types/module/testutil"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+
+	"github.com/polygon/envoy"
+)
+
+// newKeyedValidator returns a bonded validator whose operator address is
+// filled with b, with a consensus key.
+func newKeyedValidator(t *testing.T, b byte) stakingtypes.Validator {
+	t.Helper()
+
+	validator, err := stakingtypes.NewValidator(
+		sdk.ValAddress(bytes.Repeat([]byte{b}, 20)).String(),
+		ed25519.GenPrivKey().PubKey(),
+		stakingtypes.Description{},
+	)
+	require.NoError(t, err)
+	validator.Status = stakingtypes.Bonded
+	validator.Tokens = sdk.TokensFromConsensusPower(10, sdk.DefaultPowerReduction)
+
+	return validator
+}
+
+func TestTrackLiveness(t *testing.T) {
+	validator := newKeyedValidator(t, 1)
+	consAddr, err := validator.GetConsAddr()
+	require.NoError(t, err)
+
+	ctx, k, locks := setupKeeper(t, validator)
+	blockTime := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ctx = ctx.WithBlockHeader(cmtproto.Header{Time: blockTime})
+
+	require.NoError(t, k.InitGenesis(ctx, GenesisState{
+		Params: Params{MissThreshold: 2, SlashFraction: sdkmath.LegacyNewDecWithPrec(1, 2), Jail: true},
+		Actions: []Action{{
+			Name:        "checkpoint",
+			LeaseBlocks: 100,
+			Eligibility: Eligibility{Validators: []string{validator.GetOperator()}},
+		}},
+	}))
+	lock := envoy.Lock{Name: "checkpoint", Envoy: holder(1), AtBlock: 10, NumBlocks: 100}
+	require.NoError(t, locks.Set(ctx, lock.Name, lock))
+	require.NoError(t, k.IndexLocks(ctx))
+
+	completed := map[string][]string{holder(1): {"checkpoint"}}
+	missed := map[string][]string{holder(1): {"other"}}
+
+	// the job is not running yet at the first block of the lease
+	require.NoError(t, k.TrackLiveness(ctx, 10, missed))
+	has, err := k.Misses.Has(ctx, "checkpoint")
+	require.NoError(t, err)
+	require.False(t, has)
+
+	// the misses are counted in a row
+	require.NoError(t, k.TrackLiveness(ctx, 11, missed))
+	require.NoError(t, k.TrackLiveness(ctx, 12, completed))
+	require.NoError(t, k.TrackLiveness(ctx, 13, missed))
+	require.NoError(t, k.TrackLiveness(ctx, 14, missed))
+	misses, err := k.Misses.Get(ctx, "checkpoint")
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), misses)
+	require.Empty(t, k.slashing.(*mockSlashing).slashed)
+
+	// the absence of the holder, handled by x/slashing, is not a miss
+	require.NoError(t, k.TrackLiveness(ctx, 15, nil))
+	misses, err = k.Misses.Get(ctx, "checkpoint")
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), misses)
+
+	// past the threshold, the holder is penalised and forfeits the lease
+	ctx = ctx.WithEventManager(sdk.NewEventManager())
+	require.NoError(t, k.TrackLiveness(ctx, 16, missed))
+
+	slashing := k.slashing.(*mockSlashing)
+	require.Equal(t, "0.010000000000000000", slashing.slashed[sdk.ConsAddress(consAddr).String()].String())
+	require.Equal(t, blockTime.Add(10*time.Minute), slashing.jailed[sdk.ConsAddress(consAddr).String()])
+
+	require.Equal(t, sdk.Events{sdk.NewEvent(
+		EventTypeLockForfeited,
+		sdk.NewAttribute(AttributeKeyName, "checkpoint"),
+		sdk.NewAttribute(AttributeKeyEnvoy, holder(1)),
+		sdk.NewAttribute(AttributeKeyAtBlock, "10"),
+		sdk.NewAttribute(AttributeKeyNumBlocks, "100"),
+		sdk.NewAttribute(AttributeKeyMisses, "3"),
+	)}, ctx.EventManager().Events())
+
+	has, err = locks.Has(ctx, "checkpoint")
+	require.NoError(t, err)
+	require.False(t, has)
+
+	has, err = k.ExpiryIndex.Has(ctx, "checkpoint")
+	require.NoError(t, err)
+	require.False(t, has)
+
+	has, err = k.Misses.Has(ctx, "checkpoint")
+	require.NoError(t, err)
+	require.False(t, has)
+
+	history, err := k.LockHistory(ctx, "checkpoint")
+	require.NoError(t, err)
+	require.Equal(t, []Lease{NewLease(lock, 17)}, history)
+}
+
+func TestTrackLivenessUnlisted(t *testing.T) {
+	ctx, k, locks := setupKeeper(t, newKeyedValidator(t, 1))
+
+	require.NoError(t, k.InitGenesis(ctx, GenesisState{
+		Params: Params{MissThreshold: 2, SlashFraction: sdkmath.LegacyNewDecWithPrec(1, 2), Jail: true},
+		Actions: []Action{{
+			Name:        "checkpoint",
+			LeaseBlocks: 100,
+			Eligibility: Eligibility{Validators: []string{newKeyedValidator(t, 2).GetOperator()}},
+		}},
+	}))
+	lock := envoy.Lock{Name: "checkpoint", Envoy: holder(1), AtBlock: 10, NumBlocks: 100}
+	require.NoError(t, locks.Set(ctx, lock.Name, lock))
+	require.NoError(t, k.IndexLocks(ctx))
+
+	// the holder is no longer listed in the validators of the action: it
+	// forfeits the lease past the threshold, without being penalised
+	for height := uint64(11); height <= 13; height++ {
+		require.NoError(t, k.TrackLiveness(ctx, height, map[string][]string{holder(1): nil}))
+	}
+
+	slashing := k.slashing.(*mockSlashing)
+	require.Empty(t, slashing.slashed)
+	require.Empty(t, slashing.jailed)
+
+	has, err := locks.Has(ctx, "checkpoint")
+	require.NoError(t, err)
+	require.False(t, has)
+
+	history, err := k.LockHistory(ctx, "checkpoint")
+	require.NoError(t, err)
+	require.Equal(t, []Lease{NewLease(lock, 14)}, history)
+}
+
+func TestTrackLivenessWithoutThreshold(t *testing.T) {
+	ctx, k, locks := setupKeeper(t, newKeyedValidator(t, 1))
+
+	require.NoError(t, k.InitGenesis(ctx, GenesisState{
+		Params:  Params{SlashFraction: sdkmath.LegacyZeroDec()},
+		Actions: []Action{{Name: "checkpoint", LeaseBlocks: 100}},
+	}))
+	require.NoError(t, locks.Set(ctx, "checkpoint", envoy.Lock{Name: "checkpoint", Envoy: holder(1), AtBlock: 1, NumBlocks: 100}))
+
+	// the misses are only counted
+	for height := uint64(2); height < 50; height++ {
+		require.NoError(t, k.TrackLiveness(ctx, height, map[string][]string{holder(1): nil}))
+	}
+
+	misses, err := k.Misses.Get(ctx, "checkpoint")
+	require.NoError(t, err)
+	require.Equal(t, uint64(48), misses)
+
+	has, err := locks.Has(ctx, "checkpoint")
+	require.NoError(t, err)
+	require.True(t, has)
+	require.Empty(t, k.slashing.(*mockSlashing).jailed)
+}
+
+func TestUpdateParams(t *testing.T) {
+	ctx, k, _ := setupKeeper(t)
+
+	params := Params{MissThreshold: 5, SlashFraction: sdkmath.LegacyNewDecWithPrec(1, 1), Jail: true}
+	require.ErrorIs(t, k.UpdateParams(ctx, holder(1), params), ErrInvalidAuthority)
+	require.ErrorIs(t, k.UpdateParams(ctx, authority, Params{SlashFraction: sdkmath.LegacyNewDec(-1)}), ErrInvalidParams)
+
+	require.NoError(t, k.UpdateParams(ctx, authority, params))
+	stored, err := k.Params.Get(ctx)
+	require.NoError(t, err)
+	require.Equal(t, params.MissThreshold, stored.MissThreshold)
+	require.True(t, params.SlashFraction.Equal(stored.SlashFraction))
+	require.Equal(t, params.Jail, stored.Jail)
+}
+
+func TestMsgUpdateParams(t *testing.T) {
+	ctx, k, _ := setupKeeper(t)
+
+	encCfg := moduletestutil.MakeTestEncodingConfig(AppModuleBasic{})
+	router := baseapp.NewMsgServiceRouter()
+	router.SetInterfaceRegistry(encCfg.InterfaceRegistry)
+	RegisterMsgServer(router, NewMsgServerImpl(k))
+
+	msg := &MsgUpdateParams{
+		Authority: authority,
+		Params:    Params{MissThreshold: 5, SlashFraction: sdkmath.LegacyNewDecWithPrec(1, 1), Jail: true},
+	}
+
+	// the msg is signed by the authority, and goes through an Any as in a
+	// proposal, in protobuf and in JSON
+	signers, _, err := encCfg.Codec.GetMsgV1Signers(msg)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{sdk.MustAccAddressFromBech32(authority)}, signers)
+
+	packed, err := codectypes.NewAnyWithValue(msg)
+	require.NoError(t, err)
+	require.Equal(t, "/procyon.leases.v1.MsgUpdateParams", packed.TypeUrl)
+	var unpacked sdk.Msg
+	require.NoError(t, encCfg.Codec.UnpackAny(packed, &unpacked))
+	require.Equal(t, msg.Authority, unpacked.(*MsgUpdateParams).Authority)
+	require.True(t, msg.Params.SlashFraction.Equal(unpacked.(*MsgUpdateParams).Params.SlashFraction))
+
+	bz, err := encCfg.Codec.MarshalInterfaceJSON(msg)
+	require.NoError(t, err)
+	require.Contains(t, string(bz), `"@type":"/procyon.leases.v1.MsgUpdateParams"`)
+	require.NoError(t, encCfg.Codec.UnmarshalInterfaceJSON(bz, &unpacked))
+	require.Equal(t, msg.Params.MissThreshold, unpacked.(*MsgUpdateParams).Params.MissThreshold)
+
+	// and it is signed in amino JSON under its amino name, as gov proposals
+	// are by ledger devices
+	bz, err = encCfg.Amino.MarshalJSON(msg)
+	require.NoError(t, err)
+	require.Contains(t, string(bz), `"type":"procyon/leases/MsgUpdateParams"`)
+	require.Contains(t, string(bz), `"miss_threshold":"5"`)
+
+	handler := router.Handler(msg)
+	require.NotNil(t, handler)
+
+	_, err = handler(ctx, &MsgUpdateParams{Authority: holder(1), Params: msg.Params})
+	require.ErrorIs(t, err, ErrInvalidAuthority)
+	_, err = handler(ctx, &MsgUpdateParams{Authority: authority, Params: Params{SlashFraction: sdkmath.LegacyNewDec(-1)}})
+	require.ErrorIs(t, err, ErrInvalidParams)
+
+	_, err = handler(ctx, msg)
+	require.NoError(t, err)
+	stored, err := k.Params.Get(ctx)
+	require.NoError(t, err)
+	require.Equal(t, msg.Params.MissThreshold, stored.MissThreshold)
+	require.True(t, msg.Params.SlashFraction.Equal(stored.SlashFraction))
+	require.True(t, stored.Jail)
+}
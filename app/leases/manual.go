@@ -0,0 +1,8 @@
+//go:build !dev
+
+package leases
+
+// ManualLocks reports whether the envoy locks can be created by hand, with a
+// MsgCreateLock. They are leased by the chain, and only dev builds, built with
+// the dev tag, let them be created by hand.
+const ManualLocks = false
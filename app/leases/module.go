@@ -0,0 +1,154 @@
+package leases
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	gwruntime "github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"github.com/spf13/cobra"
+
+	"cosmossdk.io/core/appmodule"
+
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/codec"
+	"github.com/cosmos/cosmos-sdk/codec/legacy"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/module"
+	"github.com/cosmos/cosmos-sdk/types/msgservice"
+)
+
+// ConsensusVersion is the version of the module state.
+const ConsensusVersion = 1
+
+var (
+	_ module.AppModuleBasic      = AppModuleBasic{}
+	_ module.HasGenesis          = AppModule{}
+	_ module.HasConsensusVersion = AppModule{}
+	_ module.HasServices         = AppModule{}
+	_ appmodule.AppModule        = AppModule{}
+	_ appmodule.HasEndBlocker    = AppModule{}
+)
+
+// AppModuleBasic is the basic module of the lock leases. Its only message is
+// the MsgUpdateParams of gov, and it has no gRPC query service, its state
+// being queried from the store.
+type AppModuleBasic struct {
+	// actions are the lockable actions of the default genesis
+	actions []Action
+}
+
+// NewAppModuleBasic returns the basic module, with the lockable actions
+// declared in the app config.
+func NewAppModuleBasic(actions []Action) AppModuleBasic {
+	return AppModuleBasic{actions: actions}
+}
+
+// Name returns the module name.
+func (AppModuleBasic) Name() string { return ModuleName }
+
+// RegisterLegacyAminoCodec registers the MsgUpdateParams under its amino
+// name, for the gov proposals carrying it to be signed in amino JSON.
+func (AppModuleBasic) RegisterLegacyAminoCodec(cdc *codec.LegacyAmino) {
+	legacy.RegisterAminoMsg(cdc, &MsgUpdateParams{}, "procyon/leases/MsgUpdateParams")
+}
+
+// RegisterInterfaces registers the MsgUpdateParams.
+func (AppModuleBasic) RegisterInterfaces(registry codectypes.InterfaceRegistry) {
+	registry.RegisterImplementations((*sdk.Msg)(nil), &MsgUpdateParams{})
+	msgservice.RegisterMsgServiceDesc(registry, &_Msg_serviceDesc)
+}
+
+// RegisterGRPCGatewayRoutes does nothing, the module has no gRPC services.
+func (AppModuleBasic) RegisterGRPCGatewayRoutes(client.Context, *gwruntime.ServeMux) {}
+
+// DefaultGenesis returns the genesis of the lockable actions, without history.
+func (am AppModuleBasic) DefaultGenesis(codec.JSONCodec) json.RawMessage {
+	bz, err := json.Marshal(DefaultGenesis(am.actions))
+	if err != nil {
+		panic(err)
+	}
+	return bz
+}
+
+// ValidateGenesis validates the genesis of the module, which the genesis of a
+// chain started before the module may not have.
+func (AppModuleBasic) ValidateGenesis(_ codec.JSONCodec, _ client.TxEncodingConfig, bz json.RawMessage) error {
+	if len(bz) == 0 {
+		return nil
+	}
+
+	var gs GenesisState
+	if err := json.Unmarshal(bz, &gs); err != nil {
+		return fmt.Errorf("failed to unmarshal %s genesis state: %w", ModuleName, err)
+	}
+	return gs.Validate()
+}
+
+// GetQueryCmd returns the query commands of the module.
+func (AppModuleBasic) GetQueryCmd() *cobra.Command {
+	return GetQueryCmd()
+}
+
+// AppModule expires and assigns the envoy locks at the end of each block. It
+// must end blocks after the envoy module, and init its genesis after it. The
+// liveness of the holders is tracked by the app, before each block.
+type AppModule struct {
+	AppModuleBasic
+
+	keeper Keeper
+}
+
+// NewAppModule returns the module of the keeper, with the lockable actions
+// declared in the app config.
+func NewAppModule(keeper Keeper, actions []Action) AppModule {
+	return AppModule{AppModuleBasic: NewAppModuleBasic(actions), keeper: keeper}
+}
+
+// IsOnePerModuleType implements the depinject.OnePerModuleType interface.
+func (AppModule) IsOnePerModuleType() {}
+
+// IsAppModule implements the appmodule.AppModule interface.
+func (AppModule) IsAppModule() {}
+
+// RegisterServices registers the Msg service.
+func (am AppModule) RegisterServices(cfg module.Configurator) {
+	RegisterMsgServer(cfg.MsgServer(), NewMsgServerImpl(am.keeper))
+}
+
+// ConsensusVersion implements module.HasConsensusVersion.
+func (AppModule) ConsensusVersion() uint64 { return ConsensusVersion }
+
+// InitGenesis sets the params, seeds the actions, imports the history and the
+// misses, and queues the envoy locks.
+func (am AppModule) InitGenesis(ctx sdk.Context, _ codec.JSONCodec, bz json.RawMessage) {
+	var gs GenesisState
+	if err := json.Unmarshal(bz, &gs); err != nil {
+		panic(fmt.Errorf("failed to unmarshal %s genesis state: %w", ModuleName, err))
+	}
+
+	if err := am.keeper.InitGenesis(ctx, gs); err != nil {
+		panic(err)
+	}
+}
+
+// ExportGenesis exports the params, the actions, the history and the misses.
+func (am AppModule) ExportGenesis(ctx sdk.Context, _ codec.JSONCodec) json.RawMessage {
+	gs, err := am.keeper.ExportGenesis(ctx)
+	if err != nil {
+		panic(err)
+	}
+
+	bz, err := json.Marshal(gs)
+	if err != nil {
+		panic(err)
+	}
+	return bz
+}
+
+// EndBlock expires the locks which do not cover the next height, and leases
+// the locks of the actions from it.
+func (am AppModule) EndBlock(ctx context.Context) error {
+	return am.keeper.EndBlock(ctx)
+}
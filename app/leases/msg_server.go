@@ -0,0 +1,25 @@
+package leases
+
+import (
+	"context"
+)
+
+type msgServer struct {
+	keeper Keeper
+}
+
+var _ MsgServer = msgServer{}
+
+// NewMsgServerImpl returns the Msg service of the keeper.
+func NewMsgServerImpl(k Keeper) MsgServer {
+	return msgServer{keeper: k}
+}
+
+// UpdateParams sets the params, checking the msg is sent by the authority.
+func (s msgServer) UpdateParams(ctx context.Context, msg *MsgUpdateParams) (*MsgUpdateParamsResponse, error) {
+	if err := s.keeper.UpdateParams(ctx, msg.Authority, msg.Params); err != nil {
+		return nil, err
+	}
+
+	return &MsgUpdateParamsResponse{}, nil
+}
@@ -0,0 +1,57 @@
+package leases
+
+import (
+	"context"
+
+	"cosmossdk.io/errors"
+	sdkmath "cosmossdk.io/math"
+)
+
+var (
+	// ErrInvalidAuthority is returned when the params are updated by another
+	// account than the module authority.
+	ErrInvalidAuthority = errors.Register(ModuleName, 3, "invalid authority")
+	// ErrInvalidParams is returned for invalid params.
+	ErrInvalidParams = errors.Register(ModuleName, 4, "invalid params")
+)
+
+// DefaultParams returns the default params: the holder missing more than 20
+// blocks in a row is jailed and slashed as for downtime, and forfeits its
+// lease.
+func DefaultParams() Params {
+	return Params{
+		MissThreshold: 20,
+		SlashFraction: sdkmath.LegacyNewDecWithPrec(1, 2),
+		Jail:          true,
+	}
+}
+
+// Validate checks the params.
+func (p Params) Validate() error {
+	if p.SlashFraction.IsNil() {
+		return errors.Wrap(ErrInvalidParams, "slash fraction not set")
+	}
+	if p.SlashFraction.IsNegative() || p.SlashFraction.GT(sdkmath.LegacyOneDec()) {
+		return errors.Wrapf(ErrInvalidParams, "slash fraction %s not between 0 and 1", p.SlashFraction)
+	}
+
+	return nil
+}
+
+// UpdateParams sets the params, on behalf of the module authority, as the
+// MsgUpdateParams of a gov proposal does.
+func (k Keeper) UpdateParams(ctx context.Context, authority string, params Params) error {
+	if authority != k.authority {
+		return errors.Wrapf(ErrInvalidAuthority, "expected %s, got %s", k.authority, authority)
+	}
+	if err := params.Validate(); err != nil {
+		return err
+	}
+
+	return k.Params.Set(ctx, params)
+}
+
+// GetAuthority returns the module authority, the gov module account.
+func (k Keeper) GetAuthority() string {
+	return k.authority
+}
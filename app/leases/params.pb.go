@@ -0,0 +1,409 @@
+// Code generated by protoc-gen-gogo. DO NOT EDIT.
+// source: procyon/leases/v1/params.proto
+
+package leases
+
+import (
+	cosmossdk_io_math "cosmossdk.io/math"
+	fmt "fmt"
+	_ "github.com/cosmos/cosmos-proto"
+	_ "github.com/cosmos/cosmos-sdk/types/tx/amino"
+	_ "github.com/cosmos/gogoproto/gogoproto"
+	proto "github.com/cosmos/gogoproto/proto"
+	io "io"
+	math "math"
+	math_bits "math/bits"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
+
+// Params are the penalties of the lock holders which miss their action.
+type Params struct {
+	// miss_threshold is the number of consecutive blocks the holder of a lock
+	// may miss its action before it is penalised, none when zero.
+	MissThreshold uint64 `protobuf:"varint,1,opt,name=miss_threshold,json=missThreshold,proto3" json:"miss_threshold,string"`
+	// slash_fraction is the fraction of the stake of the holder's validator
+	// slashed on penalty.
+	SlashFraction cosmossdk_io_math.LegacyDec `protobuf:"bytes,2,opt,name=slash_fraction,json=slashFraction,proto3,customtype=cosmossdk.io/math.LegacyDec" json:"slash_fraction"`
+	// jail jails the holder's validator on penalty, for the downtime jail
+	// duration of the slashing params.
+	Jail bool `protobuf:"varint,3,opt,name=jail,proto3" json:"jail"`
+}
+
+func (m *Params) Reset()         { *m = Params{} }
+func (m *Params) String() string { return proto.CompactTextString(m) }
+func (*Params) ProtoMessage()    {}
+func (*Params) Descriptor() ([]byte, []int) {
+	return fileDescriptor_30ad7965cbc721e4, []int{0}
+}
+func (m *Params) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *Params) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_Params.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *Params) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Params.Merge(m, src)
+}
+func (m *Params) XXX_Size() int {
+	return m.Size()
+}
+func (m *Params) XXX_DiscardUnknown() {
+	xxx_messageInfo_Params.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Params proto.InternalMessageInfo
+
+func (m *Params) GetMissThreshold() uint64 {
+	if m != nil {
+		return m.MissThreshold
+	}
+	return 0
+}
+
+func (m *Params) GetJail() bool {
+	if m != nil {
+		return m.Jail
+	}
+	return false
+}
+
+func init() {
+	proto.RegisterType((*Params)(nil), "procyon.leases.v1.Params")
+}
+
+func init() { proto.RegisterFile("procyon/leases/v1/params.proto", fileDescriptor_30ad7965cbc721e4) }
+
+var fileDescriptor_30ad7965cbc721e4 = []byte{
+	// 339 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x5c, 0x90, 0xb1, 0x4b, 0xfb, 0x40,
+	0x14, 0xc7, 0x73, 0xbf, 0x5f, 0x29, 0x35, 0xd0, 0x42, 0x83, 0x85, 0xb6, 0xca, 0xa5, 0x08, 0x42,
+	0x29, 0x9a, 0xa3, 0xb8, 0xb9, 0x28, 0xa1, 0x88, 0x83, 0x83, 0x14, 0x27, 0x97, 0x72, 0xbd, 0xc6,
+	0xe4, 0x34, 0xc9, 0x0b, 0xb9, 0xb3, 0xd0, 0x7f, 0xc1, 0xc9, 0x3f, 0xc3, 0xb1, 0x83, 0x7f, 0x44,
+	0xc7, 0xe2, 0x24, 0x0e, 0x41, 0xda, 0xa1, 0xd0, 0xdd, 0x5d, 0x7a, 0x97, 0x0e, 0x76, 0x79, 0xbc,
+	0xf7, 0xfd, 0x7c, 0xef, 0xbd, 0x7b, 0xcf, 0xc4, 0x49, 0x0a, 0x6c, 0x02, 0x31, 0x09, 0x3d, 0x2a,
+	0x3c, 0x41, 0xc6, 0x5d, 0x92, 0xd0, 0x94, 0x46, 0xc2, 0x49, 0x52, 0x90, 0x60, 0x55, 0x73, 0xee,
+	0x68, 0xee, 0x8c, 0xbb, 0xcd, 0x2a, 0x8d, 0x78, 0x0c, 0x44, 0x45, 0xed, 0x6a, 0xee, 0xfb, 0xe0,
+	0x83, 0x4a, 0xc9, 0x26, 0xcb, 0xd5, 0x06, 0x03, 0x11, 0x81, 0x18, 0x68, 0xa0, 0x0b, 0x8d, 0x8e,
+	0x7e, 0x90, 0x59, 0xbc, 0x55, 0x73, 0xac, 0x4b, 0xb3, 0x12, 0x71, 0x21, 0x06, 0x32, 0x48, 0x3d,
+	0x11, 0x40, 0x38, 0xaa, 0xa3, 0x16, 0x6a, 0x17, 0xdc, 0xc6, 0x3a, 0xb3, 0x6b, 0x7f, 0xc9, 0x89,
+	0x90, 0x29, 0x8f, 0xfd, 0x7e, 0x79, 0x23, 0xdf, 0x6d, 0x55, 0x0b, 0xcc, 0x8a, 0x08, 0xa9, 0x08,
+	0x06, 0x0f, 0x29, 0x65, 0x92, 0x43, 0x5c, 0xff, 0xd7, 0x42, 0xed, 0x3d, 0xf7, 0x7a, 0x96, 0xd9,
+	0xc6, 0x57, 0x66, 0x1f, 0xe8, 0xd1, 0x62, 0xf4, 0xe4, 0x70, 0x20, 0x11, 0x95, 0x81, 0x73, 0xe3,
+	0xf9, 0x94, 0x4d, 0x7a, 0x1e, 0x5b, 0x67, 0xf6, 0xce, 0xe3, 0x8f, 0xf7, 0x53, 0x33, 0xff, 0x6b,
+	0xcf, 0x63, 0x6f, 0xab, 0x69, 0x07, 0xf5, 0xcb, 0xca, 0x72, 0x95, 0x3b, 0xac, 0x43, 0xb3, 0xf0,
+	0x48, 0x79, 0x58, 0xff, 0xdf, 0x42, 0xed, 0x92, 0x5b, 0x5a, 0x67, 0xb6, 0xaa, 0xfb, 0x2a, 0x9e,
+	0x37, 0x5f, 0x56, 0xd3, 0x4e, 0x6d, 0xe7, 0xae, 0x7a, 0x59, 0xf7, 0x62, 0xb6, 0xc0, 0x68, 0xbe,
+	0xc0, 0xe8, 0x7b, 0x81, 0xd1, 0xeb, 0x12, 0x1b, 0xf3, 0x25, 0x36, 0x3e, 0x97, 0xd8, 0xb8, 0x3f,
+	0xf6, 0xb9, 0x0c, 0x9e, 0x87, 0x0e, 0x83, 0x88, 0x24, 0x10, 0x4e, 0x7c, 0x88, 0xc9, 0xb6, 0x07,
+	0x4d, 0x92, 0xbc, 0xcf, 0xb0, 0xa8, 0xee, 0x77, 0xf6, 0x3b, 0x00, 0x1d, 0x2e, 0x65, 0x68, 0xb8,
+	0x01, 0x00, 0x00,
+}
+
+func (m *Params) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Params) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *Params) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Jail {
+		i--
+		if m.Jail {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x18
+	}
+	{
+		size := m.SlashFraction.Size()
+		i -= size
+		if _, err := m.SlashFraction.MarshalTo(dAtA[i:]); err != nil {
+			return 0, err
+		}
+		i = encodeVarintParams(dAtA, i, uint64(size))
+	}
+	i--
+	dAtA[i] = 0x12
+	if m.MissThreshold != 0 {
+		i = encodeVarintParams(dAtA, i, uint64(m.MissThreshold))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func encodeVarintParams(dAtA []byte, offset int, v uint64) int {
+	offset -= sovParams(v)
+	base := offset
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return base
+}
+func (m *Params) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.MissThreshold != 0 {
+		n += 1 + sovParams(uint64(m.MissThreshold))
+	}
+	l = m.SlashFraction.Size()
+	n += 1 + l + sovParams(uint64(l))
+	if m.Jail {
+		n += 2
+	}
+	return n
+}
+
+func sovParams(x uint64) (n int) {
+	return (math_bits.Len64(x|1) + 6) / 7
+}
+func sozParams(x uint64) (n int) {
+	return sovParams(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (m *Params) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowParams
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Params: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Params: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MissThreshold", wireType)
+			}
+			m.MissThreshold = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowParams
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MissThreshold |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SlashFraction", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowParams
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthParams
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthParams
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := m.SlashFraction.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Jail", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowParams
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Jail = bool(v != 0)
+		default:
+			iNdEx = preIndex
+			skippy, err := skipParams(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthParams
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func skipParams(dAtA []byte) (n int, err error) {
+	l := len(dAtA)
+	iNdEx := 0
+	depth := 0
+	for iNdEx < l {
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return 0, ErrIntOverflowParams
+			}
+			if iNdEx >= l {
+				return 0, io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		wireType := int(wire & 0x7)
+		switch wireType {
+		case 0:
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowParams
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				iNdEx++
+				if dAtA[iNdEx-1] < 0x80 {
+					break
+				}
+			}
+		case 1:
+			iNdEx += 8
+		case 2:
+			var length int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowParams
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				length |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if length < 0 {
+				return 0, ErrInvalidLengthParams
+			}
+			iNdEx += length
+		case 3:
+			depth++
+		case 4:
+			if depth == 0 {
+				return 0, ErrUnexpectedEndOfGroupParams
+			}
+			depth--
+		case 5:
+			iNdEx += 4
+		default:
+			return 0, fmt.Errorf("proto: illegal wireType %d", wireType)
+		}
+		if iNdEx < 0 {
+			return 0, ErrInvalidLengthParams
+		}
+		if depth == 0 {
+			return iNdEx, nil
+		}
+	}
+	return 0, io.ErrUnexpectedEOF
+}
+
+var (
+	ErrInvalidLengthParams        = fmt.Errorf("proto: negative length found during unmarshaling")
+	ErrIntOverflowParams          = fmt.Errorf("proto: integer overflow")
+	ErrUnexpectedEndOfGroupParams = fmt.Errorf("proto: unexpected end of group")
+)
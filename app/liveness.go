@@ -0,0 +1,94 @@
+package app
+
+import (
+	"context"
+	"errors"
+
+	abci "github.com/cometbft/cometbft/abci/types"
+	cmtproto "github.com/cometbft/cometbft/proto/tendermint/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+)
+
+// LivenessTracker counts the blocks the lock holders miss their action, from
+// the actions they completed at each height. The holders without a commit vote
+// are absent from the completions.
+type LivenessTracker interface {
+	TrackLiveness(ctx context.Context, height uint64, completed map[string][]string) error
+}
+
+// DecidedCommitVerifier returns the extended commit injected in a finalized
+// block, verified against the last commit decided for it.
+type DecidedCommitVerifier interface {
+	DecidedCommit(ctx sdk.Context, req *abci.RequestFinalizeBlock) (abci.ExtendedCommitInfo, error)
+}
+
+// NewLivenessPreBlocker returns the pre-blocker running the given one, then
+// tracking the actions completed by the lock holders at the previous height,
+// as attested in the extended commit injected in the block. A block whose
+// extended commit doesn't verify counts no miss.
+func NewLivenessPreBlocker(next sdk.PreBlocker, tracker LivenessTracker, commits DecidedCommitVerifier, valStore ValidatorStore) sdk.PreBlocker {
+	return func(ctx sdk.Context, req *abci.RequestFinalizeBlock) (*sdk.ResponsePreBlock, error) {
+		resp, err := next(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		if !voteExtensionsEnabled(ctx, req.Height) {
+			return resp, nil
+		}
+
+		commit, err := commits.DecidedCommit(ctx, req)
+		if err != nil {
+			ctx.Logger().Error("not tracking the liveness of the lock holders", "height", req.Height-1, "err", err)
+			return resp, nil
+		}
+
+		completed, err := completedActions(ctx, valStore, commit, req.Height-1)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := tracker.TrackLiveness(ctx, uint64(req.Height-1), completed); err != nil {
+			return nil, err
+		}
+
+		return resp, nil
+	}
+}
+
+// completedActions returns the actions completed at the vote height by the
+// validators of the extended commit, by lock holder. Every holder which voted
+// for the block is in the map, the commit votes without a valid attestation of
+// this height completing nothing. The absent and nil votes carry no vote
+// extension, and their holder is left out: x/slashing handles the absences.
+func completedActions(ctx context.Context, valStore ValidatorStore, commit abci.ExtendedCommitInfo, height int64) (map[string][]string, error) {
+	completed := make(map[string][]string)
+	for _, vote := range commit.Votes {
+		if vote.BlockIdFlag != cmtproto.BlockIDFlagCommit {
+			continue
+		}
+
+		validator, err := valStore.GetValidatorByConsAddr(ctx, vote.Validator.Address)
+		if errors.Is(err, stakingtypes.ErrNoValidatorFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		operator, err := valStore.ValidatorAddressCodec().StringToBytes(validator.GetOperator())
+		if err != nil {
+			return nil, err
+		}
+
+		holder := sdk.AccAddress(operator).String()
+		actions := completed[holder]
+		if attestation, err := DecodeLockAttestation(vote.VoteExtension); err == nil && attestation.Height == height {
+			actions = append(actions, attestation.Completed...)
+		}
+		completed[holder] = actions
+	}
+
+	return completed, nil
+}
@@ -0,0 +1,154 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	abci "github.com/cometbft/cometbft/abci/types"
+	cmtproto "github.com/cometbft/cometbft/proto/tendermint/types"
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/log"
+
+	addresscodec "github.com/cosmos/cosmos-sdk/codec/address"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	slashingtypes "github.com/cosmos/cosmos-sdk/x/slashing/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+)
+
+// mockTracker records the completed actions it is given, by height.
+type mockTracker map[uint64]map[string][]string
+
+func (m mockTracker) TrackLiveness(_ context.Context, height uint64, completed map[string][]string) error {
+	m[height] = completed
+	return nil
+}
+
+// fixedDecided is the decided commit of every block, or fails with err.
+type fixedDecided struct {
+	commit abci.ExtendedCommitInfo
+	err    error
+}
+
+func (f fixedDecided) DecidedCommit(sdk.Context, *abci.RequestFinalizeBlock) (abci.ExtendedCommitInfo, error) {
+	return f.commit, f.err
+}
+
+func TestLivenessPreBlocker(t *testing.T) {
+	ctx := sdk.Context{}.WithContext(context.Background()).WithLogger(log.NewNopLogger()).
+		WithConsensusParams(cmtproto.ConsensusParams{Abci: &cmtproto.ABCIParams{VoteExtensionsEnableHeight: 2}})
+
+	consAddr := sdk.ConsAddress("validator___________")
+	operator := sdk.AccAddress("operator____________")
+	valCodec := addresscodec.NewBech32Codec(sdk.GetConfig().GetBech32ValidatorAddrPrefix())
+	valoper, err := valCodec.BytesToString(operator)
+	require.NoError(t, err)
+	absentAddr := sdk.ConsAddress("absent______________")
+	absentValoper, err := valCodec.BytesToString(sdk.AccAddress("absent_operator_____"))
+	require.NoError(t, err)
+
+	valStore := mockValStore{
+		codec: valCodec,
+		validators: map[string]stakingtypes.Validator{
+			consAddr.String():   {OperatorAddress: valoper},
+			absentAddr.String(): {OperatorAddress: absentValoper},
+		},
+	}
+
+	vote := func(validator sdk.ConsAddress, flag cmtproto.BlockIDFlag, extension string) abci.ExtendedVoteInfo {
+		return abci.ExtendedVoteInfo{
+			Validator:     abci.Validator{Address: validator},
+			BlockIdFlag:   flag,
+			VoteExtension: []byte(extension),
+		}
+	}
+	commit := abci.ExtendedCommitInfo{Votes: []abci.ExtendedVoteInfo{
+		vote(consAddr, cmtproto.BlockIDFlagCommit, `{"height":9,"locks":["lock1","lock2"],"completed":["lock1"]}`),
+		// not a validator anymore
+		vote(sdk.ConsAddress("other_______________"), cmtproto.BlockIDFlagCommit, `{"height":9,"locks":["lock3"],"completed":["lock3"]}`),
+		// absent, which is left to x/slashing
+		vote(absentAddr, cmtproto.BlockIDFlagAbsent, ""),
+	}}
+
+	var nextCalled bool
+	next := func(sdk.Context, *abci.RequestFinalizeBlock) (*sdk.ResponsePreBlock, error) {
+		nextCalled = true
+		return &sdk.ResponsePreBlock{}, nil
+	}
+
+	tracker := mockTracker{}
+	preBlocker := NewLivenessPreBlocker(next, tracker, fixedDecided{commit: commit}, valStore)
+
+	_, err = preBlocker(ctx, &abci.RequestFinalizeBlock{Height: 10})
+	require.NoError(t, err)
+	require.True(t, nextCalled)
+	require.Equal(t, mockTracker{9: {operator.String(): {"lock1"}}}, tracker)
+
+	// an attestation of another height completes nothing, but its holder voted
+	_, err = preBlocker(ctx, &abci.RequestFinalizeBlock{Height: 11})
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{operator.String(): nil}, tracker[10])
+
+	// without vote extensions, nothing is tracked
+	delete(tracker, 9)
+	delete(tracker, 10)
+	_, err = preBlocker(ctx, &abci.RequestFinalizeBlock{Height: 2})
+	require.NoError(t, err)
+	require.Empty(t, tracker)
+
+	// nor from a commit which doesn't verify against the decided one
+	preBlocker = NewLivenessPreBlocker(next, tracker, fixedDecided{err: ErrLastCommitMismatch}, valStore)
+	_, err = preBlocker(ctx, &abci.RequestFinalizeBlock{Height: 10})
+	require.NoError(t, err)
+	require.Empty(t, tracker)
+}
+
+func TestTrackLivenessShippedAction(t *testing.T) {
+	actions, err := LockableActions()
+	require.NoError(t, err)
+	require.NotEmpty(t, actions)
+
+	app := Setup(t)
+	NextBlock(t, app)
+
+	// the end-blocker leased the shipped action to the only validator
+	action := actions[0]
+	ctx := app.NewUncachedContext(false, cmtproto.Header{Height: app.LastBlockHeight() + 1, Time: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)})
+	lock, err := app.EnvoyKeeper.Locks.Get(ctx, action.Name)
+	require.NoError(t, err)
+
+	holder, err := sdk.AccAddressFromBech32(lock.Envoy)
+	require.NoError(t, err)
+	validator, err := app.StakingKeeper.GetValidator(ctx, sdk.ValAddress(holder))
+	require.NoError(t, err)
+	require.False(t, validator.IsJailed())
+
+	// the validator of the test genesis was not bonded by a gentx, which
+	// creates the signing info a validator is jailed in
+	consAddr, err := validator.GetConsAddr()
+	require.NoError(t, err)
+	require.NoError(t, app.SlashingKeeper.SetValidatorSigningInfo(ctx, consAddr, slashingtypes.NewValidatorSigningInfo(consAddr, 0, 0, time.Unix(0, 0), false, 0)))
+
+	params, err := app.LeasesKeeper.Params.Get(ctx)
+	require.NoError(t, err)
+	require.True(t, params.Jail)
+	require.True(t, params.SlashFraction.IsPositive())
+
+	// the holder voting without completing the action misses it until it is
+	// past the threshold
+	height := lock.AtBlock + 1
+	for misses := uint64(0); misses <= params.MissThreshold; misses++ {
+		require.NoError(t, app.LeasesKeeper.TrackLiveness(ctx.WithBlockHeight(int64(height)), height, map[string][]string{lock.Envoy: nil}))
+		height++
+	}
+
+	penalised, err := app.StakingKeeper.GetValidator(ctx, sdk.ValAddress(holder))
+	require.NoError(t, err)
+	require.True(t, penalised.IsJailed())
+	require.True(t, penalised.GetTokens().LT(validator.GetTokens()))
+
+	has, err := app.EnvoyKeeper.Locks.Has(ctx, action.Name)
+	require.NoError(t, err)
+	require.False(t, has)
+}
@@ -9,31 +9,56 @@ import (
 	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
 )
 
-const (
+// The identity of the chain, set at build time to launch a chain other than
+// mini without forking, e.g. with
+//
+//	-ldflags "-X github.com/polygon/procyon/app/params.CoinUnit=ustar -X github.com/polygon/procyon/app/params.Bech32PrefixAccAddr=star"
+//
+// They must be set before the package is initialized, which derives the other
+// prefixes and the bond denom from them.
+var (
 	CoinUnit = "mini"
 
-	DefaultBondDenom = CoinUnit
-
 	// Bech32PrefixAccAddr defines the Bech32 prefix of an account's address.
 	Bech32PrefixAccAddr = "mini"
 )
 
 var (
+	DefaultBondDenom string
+
 	// Bech32PrefixAccPub defines the Bech32 prefix of an account's public key.
-	Bech32PrefixAccPub = Bech32PrefixAccAddr + "pub"
+	Bech32PrefixAccPub string
 	// Bech32PrefixValAddr defines the Bech32 prefix of a validator's operator address.
-	Bech32PrefixValAddr = Bech32PrefixAccAddr + "valoper"
+	Bech32PrefixValAddr string
 	// Bech32PrefixValPub defines the Bech32 prefix of a validator's operator public key.
-	Bech32PrefixValPub = Bech32PrefixAccAddr + "valoperpub"
+	Bech32PrefixValPub string
 	// Bech32PrefixConsAddr defines the Bech32 prefix of a consensus node address.
-	Bech32PrefixConsAddr = Bech32PrefixAccAddr + "valcons"
+	Bech32PrefixConsAddr string
 	// Bech32PrefixConsPub defines the Bech32 prefix of a consensus node public key.
-	Bech32PrefixConsPub = Bech32PrefixAccAddr + "valconspub"
+	Bech32PrefixConsPub string
 )
 
 func init() {
+	// derived here rather than in the declarations, which the compiler may
+	// initialize statically from the values before the linker sets them
+	DefaultBondDenom = CoinUnit
+	Bech32PrefixAccPub = Bech32PrefixAccAddr + "pub"
+	Bech32PrefixValAddr = Bech32PrefixAccAddr + "valoper"
+	Bech32PrefixValPub = Bech32PrefixAccAddr + "valoperpub"
+	Bech32PrefixConsAddr = Bech32PrefixAccAddr + "valcons"
+	Bech32PrefixConsPub = Bech32PrefixAccAddr + "valconspub"
+
 	SetAddressPrefixes()
 	RegisterDenoms()
+
+	// the default genesis of the modules and the init command use it
+	sdk.DefaultBondDenom = DefaultBondDenom
+}
+
+// DefaultMinGasPrices returns the minimum gas prices of a new node, accepting
+// txs without fees.
+func DefaultMinGasPrices() string {
+	return "0" + DefaultBondDenom
 }
 
 func RegisterDenoms() {
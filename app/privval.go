@@ -0,0 +1,59 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	cmtcfg "github.com/cometbft/cometbft/config"
+	"github.com/cometbft/cometbft/crypto"
+	cmtjson "github.com/cometbft/cometbft/libs/json"
+	"github.com/spf13/cast"
+
+	"github.com/cosmos/cosmos-sdk/client/flags"
+	servertypes "github.com/cosmos/cosmos-sdk/server/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// loadValidatorAddress returns the consensus address of the node's validator
+// from its CometBFT priv validator key file, or nil if the node has no such
+// file, e.g. when it is not a validator or is only used for an export.
+//
+// The app is built before the node's priv validator, which it has no access
+// to, so the key file is read to find which validator the node runs. Only its
+// public key is decoded, the address being derived from it, and the bytes read
+// are zeroed, the file also holding the private key.
+func loadValidatorAddress(appOpts servertypes.AppOptions) (sdk.ConsAddress, error) {
+	keyFile := cast.ToString(appOpts.Get("priv_validator_key_file"))
+	if keyFile == "" {
+		keyFile = cmtcfg.DefaultBaseConfig().PrivValidatorKey
+	}
+
+	if !filepath.IsAbs(keyFile) {
+		keyFile = filepath.Join(cast.ToString(appOpts.Get(flags.FlagHome)), keyFile)
+	}
+
+	bz, err := os.ReadFile(keyFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer clear(bz)
+
+	var pvKey struct {
+		PubKey json.RawMessage `json:"pub_key"`
+	}
+	if err := json.Unmarshal(bz, &pvKey); err != nil {
+		return nil, fmt.Errorf("error reading priv validator key from %s: %w", keyFile, err)
+	}
+
+	var pubKey crypto.PubKey
+	if err := cmtjson.Unmarshal(pvKey.PubKey, &pubKey); err != nil {
+		return nil, fmt.Errorf("error reading priv validator public key from %s: %w", keyFile, err)
+	}
+
+	return sdk.ConsAddress(pubKey.Address()), nil
+}
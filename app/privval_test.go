@@ -0,0 +1,47 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cometbft/cometbft/privval"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/client/flags"
+	simtestutil "github.com/cosmos/cosmos-sdk/testutil/sims"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestLoadValidatorAddress(t *testing.T) {
+	home := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(home, "config"), 0o700))
+	require.NoError(t, os.MkdirAll(filepath.Join(home, "data"), 0o700))
+
+	// without a key file, the node runs no validator
+	appOpts := simtestutil.AppOptionsMap{flags.FlagHome: home}
+	consAddr, err := loadValidatorAddress(appOpts)
+	require.NoError(t, err)
+	require.Nil(t, consAddr)
+
+	pv := privval.GenFilePV(
+		filepath.Join(home, "config", "priv_validator_key.json"),
+		filepath.Join(home, "data", "priv_validator_state.json"),
+	)
+	pv.Save()
+
+	consAddr, err = loadValidatorAddress(appOpts)
+	require.NoError(t, err)
+	require.Equal(t, sdk.ConsAddress(pv.GetAddress()), consAddr)
+
+	// the key file may be set in the config
+	keyFile := filepath.Join(home, "key.json")
+	require.NoError(t, os.Rename(filepath.Join(home, "config", "priv_validator_key.json"), keyFile))
+	consAddr, err = loadValidatorAddress(simtestutil.AppOptionsMap{"priv_validator_key_file": keyFile})
+	require.NoError(t, err)
+	require.Equal(t, sdk.ConsAddress(pv.GetAddress()), consAddr)
+
+	require.NoError(t, os.WriteFile(keyFile, []byte(`{"pub_key":{}}`), 0o600))
+	_, err = loadValidatorAddress(simtestutil.AppOptionsMap{"priv_validator_key_file": keyFile})
+	require.ErrorContains(t, err, "public key")
+}
@@ -0,0 +1,267 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	abci "github.com/cometbft/cometbft/abci/types"
+	cmttypes "github.com/cometbft/cometbft/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// ErrProposalItemMalformed is returned when a proposal item not claimed by any
+// injector is not a tx.
+var ErrProposalItemMalformed = errors.New("malformed item in proposal")
+
+// ProposalInjector injects items into block proposals, next to the txs selected
+// from the mempool, and verifies them in the proposals of other validators.
+type ProposalInjector interface {
+	// PrepareInjection returns the items to inject in the proposal. The
+	// request carries no txs, and its MaxTxBytes is the reserved budget.
+	PrepareInjection(ctx sdk.Context, req *abci.RequestPrepareProposal) ([][]byte, error)
+
+	// ProcessInjection verifies the injected items and returns how many there
+	// are. items are the proposal items not claimed by the injectors verified
+	// before, a prepended injection is at their head and an appended one at
+	// their tail. maxBytes is the budget the injection was given in
+	// PrepareProposal.
+	ProcessInjection(ctx sdk.Context, req *abci.RequestProcessProposal, items [][]byte, maxBytes int64) (int, error)
+}
+
+// InjectionPosition is where injected items are placed in the proposal.
+type InjectionPosition int
+
+const (
+	// Prepend places the injected items before the txs.
+	Prepend InjectionPosition = iota
+	// Append places the injected items after the txs.
+	Append
+)
+
+// ProposalInjection registers a ProposalInjector in a ProposalHandlerChain.
+type ProposalInjection struct {
+	Name     string
+	Injector ProposalInjector
+	Position InjectionPosition
+	// MaxBytes is the part of the block reserved for the injected items. The
+	// injection is given less when less is left of the block.
+	MaxBytes int64
+	// MaxGas is the part of the block gas reserved for the injected items,
+	// zero unless they are txs consuming gas.
+	MaxGas int64
+}
+
+// ProposalHandlerChain builds proposals from the txs selected by the default
+// proposal handler and the items of every injection, prepended items first in
+// chain order, then the txs, then appended items in chain order. The txs are
+// selected within what is left of the block once the injected items are in.
+// ProcessProposal mirrors it, each injection verifying its own items and the
+// default handler the txs.
+//
+// The injections take their budget from the block in the order ProcessProposal
+// verifies them, prepended ones in chain order then appended ones in reverse
+// chain order, so both handlers give each injection the same budget.
+type ProposalHandlerChain struct {
+	txSelector sdk.PrepareProposalHandler
+	txVerifier sdk.ProcessProposalHandler
+	txDecoder  sdk.TxDecoder
+	injections []ProposalInjection
+}
+
+// NewProposalHandlerChain returns a ProposalHandlerChain around the tx selection
+// and verification handlers, typically those of baseapp.DefaultProposalHandler.
+func NewProposalHandlerChain(
+	txSelector sdk.PrepareProposalHandler,
+	txVerifier sdk.ProcessProposalHandler,
+	txDecoder sdk.TxDecoder,
+	injections ...ProposalInjection,
+) *ProposalHandlerChain {
+	return &ProposalHandlerChain{
+		txSelector: txSelector,
+		txVerifier: txVerifier,
+		txDecoder:  txDecoder,
+		injections: injections,
+	}
+}
+
+// PrepareProposal returns the PrepareProposal handler of the chain.
+func (c *ProposalHandlerChain) PrepareProposal() sdk.PrepareProposalHandler {
+	return func(ctx sdk.Context, req *abci.RequestPrepareProposal) (*abci.ResponsePrepareProposal, error) {
+		var (
+			head, tail   [][]byte
+			usedBytes    int64
+			reservedGas  int64
+			maxBytes     = maxDataBytes(ctx, len(req.LocalLastCommit.Votes))
+			injectionReq = *req
+		)
+
+		for _, injection := range c.budgetOrder() {
+			injectionReq.Txs = nil
+			injectionReq.MaxTxBytes = min(injection.MaxBytes, maxBytes-usedBytes)
+
+			items, err := injection.Injector.PrepareInjection(ctx, &injectionReq)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", injection.Name, err)
+			}
+
+			size := itemsSize(items)
+			if size > injectionReq.MaxTxBytes {
+				return nil, fmt.Errorf("%s: injected %d bytes, %d reserved", injection.Name, size, injectionReq.MaxTxBytes)
+			}
+			usedBytes += size
+			reservedGas += injection.MaxGas
+
+			if injection.Position == Append {
+				tail = append(slices.Clone(items), tail...)
+			} else {
+				head = append(head, items...)
+			}
+		}
+
+		// the evidence of the block may leave less than the injections took
+		if usedBytes > req.MaxTxBytes {
+			return nil, fmt.Errorf("injected %d bytes, %d left in the block", usedBytes, req.MaxTxBytes)
+		}
+
+		txReq := *req
+		txReq.MaxTxBytes -= usedBytes
+		resp, err := c.txSelector(withReservedGas(ctx, reservedGas), &txReq)
+		if err != nil {
+			return nil, err
+		}
+
+		// the selected txs may be measured by their length, CometBFT rejects
+		// a proposal whose data takes more than MaxTxBytes
+		selected := resp.Txs
+		for size := itemsSize(selected); len(selected) > 0 && usedBytes+size > req.MaxTxBytes; {
+			size -= itemsSize(selected[len(selected)-1:])
+			selected = selected[:len(selected)-1]
+		}
+
+		txs := make([][]byte, 0, len(head)+len(selected)+len(tail))
+		txs = append(txs, head...)
+		txs = append(txs, selected...)
+		txs = append(txs, tail...)
+
+		return &abci.ResponsePrepareProposal{Txs: txs}, nil
+	}
+}
+
+// ProcessProposal returns the ProcessProposal handler of the chain.
+func (c *ProposalHandlerChain) ProcessProposal() sdk.ProcessProposalHandler {
+	return func(ctx sdk.Context, req *abci.RequestProcessProposal) (*abci.ResponseProcessProposal, error) {
+		txs, reservedGas, err := c.verifyInjections(ctx, req)
+		if err != nil {
+			ctx.Logger().Error("rejecting proposal", "height", req.Height, "proposer", fmt.Sprintf("%X", req.ProposerAddress), "err", err)
+			return &abci.ResponseProcessProposal{Status: abci.ResponseProcessProposal_REJECT}, nil
+		}
+
+		txReq := *req
+		txReq.Txs = txs
+		return c.txVerifier(withReservedGas(ctx, reservedGas), &txReq)
+	}
+}
+
+// verifyInjections verifies the injected items of the proposal and returns the
+// remaining txs and the gas reserved for the injections.
+func (c *ProposalHandlerChain) verifyInjections(ctx sdk.Context, req *abci.RequestProcessProposal) ([][]byte, int64, error) {
+	var (
+		items       = req.Txs
+		usedBytes   int64
+		reservedGas int64
+		maxBytes    = maxDataBytes(ctx, len(req.ProposedLastCommit.Votes))
+	)
+
+	for _, injection := range c.budgetOrder() {
+		budget := min(injection.MaxBytes, maxBytes-usedBytes)
+		n, err := injection.Injector.ProcessInjection(ctx, req, items, budget)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", injection.Name, err)
+		}
+
+		if n < 0 || n > len(items) {
+			return nil, 0, fmt.Errorf("%s: claimed %d of %d items", injection.Name, n, len(items))
+		}
+
+		injected := items[:n]
+		if injection.Position == Append {
+			injected = items[len(items)-n:]
+		}
+
+		size := itemsSize(injected)
+		if size > budget {
+			return nil, 0, fmt.Errorf("%s: injected %d bytes, %d reserved", injection.Name, size, budget)
+		}
+		usedBytes += size
+		reservedGas += injection.MaxGas
+
+		if injection.Position == Append {
+			items = items[:len(items)-n]
+		} else {
+			items = items[n:]
+		}
+	}
+
+	for _, item := range items {
+		if _, err := c.txDecoder(item); err != nil {
+			return nil, 0, fmt.Errorf("%w: item %X: %w", ErrProposalItemMalformed, cmttypes.Tx(item).Hash(), err)
+		}
+	}
+
+	return items, reservedGas, nil
+}
+
+// budgetOrder returns the injections in the order they take their budget from
+// the block: prepended ones in chain order, then appended ones from the tail,
+// in reverse chain order.
+func (c *ProposalHandlerChain) budgetOrder() []ProposalInjection {
+	ordered := make([]ProposalInjection, 0, len(c.injections))
+	for _, injection := range c.injections {
+		if injection.Position != Append {
+			ordered = append(ordered, injection)
+		}
+	}
+
+	for i := len(c.injections) - 1; i >= 0; i-- {
+		if c.injections[i].Position == Append {
+			ordered = append(ordered, c.injections[i])
+		}
+	}
+
+	return ordered
+}
+
+// maxDataBytes returns the bytes of the block left to its data, regardless of
+// its evidence, which ProcessProposal does not see the size of, as CometBFT
+// computes them for a last commit of numVotes votes.
+func maxDataBytes(ctx sdk.Context, numVotes int) int64 {
+	maxBytes := int64(cmttypes.MaxBlockSizeBytes)
+	if cp := ctx.ConsensusParams(); cp.Block != nil && cp.Block.MaxBytes > 0 {
+		maxBytes = cp.Block.MaxBytes
+	}
+
+	return cmttypes.MaxDataBytesNoEvidence(maxBytes, numVotes)
+}
+
+// withReservedGas returns the context with the block gas limit lowered by the
+// gas reserved for injected items.
+func withReservedGas(ctx sdk.Context, reservedGas int64) sdk.Context {
+	cp := ctx.ConsensusParams()
+	if reservedGas == 0 || cp.Block == nil || cp.Block.MaxGas <= 0 {
+		return ctx
+	}
+
+	block := *cp.Block
+	block.MaxGas = max(block.MaxGas-reservedGas, 0)
+	cp.Block = &block
+
+	return ctx.WithConsensusParams(cp)
+}
+
+// itemsSize returns the size the items take in the block data, as CometBFT
+// measures it against MaxTxBytes.
+func itemsSize(items [][]byte) int64 {
+	return cmttypes.ComputeProtoSizeForTxs(cmttypes.ToTxs(items))
+}
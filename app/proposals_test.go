@@ -0,0 +1,204 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	abci "github.com/cometbft/cometbft/abci/types"
+	cmtproto "github.com/cometbft/cometbft/proto/tendermint/types"
+	cmttypes "github.com/cometbft/cometbft/types"
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/log"
+
+	"github.com/cosmos/cosmos-sdk/baseapp"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/mempool"
+)
+
+// fixedInjector injects the same items at every height.
+type fixedInjector [][]byte
+
+func (f fixedInjector) PrepareInjection(sdk.Context, *abci.RequestPrepareProposal) ([][]byte, error) {
+	return f, nil
+}
+
+func (f fixedInjector) ProcessInjection(_ sdk.Context, _ *abci.RequestProcessProposal, items [][]byte, _ int64) (int, error) {
+	if len(items) < len(f) {
+		return 0, errors.New("missing items")
+	}
+	return len(f), nil
+}
+
+// budgetInjector injects its items, and records the budget it is given in
+// both handlers.
+type budgetInjector struct {
+	items              [][]byte
+	prepared, verified *int64
+}
+
+func (b budgetInjector) PrepareInjection(_ sdk.Context, req *abci.RequestPrepareProposal) ([][]byte, error) {
+	*b.prepared = req.MaxTxBytes
+	return b.items, nil
+}
+
+func (b budgetInjector) ProcessInjection(_ sdk.Context, _ *abci.RequestProcessProposal, _ [][]byte, maxBytes int64) (int, error) {
+	*b.verified = maxBytes
+	return len(b.items), nil
+}
+
+func TestProposalHandlerChain(t *testing.T) {
+	ctx := sdk.Context{}.WithLogger(log.NewNopLogger())
+
+	var selectReq *abci.RequestPrepareProposal
+	txSelector := func(_ sdk.Context, req *abci.RequestPrepareProposal) (*abci.ResponsePrepareProposal, error) {
+		selectReq = req
+		return &abci.ResponsePrepareProposal{Txs: req.Txs}, nil
+	}
+
+	var verifyReq *abci.RequestProcessProposal
+	txVerifier := func(_ sdk.Context, req *abci.RequestProcessProposal) (*abci.ResponseProcessProposal, error) {
+		verifyReq = req
+		return &abci.ResponseProcessProposal{Status: abci.ResponseProcessProposal_ACCEPT}, nil
+	}
+
+	chain := NewProposalHandlerChain(txSelector, txVerifier, decodeTx,
+		ProposalInjection{Name: "first", Injector: fixedInjector{[]byte("a")}, Position: Prepend, MaxBytes: 10},
+		ProposalInjection{Name: "last", Injector: fixedInjector{[]byte("z1"), []byte("z2")}, Position: Append, MaxBytes: 10},
+		ProposalInjection{Name: "envoy", Injector: NewEnvoyProposalInjector(prepareEnvoy, decodeTx, fixedCommit{}), Position: Prepend, MaxBytes: 10},
+	)
+
+	resp, err := chain.PrepareProposal()(ctx, &abci.RequestPrepareProposal{
+		Height:     5,
+		MaxTxBytes: 100,
+		Txs:        [][]byte{[]byte("tx1"), []byte("tx2")},
+	})
+	require.NoError(t, err)
+	// each item takes 2 bytes of proto framing in the block data
+	require.Equal(t, int64(100-3-9-8), selectReq.MaxTxBytes)
+
+	expTxs := [][]byte{[]byte("a"), envoyItem(5), []byte("tx1"), []byte("tx2"), []byte("z1"), []byte("z2")}
+	require.Equal(t, expTxs, resp.Txs)
+
+	processResp, err := chain.ProcessProposal()(ctx, &abci.RequestProcessProposal{Height: 5, Txs: resp.Txs})
+	require.NoError(t, err)
+	require.Equal(t, abci.ResponseProcessProposal_ACCEPT, processResp.Status)
+	require.Equal(t, [][]byte{[]byte("tx1"), []byte("tx2")}, verifyReq.Txs)
+
+	// envoy data from another height
+	verifyReq = nil
+	txs := [][]byte{[]byte("a"), envoyItem(4), []byte("tx1"), []byte("z1"), []byte("z2")}
+	processResp, err = chain.ProcessProposal()(ctx, &abci.RequestProcessProposal{Height: 5, Txs: txs})
+	require.NoError(t, err)
+	require.Equal(t, abci.ResponseProcessProposal_REJECT, processResp.Status)
+	require.Nil(t, verifyReq)
+
+	// item claimed by no injector which is not a tx
+	txs = [][]byte{[]byte("a"), envoyItem(5), []byte("garbage"), []byte("z1"), []byte("z2")}
+	_, _, err = chain.verifyInjections(ctx, &abci.RequestProcessProposal{Height: 5, Txs: txs})
+	require.ErrorIs(t, err, ErrProposalItemMalformed)
+}
+
+func TestProposalHandlerChainBudget(t *testing.T) {
+	ctx := sdk.Context{}.WithLogger(log.NewNopLogger())
+
+	txSelector := func(_ sdk.Context, req *abci.RequestPrepareProposal) (*abci.ResponsePrepareProposal, error) {
+		return &abci.ResponsePrepareProposal{Txs: req.Txs}, nil
+	}
+	txVerifier := func(sdk.Context, *abci.RequestProcessProposal) (*abci.ResponseProcessProposal, error) {
+		return &abci.ResponseProcessProposal{Status: abci.ResponseProcessProposal_ACCEPT}, nil
+	}
+
+	chain := NewProposalHandlerChain(txSelector, txVerifier, decodeTx,
+		ProposalInjection{Name: "big", Injector: fixedInjector{[]byte("0123456789")}, Position: Prepend, MaxBytes: 5},
+	)
+
+	_, err := chain.PrepareProposal()(ctx, &abci.RequestPrepareProposal{Height: 5, MaxTxBytes: 100})
+	require.Error(t, err)
+
+	resp, err := chain.ProcessProposal()(ctx, &abci.RequestProcessProposal{Height: 5, Txs: [][]byte{[]byte("0123456789")}})
+	require.NoError(t, err)
+	require.Equal(t, abci.ResponseProcessProposal_REJECT, resp.Status)
+}
+
+func TestProposalHandlerChainBudgetParity(t *testing.T) {
+	// 50 bytes are left to the block data
+	overhead := cmttypes.MaxBlockSizeBytes - cmttypes.MaxDataBytesNoEvidence(cmttypes.MaxBlockSizeBytes, 0)
+	ctx := sdk.Context{}.WithLogger(log.NewNopLogger()).
+		WithConsensusParams(cmtproto.ConsensusParams{Block: &cmtproto.BlockParams{MaxBytes: overhead + 50}})
+
+	txSelector := func(_ sdk.Context, req *abci.RequestPrepareProposal) (*abci.ResponsePrepareProposal, error) {
+		return &abci.ResponsePrepareProposal{Txs: req.Txs}, nil
+	}
+	txVerifier := func(sdk.Context, *abci.RequestProcessProposal) (*abci.ResponseProcessProposal, error) {
+		return &abci.ResponseProcessProposal{Status: abci.ResponseProcessProposal_ACCEPT}, nil
+	}
+
+	var budgets [4]int64
+	chain := NewProposalHandlerChain(txSelector, txVerifier, decodeTx,
+		ProposalInjection{Name: "first", Injector: fixedInjector{[]byte("0123456789")}, Position: Prepend, MaxBytes: 100},
+		ProposalInjection{Name: "last", Injector: budgetInjector{[][]byte{[]byte("zz")}, &budgets[0], &budgets[1]}, Position: Append, MaxBytes: 100},
+		ProposalInjection{Name: "second", Injector: budgetInjector{[][]byte{[]byte("bbbb")}, &budgets[2], &budgets[3]}, Position: Prepend, MaxBytes: 100},
+	)
+
+	resp, err := chain.PrepareProposal()(ctx, &abci.RequestPrepareProposal{Height: 5, MaxTxBytes: 50})
+	require.NoError(t, err)
+
+	processResp, err := chain.ProcessProposal()(ctx, &abci.RequestProcessProposal{Height: 5, Txs: resp.Txs})
+	require.NoError(t, err)
+	require.Equal(t, abci.ResponseProcessProposal_ACCEPT, processResp.Status)
+
+	// the appended injection takes its budget after the prepended ones
+	require.Equal(t, [4]int64{50 - 12 - 6, 50 - 12 - 6, 50 - 12, 50 - 12}, budgets)
+
+	// the evidence of the block may leave less than the injections take
+	_, err = chain.PrepareProposal()(ctx, &abci.RequestPrepareProposal{Height: 5, MaxTxBytes: 21})
+	require.ErrorContains(t, err, "injected 22 bytes, 21 left in the block")
+}
+
+// txVerifier is the baseapp.ProposalTxVerifier of the txs accepted by decodeTx.
+type txVerifier struct{}
+
+func (txVerifier) PrepareProposalVerifyTx(sdk.Tx) ([]byte, error) {
+	return nil, errors.New("no mempool")
+}
+
+func (txVerifier) ProcessProposalVerifyTx(bz []byte) (sdk.Tx, error) {
+	return decodeTx(bz)
+}
+
+func (txVerifier) TxDecode(bz []byte) (sdk.Tx, error) {
+	return decodeTx(bz)
+}
+
+func (txVerifier) TxEncode(sdk.Tx) ([]byte, error) {
+	return nil, errors.New("not encodable")
+}
+
+func TestProposalHandlerChainFullBlock(t *testing.T) {
+	ctx := sdk.Context{}.WithLogger(log.NewNopLogger())
+
+	proposalHandler := baseapp.NewDefaultProposalHandler(mempool.NoOpMempool{}, txVerifier{})
+	chain := NewProposalHandlerChain(proposalHandler.PrepareProposalHandler(), proposalHandler.ProcessProposalHandler(), decodeTx,
+		ProposalInjection{Name: "envoy", Injector: NewEnvoyProposalInjector(prepareEnvoy, decodeTx, fixedCommit{}), Position: Prepend, MaxBytes: 100},
+	)
+
+	// more txs than the block holds
+	var pending [][]byte
+	for i := 0; i < 100; i++ {
+		pending = append(pending, []byte(fmt.Sprintf("tx%0200d", i)))
+	}
+
+	// the default handler measures the txs by their length, which would let
+	// one tx more in than the block data holds
+	const maxTxBytes = 4900
+	resp, err := chain.PrepareProposal()(ctx, &abci.RequestPrepareProposal{Height: 5, MaxTxBytes: maxTxBytes, Txs: pending})
+	require.NoError(t, err)
+
+	// the block is full, as CometBFT measures it
+	size := cmttypes.ComputeProtoSizeForTxs(cmttypes.ToTxs(resp.Txs))
+	require.LessOrEqual(t, size, int64(maxTxBytes))
+	require.Greater(t, size+cmttypes.ComputeProtoSizeForTxs(cmttypes.ToTxs(pending[:1])), int64(maxTxBytes))
+	require.Equal(t, envoyItem(5), resp.Txs[0])
+}
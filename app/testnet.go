@@ -0,0 +1,208 @@
+package app
+
+import (
+	"fmt"
+
+	cmtcrypto "github.com/cometbft/cometbft/crypto"
+	cmtproto "github.com/cometbft/cometbft/proto/tendermint/types"
+
+	"cosmossdk.io/math"
+	storetypes "cosmossdk.io/store/types"
+
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	cryptocodec "github.com/cosmos/cosmos-sdk/crypto/codec"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	distrtypes "github.com/cosmos/cosmos-sdk/x/distribution/types"
+	minttypes "github.com/cosmos/cosmos-sdk/x/mint/types"
+	slashingtypes "github.com/cosmos/cosmos-sdk/x/slashing/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+
+	"github.com/polygon/envoy"
+)
+
+// InPlaceTestnetPower is the consensus power of the single validator of an
+// in-place testnet, which must outweigh the bonded tokens of the forked chain.
+const InPlaceTestnetPower = 900_000_000
+
+// InPlaceTestnetOptions are the changes made to a forked state to run it as a
+// single validator testnet.
+type InPlaceTestnetOptions struct {
+	// ValidatorPubKey is the consensus key of the local validator.
+	ValidatorPubKey cmtcrypto.PubKey
+	// Operator is the account operating the local validator.
+	Operator sdk.AccAddress
+	// FundAccounts are funded with FundCoins, minted on top of the supply.
+	FundAccounts []sdk.AccAddress
+	FundCoins    sdk.Coins
+	// ReassignEnvoyLocks gives every envoy lock to the local operator.
+	ReassignEnvoyLocks bool
+}
+
+// InPlaceTestnetReport reports the changes made to the forked state.
+type InPlaceTestnetReport struct {
+	Validator       string   `json:"validator"`
+	FundedAccounts  []string `json:"funded_accounts"`
+	ReassignedLocks []string `json:"reassigned_locks"`
+}
+
+// InitForInPlaceTestnet replaces the validator set of the loaded state with a
+// single local validator, and applies the other options. The changes are
+// written to the working state, which the next block commits.
+func (app *MiniApp) InitForInPlaceTestnet(opts InPlaceTestnetOptions) (InPlaceTestnetReport, error) {
+	var report InPlaceTestnetReport
+
+	ctx := app.BaseApp.NewUncachedContext(true, cmtproto.Header{Height: app.LastBlockHeight()})
+
+	pubKey, err := cryptocodec.FromCmtPubKeyInterface(opts.ValidatorPubKey)
+	if err != nil {
+		return report, err
+	}
+
+	pubKeyAny, err := codectypes.NewAnyWithValue(pubKey)
+	if err != nil {
+		return report, err
+	}
+
+	valAddr := sdk.ValAddress(opts.Operator)
+	valAddrStr, err := app.StakingKeeper.ValidatorAddressCodec().BytesToString(valAddr)
+	if err != nil {
+		return report, err
+	}
+	report.Validator = valAddrStr
+
+	/* Handle staking state. */
+
+	tokens := sdk.TokensFromConsensusPower(InPlaceTestnetPower, app.StakingKeeper.PowerReduction(ctx))
+	newVal := stakingtypes.Validator{
+		OperatorAddress: valAddrStr,
+		ConsensusPubkey: pubKeyAny,
+		Jailed:          false,
+		Status:          stakingtypes.Bonded,
+		Tokens:          tokens,
+		DelegatorShares: math.LegacyNewDecFromInt(tokens),
+		Description: stakingtypes.Description{
+			Moniker: "Testnet Validator",
+		},
+		Commission: stakingtypes.Commission{
+			CommissionRates: stakingtypes.CommissionRates{
+				Rate:          math.LegacyMustNewDecFromStr("0.05"),
+				MaxRate:       math.LegacyMustNewDecFromStr("0.1"),
+				MaxChangeRate: math.LegacyMustNewDecFromStr("0.05"),
+			},
+		},
+		MinSelfDelegation: math.OneInt(),
+	}
+
+	// remove every other validator from the power index and the last validator
+	// powers, so that the next validator set update only has the local one
+	stakingStore := ctx.KVStore(app.GetKey(stakingtypes.StoreKey))
+	for _, prefix := range [][]byte{stakingtypes.ValidatorsByPowerIndexKey, stakingtypes.LastValidatorPowerKey} {
+		iter := storetypes.KVStorePrefixIterator(stakingStore, prefix)
+		var keys [][]byte
+		for ; iter.Valid(); iter.Next() {
+			keys = append(keys, iter.Key())
+		}
+		if err := iter.Close(); err != nil {
+			return report, err
+		}
+
+		for _, key := range keys {
+			stakingStore.Delete(key)
+		}
+	}
+
+	if err := app.StakingKeeper.SetValidator(ctx, newVal); err != nil {
+		return report, err
+	}
+	if err := app.StakingKeeper.SetValidatorByConsAddr(ctx, newVal); err != nil {
+		return report, err
+	}
+	if err := app.StakingKeeper.SetValidatorByPowerIndex(ctx, newVal); err != nil {
+		return report, err
+	}
+	if err := app.StakingKeeper.SetLastValidatorPower(ctx, valAddr, 0); err != nil {
+		return report, err
+	}
+	if err := app.StakingKeeper.Hooks().AfterValidatorCreated(ctx, valAddr); err != nil {
+		return report, err
+	}
+
+	/* Handle distribution state. */
+
+	if err := app.DistrKeeper.SetValidatorHistoricalRewards(ctx, valAddr, 0, distrtypes.NewValidatorHistoricalRewards(sdk.DecCoins{}, 1)); err != nil {
+		return report, err
+	}
+	if err := app.DistrKeeper.SetValidatorCurrentRewards(ctx, valAddr, distrtypes.NewValidatorCurrentRewards(sdk.DecCoins{}, 1)); err != nil {
+		return report, err
+	}
+	if err := app.DistrKeeper.SetValidatorAccumulatedCommission(ctx, valAddr, distrtypes.InitialValidatorAccumulatedCommission()); err != nil {
+		return report, err
+	}
+	if err := app.DistrKeeper.SetValidatorOutstandingRewards(ctx, valAddr, distrtypes.ValidatorOutstandingRewards{Rewards: sdk.DecCoins{}}); err != nil {
+		return report, err
+	}
+
+	/* Handle slashing state. */
+
+	consAddr := sdk.ConsAddress(opts.ValidatorPubKey.Address())
+	consAddrStr, err := app.StakingKeeper.ConsensusAddressCodec().BytesToString(consAddr)
+	if err != nil {
+		return report, err
+	}
+
+	if err := app.SlashingKeeper.SetValidatorSigningInfo(ctx, consAddr, slashingtypes.ValidatorSigningInfo{
+		Address:     consAddrStr,
+		StartHeight: app.LastBlockHeight() - 1,
+		Tombstoned:  false,
+	}); err != nil {
+		return report, err
+	}
+
+	/* Handle bank state. */
+
+	if len(opts.FundAccounts) > 0 && !opts.FundCoins.IsZero() {
+		for _, addr := range opts.FundAccounts {
+			if err := app.BankKeeper.MintCoins(ctx, minttypes.ModuleName, opts.FundCoins); err != nil {
+				return report, err
+			}
+			if err := app.BankKeeper.SendCoinsFromModuleToAccount(ctx, minttypes.ModuleName, addr, opts.FundCoins); err != nil {
+				return report, err
+			}
+			report.FundedAccounts = append(report.FundedAccounts, addr.String())
+		}
+	}
+
+	/* Handle envoy state. */
+
+	if opts.ReassignEnvoyLocks {
+		// locks are held by the validator operators, as in the vote extensions
+		report.ReassignedLocks, err = app.reassignEnvoyLocks(ctx, sdk.AccAddress(valAddr))
+		if err != nil {
+			return report, fmt.Errorf("failed to reassign envoy locks: %w", err)
+		}
+	}
+
+	return report, nil
+}
+
+// reassignEnvoyLocks gives every envoy lock to holder, and returns their names.
+func (app *MiniApp) reassignEnvoyLocks(ctx sdk.Context, holder sdk.AccAddress) ([]string, error) {
+	var locks []envoy.Lock
+	if err := app.EnvoyKeeper.Locks.Walk(ctx, nil, func(_ string, lock envoy.Lock) (bool, error) {
+		locks = append(locks, lock)
+		return false, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(locks))
+	for _, lock := range locks {
+		lock.Envoy = holder.String()
+		if err := app.EnvoyKeeper.Locks.Set(ctx, lock.Name, lock); err != nil {
+			return nil, err
+		}
+		names = append(names, lock.Name)
+	}
+
+	return names, nil
+}
@@ -0,0 +1,111 @@
+package app
+
+import (
+	"testing"
+
+	abci "github.com/cometbft/cometbft/abci/types"
+	"github.com/cometbft/cometbft/crypto/ed25519"
+	cmtproto "github.com/cometbft/cometbft/proto/tendermint/types"
+	cmttypes "github.com/cometbft/cometbft/types"
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/math"
+
+	sdked25519 "github.com/cosmos/cosmos-sdk/crypto/keys/ed25519"
+	"github.com/cosmos/cosmos-sdk/crypto/keys/secp256k1"
+	"github.com/cosmos/cosmos-sdk/testutil/mock"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	stakingkeeper "github.com/cosmos/cosmos-sdk/x/staking/keeper"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+
+	"github.com/polygon/envoy"
+)
+
+func TestInitForInPlaceTestnet(t *testing.T) {
+	pubKey, err := mock.NewPV().GetPubKey()
+	require.NoError(t, err)
+	valSet := cmttypes.NewValidatorSet([]*cmttypes.Validator{cmttypes.NewValidator(pubKey, 1)})
+
+	senderPrivKey := secp256k1.GenPrivKey()
+	acc := authtypes.NewBaseAccount(senderPrivKey.PubKey().Address().Bytes(), senderPrivKey.PubKey(), 0, 0)
+	balance := banktypes.Balance{
+		Address: acc.GetAddress().String(),
+		Coins:   sdk.NewCoins(sdk.NewCoin(sdk.DefaultBondDenom, math.NewInt(100000000000000))),
+	}
+
+	app := SetupWithGenesisValSet(t, valSet, []authtypes.GenesisAccount{acc}, balance)
+
+	// fork a chain of two validators, the account operating the second one
+	createValMsg, err := stakingtypes.NewMsgCreateValidator(
+		sdk.ValAddress(acc.GetAddress()).String(),
+		sdked25519.GenPrivKey().PubKey(),
+		sdk.NewCoin(sdk.DefaultBondDenom, sdk.DefaultPowerReduction),
+		stakingtypes.NewDescription("second", "", "", "", ""),
+		stakingtypes.NewCommissionRates(math.LegacyNewDecWithPrec(1, 1), math.LegacyNewDecWithPrec(2, 1), math.LegacyNewDecWithPrec(1, 2)),
+		math.OneInt(),
+	)
+	require.NoError(t, err)
+	_, err = stakingkeeper.NewMsgServerImpl(app.StakingKeeper).CreateValidator(app.NewUncachedContext(false, cmtproto.Header{Height: app.LastBlockHeight() + 1}), createValMsg)
+	require.NoError(t, err)
+
+	NextBlock(t, app)
+	_, err = app.Commit()
+	require.NoError(t, err)
+
+	ctx := app.NewContextLegacy(true, cmtproto.Header{Height: app.LastBlockHeight()})
+	lastPowers, err := app.StakingKeeper.GetLastValidators(ctx)
+	require.NoError(t, err)
+	require.Len(t, lastPowers, 2)
+
+	holder := sdk.AccAddress("holder______________").String()
+	lock := envoy.Lock{Name: "lock1", Envoy: holder, AtBlock: 1, NumBlocks: 100}
+	require.NoError(t, app.EnvoyKeeper.Locks.Set(app.NewUncachedContext(false, cmtproto.Header{Height: app.LastBlockHeight()}), lock.Name, lock))
+
+	valPubKey := ed25519.GenPrivKey().PubKey()
+	operator := sdk.AccAddress(secp256k1.GenPrivKey().PubKey().Address())
+	fundCoins := sdk.NewCoins(sdk.NewCoin(sdk.DefaultBondDenom, math.NewInt(1000)))
+
+	report, err := app.InitForInPlaceTestnet(InPlaceTestnetOptions{
+		ValidatorPubKey:    valPubKey,
+		Operator:           operator,
+		FundAccounts:       []sdk.AccAddress{operator},
+		FundCoins:          fundCoins,
+		ReassignEnvoyLocks: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, sdk.ValAddress(operator).String(), report.Validator)
+	require.Equal(t, []string{operator.String()}, report.FundedAccounts)
+	require.Contains(t, report.ReassignedLocks, lock.Name)
+
+	// the next block makes the local validator the only one
+	res, err := app.FinalizeBlock(&abci.RequestFinalizeBlock{
+		Height: app.LastBlockHeight() + 1,
+		Hash:   app.LastCommitID().Hash,
+	})
+	require.NoError(t, err)
+	require.Len(t, res.ValidatorUpdates, 1)
+	require.Equal(t, valPubKey.Bytes(), res.ValidatorUpdates[0].PubKey.GetEd25519())
+	require.Equal(t, int64(InPlaceTestnetPower), res.ValidatorUpdates[0].Power)
+
+	_, err = app.Commit()
+	require.NoError(t, err)
+
+	ctx = app.NewContextLegacy(true, cmtproto.Header{Height: app.LastBlockHeight()})
+	lastPowers, err = app.StakingKeeper.GetLastValidators(ctx)
+	require.NoError(t, err)
+	require.Len(t, lastPowers, 1)
+	require.Equal(t, sdk.ValAddress(operator).String(), lastPowers[0].GetOperator())
+	require.True(t, lastPowers[0].IsBonded())
+
+	totalPower, err := app.StakingKeeper.GetLastTotalPower(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(InPlaceTestnetPower), totalPower.Int64())
+
+	require.Equal(t, fundCoins, app.BankKeeper.GetAllBalances(ctx, operator))
+
+	got, err := app.EnvoyKeeper.Locks.Get(ctx, lock.Name)
+	require.NoError(t, err)
+	require.Equal(t, sdk.AccAddress(operator).String(), got.Envoy)
+}
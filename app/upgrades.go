@@ -0,0 +1,45 @@
+package app
+
+import (
+	upgradetypes "cosmossdk.io/x/upgrade/types"
+
+	"github.com/polygon/procyon/app/upgrades"
+	v2 "github.com/polygon/procyon/app/upgrades/v2"
+	v3 "github.com/polygon/procyon/app/upgrades/v3"
+)
+
+// Upgrades lists every upgrade known to this binary.
+var Upgrades = []upgrades.Upgrade{
+	v2.Upgrade,
+	v3.Upgrade,
+}
+
+// registerUpgrades registers the handler of every known upgrade and, when the
+// node was halted for one of them, the store loader applying its store upgrades.
+// It must be called before the app is loaded.
+func (app *MiniApp) registerUpgrades() error {
+	for _, upgrade := range Upgrades {
+		app.UpgradeKeeper.SetUpgradeHandler(
+			upgrade.Name,
+			upgrade.CreateUpgradeHandler(app.ModuleManager, app.Configurator()),
+		)
+	}
+
+	upgradeInfo, err := app.UpgradeKeeper.ReadUpgradeInfoFromDisk()
+	if err != nil {
+		return err
+	}
+
+	if app.UpgradeKeeper.IsSkipHeight(upgradeInfo.Height) {
+		return nil
+	}
+
+	for i := range Upgrades {
+		if Upgrades[i].Name == upgradeInfo.Name {
+			app.SetStoreLoader(upgradetypes.UpgradeStoreLoader(upgradeInfo.Height, &Upgrades[i].StoreUpgrades))
+			break
+		}
+	}
+
+	return nil
+}
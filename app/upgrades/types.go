@@ -0,0 +1,23 @@
+package upgrades
+
+import (
+	storetypes "cosmossdk.io/store/types"
+	upgradetypes "cosmossdk.io/x/upgrade/types"
+
+	"github.com/cosmos/cosmos-sdk/types/module"
+)
+
+// Upgrade defines a named software upgrade. Every upgrade which changes module
+// state or the set of stores must be declared here and registered in the app,
+// so that a node running the new binary can migrate the existing state.
+type Upgrade struct {
+	// Name is the upgrade name, it must match the name of the upgrade plan.
+	Name string
+
+	// CreateUpgradeHandler returns the handler run at the upgrade height,
+	// it is expected to run the module version migrations.
+	CreateUpgradeHandler func(*module.Manager, module.Configurator) upgradetypes.UpgradeHandler
+
+	// StoreUpgrades lists the stores added, renamed or deleted by the upgrade.
+	StoreUpgrades storetypes.StoreUpgrades
+}
@@ -0,0 +1,29 @@
+package v2
+
+import (
+	"context"
+
+	storetypes "cosmossdk.io/store/types"
+	upgradetypes "cosmossdk.io/x/upgrade/types"
+
+	"github.com/cosmos/cosmos-sdk/types/module"
+
+	"github.com/polygon/procyon/app/upgrades"
+)
+
+// UpgradeName defines the on-chain upgrade name.
+const UpgradeName = "v2"
+
+var Upgrade = upgrades.Upgrade{
+	Name:                 UpgradeName,
+	CreateUpgradeHandler: CreateUpgradeHandler,
+	StoreUpgrades:        storetypes.StoreUpgrades{},
+}
+
+// CreateUpgradeHandler runs the migrations registered by every module whose
+// consensus version changed since the previous binary.
+func CreateUpgradeHandler(mm *module.Manager, configurator module.Configurator) upgradetypes.UpgradeHandler {
+	return func(ctx context.Context, _ upgradetypes.Plan, fromVM module.VersionMap) (module.VersionMap, error) {
+		return mm.RunMigrations(ctx, configurator, fromVM)
+	}
+}
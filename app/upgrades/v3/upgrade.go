@@ -0,0 +1,34 @@
+package v3
+
+import (
+	"context"
+
+	storetypes "cosmossdk.io/store/types"
+	upgradetypes "cosmossdk.io/x/upgrade/types"
+
+	"github.com/cosmos/cosmos-sdk/types/module"
+
+	"github.com/polygon/procyon/app/leases"
+	"github.com/polygon/procyon/app/upgrades"
+)
+
+// UpgradeName defines the on-chain upgrade name.
+const UpgradeName = "v3"
+
+var Upgrade = upgrades.Upgrade{
+	Name:                 UpgradeName,
+	CreateUpgradeHandler: CreateUpgradeHandler,
+	StoreUpgrades: storetypes.StoreUpgrades{
+		Added: []string{leases.StoreKey},
+	},
+}
+
+// CreateUpgradeHandler runs the module migrations. The leases module, missing
+// from the version map, is initialized by them from its default genesis,
+// setting its default params, seeding the lockable actions of the app config
+// and queueing the existing envoy locks for expiry.
+func CreateUpgradeHandler(mm *module.Manager, configurator module.Configurator) upgradetypes.UpgradeHandler {
+	return func(ctx context.Context, _ upgradetypes.Plan, fromVM module.VersionMap) (module.VersionMap, error) {
+		return mm.RunMigrations(ctx, configurator, fromVM)
+	}
+}
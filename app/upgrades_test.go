@@ -0,0 +1,114 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	cmtproto "github.com/cometbft/cometbft/proto/tendermint/types"
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/collections"
+	"cosmossdk.io/math"
+	upgradetypes "cosmossdk.io/x/upgrade/types"
+
+	simtestutil "github.com/cosmos/cosmos-sdk/testutil/sims"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/module"
+	banktestutil "github.com/cosmos/cosmos-sdk/x/bank/testutil"
+
+	"github.com/polygon/envoy"
+
+	"github.com/polygon/procyon/app/leases"
+	v2 "github.com/polygon/procyon/app/upgrades/v2"
+)
+
+func TestUpgrade(t *testing.T) {
+	app := Setup(t)
+
+	// populate some state which must survive the upgrade, in the store the
+	// already finalized block commits
+	ctx := app.NewUncachedContext(false, cmtproto.Header{Height: app.LastBlockHeight() + 1})
+	coins := sdk.NewCoins(sdk.NewCoin(sdk.DefaultBondDenom, math.NewInt(1000)))
+	addrs := simtestutil.CreateIncrementalAccounts(10)
+	for _, addr := range addrs {
+		require.NoError(t, banktestutil.FundAccount(ctx, app.BankKeeper, addr, coins))
+	}
+
+	// the handler is already in the binary, so the upgrade runs in the block
+	// after the finalized one, no block passing with the plan pending
+	upgradeHeight := app.LastBlockHeight() + 2
+	require.NoError(t, app.UpgradeKeeper.ScheduleUpgrade(ctx, upgradetypes.Plan{
+		Name:   v2.UpgradeName,
+		Height: upgradeHeight,
+	}))
+
+	for app.LastBlockHeight()+1 < upgradeHeight {
+		NextBlock(t, app)
+	}
+
+	ctx = app.NewContextLegacy(false, cmtproto.Header{Height: app.LastBlockHeight() + 1})
+	name, height, err := app.UpgradeKeeper.GetLastCompletedUpgrade(ctx)
+	require.NoError(t, err)
+	require.Equal(t, v2.UpgradeName, name)
+	require.Equal(t, upgradeHeight, height)
+
+	vm, err := app.UpgradeKeeper.GetModuleVersionMap(ctx)
+	require.NoError(t, err)
+	require.Equal(t, app.ModuleManager.GetVersionMap(), vm)
+
+	for _, addr := range addrs {
+		require.Equal(t, coins, app.BankKeeper.GetAllBalances(ctx, addr))
+	}
+}
+
+func TestUpgradeKeepsLeases(t *testing.T) {
+	app := Setup(t)
+
+	// the leases module is recorded at genesis, as the modules of the app config
+	ctx := app.NewUncachedContext(false, cmtproto.Header{Height: app.LastBlockHeight() + 1})
+	vm, err := app.UpgradeKeeper.GetModuleVersionMap(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(leases.ConsensusVersion), vm[leases.ModuleName])
+
+	params := leases.Params{MissThreshold: 7, SlashFraction: math.LegacyNewDecWithPrec(5, 1), Jail: true}
+	require.NoError(t, app.LeasesKeeper.Params.Set(ctx, params))
+	lock := envoy.Lock{Name: "lock1", Envoy: "alice", AtBlock: 1, NumBlocks: 5}
+	require.NoError(t, app.LeasesKeeper.History.Set(ctx, collections.Join("lock1", uint64(6)), lock))
+
+	// a later upgrade runs the migrations without initializing the module again
+	const upgradeName = "later"
+	app.UpgradeKeeper.SetUpgradeHandler(upgradeName, func(ctx context.Context, _ upgradetypes.Plan, fromVM module.VersionMap) (module.VersionMap, error) {
+		return app.ModuleManager.RunMigrations(ctx, app.Configurator(), fromVM)
+	})
+	upgradeHeight := app.LastBlockHeight() + 2
+	require.NoError(t, app.UpgradeKeeper.ScheduleUpgrade(ctx, upgradetypes.Plan{
+		Name:   upgradeName,
+		Height: upgradeHeight,
+	}))
+
+	for app.LastBlockHeight()+1 < upgradeHeight {
+		NextBlock(t, app)
+	}
+
+	ctx = app.NewContextLegacy(false, cmtproto.Header{Height: app.LastBlockHeight() + 1})
+	name, _, err := app.UpgradeKeeper.GetLastCompletedUpgrade(ctx)
+	require.NoError(t, err)
+	require.Equal(t, upgradeName, name)
+
+	got, err := app.LeasesKeeper.Params.Get(ctx)
+	require.NoError(t, err)
+	require.Equal(t, params, got)
+
+	history, err := app.LeasesKeeper.LockHistory(ctx, "lock1")
+	require.NoError(t, err)
+	require.Equal(t, []leases.Lease{leases.NewLease(lock, 6)}, history)
+}
+
+func TestUpgradesAreUnique(t *testing.T) {
+	names := make(map[string]bool)
+	for _, upgrade := range Upgrades {
+		require.NotEmpty(t, upgrade.Name)
+		require.False(t, names[upgrade.Name], "duplicate upgrade %s", upgrade.Name)
+		names[upgrade.Name] = true
+	}
+}
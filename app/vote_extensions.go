@@ -0,0 +1,363 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	abci "github.com/cometbft/cometbft/abci/types"
+	cmtproto "github.com/cometbft/cometbft/proto/tendermint/types"
+
+	"cosmossdk.io/core/address"
+
+	"github.com/cosmos/cosmos-sdk/baseapp"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+)
+
+// ErrLastCommitMismatch is returned when the extended commit injected in a
+// proposal is not the last commit of the block.
+var ErrLastCommitMismatch = errors.New("extended commit inconsistent with the last commit")
+
+// LockAttestation is the vote extension of a validator. It attests that the
+// validator operator holds the listed envoy locks at the vote height, and that
+// the validator is alive. CometBFT signs it with the validator consensus key.
+type LockAttestation struct {
+	Height int64    `json:"height"`
+	Locks  []string `json:"locks"`
+	// Completed are the held locks whose action job runs on the node, which
+	// keeps the holder from being penalised by the leases module.
+	Completed []string `json:"completed,omitempty"`
+}
+
+// RunningJobs reports the lockable actions whose job runs on the local node.
+type RunningJobs interface {
+	Running() []string
+}
+
+// ValidatorStore is the view of the staking state used by vote extensions.
+type ValidatorStore interface {
+	baseapp.ValidatorStore
+	GetValidatorByConsAddr(ctx context.Context, consAddr sdk.ConsAddress) (stakingtypes.Validator, error)
+	ValidatorAddressCodec() address.Codec
+}
+
+// VoteExtensionHandler attaches envoy lock attestations to precommit votes and
+// verifies the attestations of other validators. It is also the injector of
+// the extended commit of the previous height in the proposal, so every
+// validator sees the attestations envoy used to prepare it.
+type VoteExtensionHandler struct {
+	locks    EnvoyLocks
+	valStore ValidatorStore
+	consAddr sdk.ConsAddress
+	cfg      EnvoyConfig
+	jobs     RunningJobs
+}
+
+var (
+	_ ProposalInjector      = (*VoteExtensionHandler)(nil)
+	_ LastCommitVerifier    = (*VoteExtensionHandler)(nil)
+	_ DecidedCommitVerifier = (*VoteExtensionHandler)(nil)
+)
+
+// NewVoteExtensionHandler returns a VoteExtensionHandler for the validator
+// with the given consensus address, which is nil on nodes without one. It
+// attests the completion of the actions whose job runs, when jobs is not nil.
+func NewVoteExtensionHandler(
+	locks EnvoyLocks,
+	valStore ValidatorStore,
+	consAddr sdk.ConsAddress,
+	cfg EnvoyConfig,
+	jobs RunningJobs,
+) *VoteExtensionHandler {
+	return &VoteExtensionHandler{
+		locks:    locks,
+		valStore: valStore,
+		consAddr: consAddr,
+		cfg:      cfg,
+		jobs:     jobs,
+	}
+}
+
+// ExtendVote returns the handler attesting the locks held by the local
+// validator, for the actions the node is willing to take, and the completion
+// of those whose job runs.
+func (h *VoteExtensionHandler) ExtendVote() sdk.ExtendVoteHandler {
+	return func(ctx sdk.Context, req *abci.RequestExtendVote) (*abci.ResponseExtendVote, error) {
+		if h.consAddr == nil || !h.cfg.Enable {
+			return &abci.ResponseExtendVote{}, nil
+		}
+
+		if h.cfg.AttestTimeout > 0 {
+			timeoutCtx, cancel := context.WithTimeout(ctx.Context(), h.cfg.AttestTimeout)
+			defer cancel()
+			ctx = ctx.WithContext(timeoutCtx)
+		}
+
+		held, err := h.heldLocks(ctx, h.consAddr, req.Height)
+		if errors.Is(err, context.DeadlineExceeded) {
+			ctx.Logger().Error("no lock attestation, held locks lookup timed out", "height", req.Height, "timeout", h.cfg.AttestTimeout)
+			return &abci.ResponseExtendVote{}, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		var locks []string
+		for _, name := range held {
+			if h.cfg.Takes(name) {
+				locks = append(locks, name)
+			}
+		}
+
+		var completed []string
+		if h.jobs != nil {
+			for _, name := range h.jobs.Running() {
+				if slices.Contains(locks, name) {
+					completed = append(completed, name)
+				}
+			}
+		}
+
+		bz, err := json.Marshal(LockAttestation{Height: req.Height, Locks: locks, Completed: completed})
+		if err != nil {
+			return nil, err
+		}
+
+		return &abci.ResponseExtendVote{VoteExtension: bz}, nil
+	}
+}
+
+// VerifyVoteExtension returns the handler rejecting attestations for another
+// height, for locks the validator does not hold, or completing actions of
+// locks they do not attest.
+func (h *VoteExtensionHandler) VerifyVoteExtension() sdk.VerifyVoteExtensionHandler {
+	return func(ctx sdk.Context, req *abci.RequestVerifyVoteExtension) (*abci.ResponseVerifyVoteExtension, error) {
+		if err := h.verifyAttestation(ctx, req); err != nil {
+			ctx.Logger().Error("rejecting vote extension", "height", req.Height, "validator", sdk.ConsAddress(req.ValidatorAddress).String(), "err", err)
+			return &abci.ResponseVerifyVoteExtension{Status: abci.ResponseVerifyVoteExtension_REJECT}, nil
+		}
+
+		return &abci.ResponseVerifyVoteExtension{Status: abci.ResponseVerifyVoteExtension_ACCEPT}, nil
+	}
+}
+
+func (h *VoteExtensionHandler) verifyAttestation(ctx sdk.Context, req *abci.RequestVerifyVoteExtension) error {
+	// validators without a key file or without locks may not attest anything
+	if len(req.VoteExtension) == 0 {
+		return nil
+	}
+
+	attestation, err := DecodeLockAttestation(req.VoteExtension)
+	if err != nil {
+		return err
+	}
+
+	if attestation.Height != req.Height {
+		return fmt.Errorf("attestation for height %d", attestation.Height)
+	}
+
+	held, err := h.heldLocks(ctx, req.ValidatorAddress, req.Height)
+	if err != nil {
+		return err
+	}
+
+	heldSet := make(map[string]bool, len(held))
+	for _, name := range held {
+		heldSet[name] = true
+	}
+
+	for _, name := range attestation.Locks {
+		if !heldSet[name] {
+			return fmt.Errorf("lock %s not held by validator", name)
+		}
+	}
+
+	for _, name := range attestation.Completed {
+		if !slices.Contains(attestation.Locks, name) {
+			return fmt.Errorf("completed action %s of unattested lock", name)
+		}
+	}
+
+	return nil
+}
+
+// heldLocks returns the locks held by the operator of the validator.
+func (h *VoteExtensionHandler) heldLocks(ctx context.Context, consAddr sdk.ConsAddress, height int64) ([]string, error) {
+	return validatorHeldLocks(ctx, h.locks, h.valStore, consAddr, height)
+}
+
+// validatorHeldLocks returns the locks held by the operator of the validator
+// with the given consensus address, none if it is not a validator.
+func validatorHeldLocks(
+	ctx context.Context,
+	locks EnvoyLocks,
+	valStore ValidatorStore,
+	consAddr sdk.ConsAddress,
+	height int64,
+) ([]string, error) {
+	validator, err := valStore.GetValidatorByConsAddr(ctx, consAddr)
+	if errors.Is(err, stakingtypes.ErrNoValidatorFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	operator, err := valStore.ValidatorAddressCodec().StringToBytes(validator.GetOperator())
+	if err != nil {
+		return nil, err
+	}
+
+	return locks.HeldLocks(ctx, sdk.AccAddress(operator), height)
+}
+
+// PrepareInjection implements ProposalInjector, injecting the verified
+// extended commit of the previous height as the first proposal item.
+func (h *VoteExtensionHandler) PrepareInjection(ctx sdk.Context, req *abci.RequestPrepareProposal) ([][]byte, error) {
+	if !voteExtensionsEnabled(ctx, req.Height) {
+		return nil, nil
+	}
+
+	if err := baseapp.ValidateVoteExtensions(ctx, h.valStore, req.Height, ctx.ChainID(), req.LocalLastCommit); err != nil {
+		return nil, err
+	}
+
+	bz, err := req.LocalLastCommit.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	return [][]byte{bz}, nil
+}
+
+// ProcessInjection implements ProposalInjector, verifying the injected
+// extended commit.
+func (h *VoteExtensionHandler) ProcessInjection(ctx sdk.Context, req *abci.RequestProcessProposal, items [][]byte, _ int64) (int, error) {
+	if !voteExtensionsEnabled(ctx, req.Height) {
+		return 0, nil
+	}
+
+	if len(items) == 0 {
+		return 0, errors.New("missing extended commit")
+	}
+
+	if _, err := h.ExtendedCommit(ctx, req.Height, items[0], req.ProposedLastCommit); err != nil {
+		return 0, err
+	}
+
+	return 1, nil
+}
+
+// ExtendedCommit decodes the extended commit injected in the block of the
+// given height, and verifies it is the last commit of the block, with valid
+// vote extensions.
+func (h *VoteExtensionHandler) ExtendedCommit(ctx sdk.Context, height int64, item []byte, lastCommit abci.CommitInfo) (abci.ExtendedCommitInfo, error) {
+	var commit abci.ExtendedCommitInfo
+	if err := commit.Unmarshal(item); err != nil {
+		return commit, fmt.Errorf("malformed extended commit: %w", err)
+	}
+
+	if err := verifyLastCommit(commit, lastCommit); err != nil {
+		return commit, err
+	}
+
+	if err := baseapp.ValidateVoteExtensions(ctx, h.valStore, height, ctx.ChainID(), commit); err != nil {
+		return commit, err
+	}
+
+	return commit, nil
+}
+
+// verifyLastCommit verifies the extended commit has the votes of the last
+// commit: same round, same validators in the same order, with the same powers
+// and block ID flags. The vote extensions are only signed by the validators,
+// so the proposer could otherwise drop votes or change powers to forge a
+// majority.
+func verifyLastCommit(extCommit abci.ExtendedCommitInfo, lastCommit abci.CommitInfo) error {
+	if extCommit.Round != lastCommit.Round {
+		return fmt.Errorf("%w: round %d, not %d", ErrLastCommitMismatch, extCommit.Round, lastCommit.Round)
+	}
+
+	if len(extCommit.Votes) != len(lastCommit.Votes) {
+		return fmt.Errorf("%w: %d votes, not %d", ErrLastCommitMismatch, len(extCommit.Votes), len(lastCommit.Votes))
+	}
+
+	for i, vote := range extCommit.Votes {
+		expected := lastCommit.Votes[i]
+		switch {
+		case !bytes.Equal(vote.Validator.Address, expected.Validator.Address):
+			return fmt.Errorf("%w: vote %d of validator %X, not %X", ErrLastCommitMismatch, i, vote.Validator.Address, expected.Validator.Address)
+		case vote.Validator.Power != expected.Validator.Power:
+			return fmt.Errorf("%w: validator %X with power %d, not %d", ErrLastCommitMismatch, vote.Validator.Address, vote.Validator.Power, expected.Validator.Power)
+		case vote.BlockIdFlag != expected.BlockIdFlag:
+			return fmt.Errorf("%w: validator %X voted %s, not %s", ErrLastCommitMismatch, vote.Validator.Address, vote.BlockIdFlag, expected.BlockIdFlag)
+		}
+	}
+
+	return nil
+}
+
+// DecodeLockAttestation decodes the lock attestation of a vote extension.
+func DecodeLockAttestation(bz []byte) (LockAttestation, error) {
+	var attestation LockAttestation
+	if err := json.Unmarshal(bz, &attestation); err != nil {
+		return attestation, fmt.Errorf("malformed lock attestation: %w", err)
+	}
+
+	return attestation, nil
+}
+
+// DecodeLockAttestations returns the lock attestations of the committed votes
+// in the extended commit, by validator consensus address. Votes without an
+// extension are skipped.
+func DecodeLockAttestations(commit abci.ExtendedCommitInfo) (map[string]LockAttestation, error) {
+	attestations := make(map[string]LockAttestation, len(commit.Votes))
+	for _, vote := range commit.Votes {
+		if vote.BlockIdFlag != cmtproto.BlockIDFlagCommit || len(vote.VoteExtension) == 0 {
+			continue
+		}
+
+		attestation, err := DecodeLockAttestation(vote.VoteExtension)
+		if err != nil {
+			return nil, err
+		}
+		attestations[sdk.ConsAddress(vote.Validator.Address).String()] = attestation
+	}
+
+	return attestations, nil
+}
+
+// voteExtensionsEnabled returns whether the last commit seen at height
+// carries vote extensions.
+func voteExtensionsEnabled(ctx sdk.Context, height int64) bool {
+	cp := ctx.ConsensusParams()
+	return cp.Abci != nil && cp.Abci.VoteExtensionsEnableHeight > 0 && height > cp.Abci.VoteExtensionsEnableHeight
+}
+
+// LastCommit implements LastCommitVerifier, returning the verified extended
+// commit injected first in the proposal when vote extensions are enabled, else
+// the proposal's last commit.
+func (h *VoteExtensionHandler) LastCommit(ctx sdk.Context, req *abci.RequestProcessProposal) (abci.ExtendedCommitInfo, error) {
+	if !voteExtensionsEnabled(ctx, req.Height) {
+		return extendedCommitInfo(req.ProposedLastCommit), nil
+	}
+
+	if len(req.Txs) == 0 {
+		return abci.ExtendedCommitInfo{}, errors.New("missing extended commit")
+	}
+
+	return h.ExtendedCommit(ctx, req.Height, req.Txs[0], req.ProposedLastCommit)
+}
+
+// DecidedCommit implements DecidedCommitVerifier, returning the extended commit
+// injected first in the finalized block, verified against its decided last
+// commit as ProcessProposal verified it against the proposed one.
+func (h *VoteExtensionHandler) DecidedCommit(ctx sdk.Context, req *abci.RequestFinalizeBlock) (abci.ExtendedCommitInfo, error) {
+	if len(req.Txs) == 0 {
+		return abci.ExtendedCommitInfo{}, errors.New("missing extended commit")
+	}
+
+	return h.ExtendedCommit(ctx, req.Height, req.Txs[0], req.DecidedLastCommit)
+}
@@ -0,0 +1,312 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	abci "github.com/cometbft/cometbft/abci/types"
+	"github.com/cometbft/cometbft/crypto/ed25519"
+	cryptoenc "github.com/cometbft/cometbft/crypto/encoding"
+	cmtprotocrypto "github.com/cometbft/cometbft/proto/tendermint/crypto"
+	cmtproto "github.com/cometbft/cometbft/proto/tendermint/types"
+	cmttypes "github.com/cometbft/cometbft/types"
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/core/address"
+	"cosmossdk.io/log"
+
+	addresscodec "github.com/cosmos/cosmos-sdk/codec/address"
+	simtestutil "github.com/cosmos/cosmos-sdk/testutil/sims"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+)
+
+type mockLocks map[string][]string
+
+func (m mockLocks) HeldLocks(_ context.Context, holder sdk.AccAddress, _ int64) ([]string, error) {
+	return m[holder.String()], nil
+}
+
+// blockingLocks waits for the lookup to be given up.
+type blockingLocks struct{}
+
+func (blockingLocks) HeldLocks(ctx context.Context, _ sdk.AccAddress, _ int64) ([]string, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// runningJobs are the actions whose job runs.
+type runningJobs []string
+
+func (m runningJobs) Running() []string { return m }
+
+type mockValStore struct {
+	codec      address.Codec
+	validators map[string]stakingtypes.Validator
+	pubKeys    map[string]cmtprotocrypto.PublicKey
+}
+
+func (m mockValStore) GetPubKeyByConsAddr(_ context.Context, consAddr sdk.ConsAddress) (cmtprotocrypto.PublicKey, error) {
+	return m.pubKeys[consAddr.String()], nil
+}
+
+func (m mockValStore) GetValidatorByConsAddr(_ context.Context, consAddr sdk.ConsAddress) (stakingtypes.Validator, error) {
+	validator, ok := m.validators[consAddr.String()]
+	if !ok {
+		return validator, stakingtypes.ErrNoValidatorFound
+	}
+	return validator, nil
+}
+
+func (m mockValStore) ValidatorAddressCodec() address.Codec {
+	return m.codec
+}
+
+func TestVoteExtensions(t *testing.T) {
+	ctx := sdk.Context{}.WithLogger(log.NewNopLogger())
+
+	consAddr := sdk.ConsAddress("validator___________")
+	operator := sdk.AccAddress("operator____________")
+	valCodec := addresscodec.NewBech32Codec(sdk.GetConfig().GetBech32ValidatorAddrPrefix())
+	valoper, err := valCodec.BytesToString(operator)
+	require.NoError(t, err)
+
+	valStore := mockValStore{
+		codec:      valCodec,
+		validators: map[string]stakingtypes.Validator{consAddr.String(): {OperatorAddress: valoper}},
+	}
+	locks := mockLocks{operator.String(): {"lock1", "lock2"}}
+
+	// only the jobs of held locks are completed
+	handler := NewVoteExtensionHandler(locks, valStore, consAddr, DefaultEnvoyConfig(), runningJobs{"lock2", "lock3"})
+	resp, err := handler.ExtendVote()(ctx, &abci.RequestExtendVote{Height: 10})
+	require.NoError(t, err)
+
+	attestation, err := DecodeLockAttestation(resp.VoteExtension)
+	require.NoError(t, err)
+	require.Equal(t, LockAttestation{Height: 10, Locks: []string{"lock1", "lock2"}, Completed: []string{"lock2"}}, attestation)
+
+	testCases := []struct {
+		name      string
+		extension []byte
+		height    int64
+		validator sdk.ConsAddress
+		expStatus abci.ResponseVerifyVoteExtension_VerifyStatus
+	}{
+		{"own attestation", resp.VoteExtension, 10, consAddr, abci.ResponseVerifyVoteExtension_ACCEPT},
+		{"partial attestation", []byte(`{"height":10,"locks":["lock2"]}`), 10, consAddr, abci.ResponseVerifyVoteExtension_ACCEPT},
+		{"empty extension", nil, 10, consAddr, abci.ResponseVerifyVoteExtension_ACCEPT},
+		{"other height", resp.VoteExtension, 11, consAddr, abci.ResponseVerifyVoteExtension_REJECT},
+		{"other validator", resp.VoteExtension, 10, sdk.ConsAddress("other_______________"), abci.ResponseVerifyVoteExtension_REJECT},
+		{"lock not held", []byte(`{"height":10,"locks":["lock3"]}`), 10, consAddr, abci.ResponseVerifyVoteExtension_REJECT},
+		{"completed unattested lock", []byte(`{"height":10,"locks":["lock2"],"completed":["lock1"]}`), 10, consAddr, abci.ResponseVerifyVoteExtension_REJECT},
+		{"malformed", []byte("garbage"), 10, consAddr, abci.ResponseVerifyVoteExtension_REJECT},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := handler.VerifyVoteExtension()(ctx, &abci.RequestVerifyVoteExtension{
+				Height:           tc.height,
+				ValidatorAddress: tc.validator,
+				VoteExtension:    tc.extension,
+			})
+			require.NoError(t, err)
+			require.Equal(t, tc.expStatus, resp.Status)
+		})
+	}
+}
+
+// signedCommit returns the extended commit of a block at height-1 committed by
+// the validators with the given keys and powers, the extensions signed by the
+// committing ones, and the last commit of the block.
+func signedCommit(
+	t *testing.T,
+	chainID string,
+	height int64,
+	keys []ed25519.PrivKey,
+	powers []int64,
+	flags []cmtproto.BlockIDFlag,
+) (abci.ExtendedCommitInfo, abci.CommitInfo) {
+	t.Helper()
+
+	var (
+		extCommit = abci.ExtendedCommitInfo{Round: 1}
+		commit    = abci.CommitInfo{Round: 1}
+	)
+	for i, key := range keys {
+		validator := abci.Validator{Address: key.PubKey().Address(), Power: powers[i]}
+		vote := abci.ExtendedVoteInfo{Validator: validator, BlockIdFlag: flags[i]}
+		if flags[i] == cmtproto.BlockIDFlagCommit {
+			vote.VoteExtension = []byte(`{"height":9,"locks":[]}`)
+			signature, err := key.Sign(cmttypes.VoteExtensionSignBytes(chainID, &cmtproto.Vote{
+				Height:    height - 1,
+				Round:     extCommit.Round,
+				Extension: vote.VoteExtension,
+			}))
+			require.NoError(t, err)
+			vote.ExtensionSignature = signature
+		}
+
+		extCommit.Votes = append(extCommit.Votes, vote)
+		commit.Votes = append(commit.Votes, abci.VoteInfo{Validator: validator, BlockIdFlag: flags[i]})
+	}
+
+	return extCommit, commit
+}
+
+func TestVoteExtensionInjection(t *testing.T) {
+	const chainID = "procyon-test"
+	ctx := sdk.Context{}.WithLogger(log.NewNopLogger()).WithChainID(chainID).
+		WithConsensusParams(cmtproto.ConsensusParams{Abci: &cmtproto.ABCIParams{VoteExtensionsEnableHeight: 2}})
+
+	keys := []ed25519.PrivKey{ed25519.GenPrivKey(), ed25519.GenPrivKey(), ed25519.GenPrivKey(), ed25519.GenPrivKey()}
+	valStore := mockValStore{pubKeys: make(map[string]cmtprotocrypto.PublicKey)}
+	for _, key := range keys {
+		pubKey, err := cryptoenc.PubKeyToProto(key.PubKey())
+		require.NoError(t, err)
+		valStore.pubKeys[sdk.ConsAddress(key.PubKey().Address()).String()] = pubKey
+	}
+	handler := NewVoteExtensionHandler(mockLocks{}, valStore, nil, DefaultEnvoyConfig(), nil)
+
+	powers := []int64{10, 10, 10, 10}
+	flags := []cmtproto.BlockIDFlag{cmtproto.BlockIDFlagCommit, cmtproto.BlockIDFlagCommit, cmtproto.BlockIDFlagCommit, cmtproto.BlockIDFlagAbsent}
+	extCommit, lastCommit := signedCommit(t, chainID, 10, keys, powers, flags)
+
+	items, err := handler.PrepareInjection(ctx, &abci.RequestPrepareProposal{Height: 10, LocalLastCommit: extCommit})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+
+	n, err := handler.ProcessInjection(ctx, &abci.RequestProcessProposal{Height: 10, ProposedLastCommit: lastCommit}, items, 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	// the commit of the finalized block is verified as the proposal's
+	decided, err := handler.DecidedCommit(ctx, &abci.RequestFinalizeBlock{Height: 10, Txs: items, DecidedLastCommit: lastCommit})
+	require.NoError(t, err)
+	require.Equal(t, extCommit, decided)
+
+	// a forged vote extension is neither injected nor accepted
+	forged, _ := signedCommit(t, chainID, 10, keys, powers, flags)
+	forged.Votes[1].VoteExtension = []byte(`{"height":9,"locks":["lock1"]}`)
+	_, err = handler.PrepareInjection(ctx, &abci.RequestPrepareProposal{Height: 10, LocalLastCommit: forged})
+	require.ErrorContains(t, err, "failed to verify validator")
+
+	testCases := []struct {
+		name   string
+		mutate func(commit *abci.ExtendedCommitInfo)
+		expErr string
+	}{
+		{"forged commit", func(commit *abci.ExtendedCommitInfo) {
+			commit.Votes[1].VoteExtension = []byte(`{"height":9,"locks":["lock1"]}`)
+		}, "failed to verify validator"},
+		{"dropped vote", func(commit *abci.ExtendedCommitInfo) {
+			commit.Votes = commit.Votes[1:]
+		}, "3 votes, not 4"},
+		{"reordered votes", func(commit *abci.ExtendedCommitInfo) {
+			commit.Votes[0], commit.Votes[1] = commit.Votes[1], commit.Votes[0]
+		}, "vote 0 of validator"},
+		{"changed power", func(commit *abci.ExtendedCommitInfo) {
+			commit.Votes[0].Validator.Power = 100
+		}, "with power 100, not 10"},
+		{"changed block ID flag", func(commit *abci.ExtendedCommitInfo) {
+			commit.Votes[2].BlockIdFlag = cmtproto.BlockIDFlagNil
+		}, "voted BLOCK_ID_FLAG_NIL, not BLOCK_ID_FLAG_COMMIT"},
+		{"other round", func(commit *abci.ExtendedCommitInfo) {
+			commit.Round = 2
+		}, "round 2, not 1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			commit, lastCommit := signedCommit(t, chainID, 10, keys, powers, flags)
+			tc.mutate(&commit)
+			bz, err := commit.Marshal()
+			require.NoError(t, err)
+
+			_, err = handler.ProcessInjection(ctx, &abci.RequestProcessProposal{Height: 10, ProposedLastCommit: lastCommit}, [][]byte{bz}, 0)
+			require.ErrorContains(t, err, tc.expErr)
+
+			_, err = handler.DecidedCommit(ctx, &abci.RequestFinalizeBlock{Height: 10, Txs: [][]byte{bz}, DecidedLastCommit: lastCommit})
+			require.ErrorContains(t, err, tc.expErr)
+		})
+	}
+
+	_, err = handler.ProcessInjection(ctx, &abci.RequestProcessProposal{Height: 10, ProposedLastCommit: lastCommit}, [][]byte{[]byte("garbage")}, 0)
+	require.ErrorContains(t, err, "malformed extended commit")
+}
+
+func TestExtendVoteWithoutValidator(t *testing.T) {
+	ctx := sdk.Context{}.WithLogger(log.NewNopLogger())
+
+	handler := NewVoteExtensionHandler(mockLocks{}, mockValStore{}, nil, DefaultEnvoyConfig(), nil)
+	resp, err := handler.ExtendVote()(ctx, &abci.RequestExtendVote{Height: 10})
+	require.NoError(t, err)
+	require.Empty(t, resp.VoteExtension)
+}
+
+func TestExtendVoteEnvoyConfig(t *testing.T) {
+	ctx := sdk.Context{}.WithContext(context.Background()).WithLogger(log.NewNopLogger())
+
+	consAddr := sdk.ConsAddress("validator___________")
+	operator := sdk.AccAddress("operator____________")
+	valCodec := addresscodec.NewBech32Codec(sdk.GetConfig().GetBech32ValidatorAddrPrefix())
+	valoper, err := valCodec.BytesToString(operator)
+	require.NoError(t, err)
+
+	valStore := mockValStore{
+		codec:      valCodec,
+		validators: map[string]stakingtypes.Validator{consAddr.String(): {OperatorAddress: valoper}},
+	}
+	locks := mockLocks{operator.String(): {"lock1", "lock2"}}
+
+	testCases := []struct {
+		name     string
+		locks    EnvoyLocks
+		cfg      EnvoyConfig
+		expLocks []string
+		expEmpty bool
+	}{
+		{"every action", locks, EnvoyConfig{Enable: true}, []string{"lock1", "lock2"}, false},
+		{"some actions", locks, EnvoyConfig{Enable: true, Actions: []string{"lock2", "lock3"}}, []string{"lock2"}, false},
+		{"disabled", locks, EnvoyConfig{Enable: false}, nil, true},
+		{"timed out", blockingLocks{}, EnvoyConfig{Enable: true, AttestTimeout: time.Millisecond}, nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := NewVoteExtensionHandler(tc.locks, valStore, consAddr, tc.cfg, nil)
+			resp, err := handler.ExtendVote()(ctx, &abci.RequestExtendVote{Height: 10})
+			require.NoError(t, err)
+
+			if tc.expEmpty {
+				require.Empty(t, resp.VoteExtension)
+				return
+			}
+
+			attestation, err := DecodeLockAttestation(resp.VoteExtension)
+			require.NoError(t, err)
+			require.Equal(t, tc.expLocks, attestation.Locks)
+		})
+	}
+}
+
+func TestReadEnvoyConfig(t *testing.T) {
+	cfg, err := ReadEnvoyConfig(simtestutil.AppOptionsMap{})
+	require.NoError(t, err)
+	require.Equal(t, DefaultEnvoyConfig(), cfg)
+
+	cfg, err = ReadEnvoyConfig(simtestutil.AppOptionsMap{
+		"envoy.enable":         "false",
+		"envoy.actions":        []any{"lock1", "lock2"},
+		"envoy.attest-timeout": "2s",
+	})
+	require.NoError(t, err)
+	require.Equal(t, EnvoyConfig{Enable: false, Actions: []string{"lock1", "lock2"}, AttestTimeout: 2 * time.Second}, cfg)
+
+	_, err = ReadEnvoyConfig(simtestutil.AppOptionsMap{"envoy.actions": []string{"lock1", "lock1"}})
+	require.ErrorContains(t, err, "duplicate action lock1")
+
+	_, err = ReadEnvoyConfig(simtestutil.AppOptionsMap{"envoy.attest-timeout": "-1s"})
+	require.ErrorContains(t, err, "negative attest timeout")
+}
@@ -0,0 +1,239 @@
+package cmd_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	abci "github.com/cometbft/cometbft/abci/types"
+	dbm "github.com/cosmos/cosmos-db"
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/log"
+
+	"github.com/cosmos/cosmos-sdk/baseapp"
+	"github.com/cosmos/cosmos-sdk/client/flags"
+	svrcmd "github.com/cosmos/cosmos-sdk/server/cmd"
+	simtestutil "github.com/cosmos/cosmos-sdk/testutil/sims"
+	genutiltypes "github.com/cosmos/cosmos-sdk/x/genutil/types"
+
+	"github.com/polygon/procyon/app"
+	"github.com/polygon/procyon/app/leases"
+	"github.com/polygon/procyon/cmd/procyon/cmd"
+	"github.com/polygon/procyon/testutil/network"
+)
+
+// execute runs a new root command with args, and returns what it wrote to its
+// output and error output.
+func execute(args ...string) (stdout, stderr []byte, err error) {
+	rootCmd := cmd.NewRootCmd()
+
+	var outBuf, errBuf bytes.Buffer
+	rootCmd.SetOut(&outBuf)
+	rootCmd.SetErr(&errBuf)
+	rootCmd.SetArgs(args)
+
+	err = svrcmd.Execute(rootCmd, "", app.DefaultNodeHome)
+	return outBuf.Bytes(), errBuf.Bytes(), err
+}
+
+// cli runs the root command with args, and returns its output.
+func cli(t *testing.T, args ...string) []byte {
+	t.Helper()
+
+	stdout, stderr, err := execute(args...)
+	require.NoError(t, err, "procyon %s: %s", strings.Join(args, " "), stderr)
+
+	return stdout
+}
+
+// cliJSON runs the root command with args, and decodes its JSON output.
+func cliJSON(t *testing.T, args ...string) map[string]any {
+	t.Helper()
+
+	var res map[string]any
+	out := cli(t, args...)
+	require.NoError(t, json.Unmarshal(out, &res), string(out))
+
+	return res
+}
+
+func TestCLIGenesis(t *testing.T) {
+	home := t.TempDir()
+	keyring := []string{"--keyring-backend", "test", "--home", home}
+
+	// init prints its summary to the process stderr, so check its files
+	cli(t, "init", "test", "--chain-id", "e2e", "--default-denom", "mini", "--home", home)
+
+	initGenesis, err := genutiltypes.AppGenesisFromFile(filepath.Join(home, "config", "genesis.json"))
+	require.NoError(t, err)
+	require.Equal(t, "e2e", initGenesis.ChainID)
+
+	config, err := os.ReadFile(filepath.Join(home, "config", "config.toml"))
+	require.NoError(t, err)
+	require.Contains(t, string(config), `moniker = "test"`)
+
+	key := cliJSON(t, append([]string{"keys", "add", "alice", "--output", "json"}, keyring...)...)
+	require.Equal(t, "alice", key["name"])
+	require.NotEmpty(t, key["mnemonic"])
+
+	addr := strings.TrimSpace(string(cli(t, append([]string{"keys", "show", "alice", "-a"}, keyring...)...)))
+	require.Equal(t, key["address"], addr)
+
+	cli(t, append([]string{"genesis", "add-genesis-account", "alice", "100000000mini"}, keyring...)...)
+	cli(t, append([]string{"genesis", "gentx", "alice", "1000000mini", "--chain-id", "e2e"}, keyring...)...)
+	cli(t, "genesis", "collect-gentxs", "--home", home)
+	cli(t, "genesis", "validate", "--home", home)
+
+	appGenesis, err := genutiltypes.AppGenesisFromFile(filepath.Join(home, "config", "genesis.json"))
+	require.NoError(t, err)
+
+	var appState map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(appGenesis.AppState, &appState))
+
+	// the JSON tag of the generated genutil state is not its proto name
+	var genutilState struct {
+		GenTxs []json.RawMessage `json:"gen_txs"`
+	}
+	require.NoError(t, json.Unmarshal(appState[genutiltypes.ModuleName], &genutilState))
+	require.Len(t, genutilState.GenTxs, 1)
+
+	// commit the first block of the genesis to the home, to export it
+	db, err := dbm.NewDB("application", dbm.GoLevelDBBackend, filepath.Join(home, "data"))
+	require.NoError(t, err)
+
+	// the gentx signatures are verified against the chain ID
+	miniApp, err := app.NewMiniApp(log.NewNopLogger(), db, nil, true, simtestutil.AppOptionsMap{flags.FlagHome: home}, baseapp.SetChainID("e2e"))
+	require.NoError(t, err)
+
+	consensusParams := appGenesis.Consensus.Params.ToProto()
+	_, err = miniApp.InitChain(&abci.RequestInitChain{
+		ChainId:         appGenesis.ChainID,
+		InitialHeight:   appGenesis.InitialHeight,
+		ConsensusParams: &consensusParams,
+		AppStateBytes:   appGenesis.AppState,
+	})
+	require.NoError(t, err)
+
+	_, err = miniApp.FinalizeBlock(&abci.RequestFinalizeBlock{Height: 1, Hash: miniApp.LastCommitID().Hash})
+	require.NoError(t, err)
+	_, err = miniApp.Commit()
+	require.NoError(t, err)
+	require.NoError(t, miniApp.Close())
+
+	exported := cliJSON(t, "export", "--home", home)
+	require.Equal(t, "e2e", exported["chain_id"])
+	require.EqualValues(t, 2, exported["initial_height"])
+
+	exportedState := exported["app_state"].(map[string]any)
+	require.Contains(t, exportedState, "envoy")
+	staking := exportedState["staking"].(map[string]any)
+	require.Len(t, staking["validators"], 1)
+}
+
+func TestCLINetwork(t *testing.T) {
+	net := network.New(t)
+	val := net.Validators[0]
+	chainID := net.Config.ChainID
+	fees := "10" + net.Config.BondDenom
+
+	home := t.TempDir()
+	keyring := []string{"--keyring-backend", "test", "--keyring-dir", val.ClientCtx.KeyringDir, "--home", home}
+	txFlags := append([]string{"--chain-id", chainID, "--node", val.RPCAddress, "--fees", fees, "--output", "json"}, keyring...)
+	queryFlags := []string{"--node", val.RPCAddress, "--output", "json", "--home", home}
+
+	// the validator key is the one of the network
+	valAddr := strings.TrimSpace(string(cli(t, append([]string{"keys", "show", "node0", "-a"}, keyring...)...)))
+	require.Equal(t, val.Address.String(), valAddr)
+
+	bob := cliJSON(t, append([]string{"keys", "add", "bob", "--output", "json"}, keyring...)...)
+	bobAddr := bob["address"].(string)
+
+	t.Run("tx sign simulate broadcast", func(t *testing.T) {
+		dir := t.TempDir()
+		unsignedFile := filepath.Join(dir, "unsigned.json")
+		signedFile := filepath.Join(dir, "signed.json")
+
+		unsigned := cli(t, append([]string{"tx", "bank", "send", "node0", bobAddr, "1000" + net.Config.BondDenom, "--generate-only"}, txFlags...)...)
+		require.NoError(t, os.WriteFile(unsignedFile, unsigned, 0o600))
+
+		sim := cli(t, append([]string{"tx", "simulate", unsignedFile, "--from", "node0"}, txFlags...)...)
+		require.Contains(t, string(sim), "gas_used")
+
+		cli(t, append([]string{"tx", "sign", unsignedFile, "--from", "node0", "--output-document", signedFile}, txFlags...)...)
+
+		res := cliJSON(t, "tx", "broadcast", signedFile, "--node", val.RPCAddress, "--output", "json", "--home", home)
+		require.EqualValues(t, 0, res["code"], res["raw_log"])
+		txHash := res["txhash"].(string)
+
+		network.WaitForBlocks(t, net, 2)
+
+		tx := cliJSON(t, append([]string{"query", "tx", txHash}, queryFlags...)...)
+		require.EqualValues(t, 0, tx["code"])
+
+		txs := cliJSON(t, append([]string{"query", "txs", "--query", "message.sender='" + valAddr + "'"}, queryFlags...)...)
+		require.NotEmpty(t, txs["txs"])
+
+		balance := cliJSON(t, append([]string{"query", "bank", "balance", bobAddr, net.Config.BondDenom}, queryFlags...)...)
+		require.Equal(t, "1000", balance["balance"].(map[string]any)["amount"])
+	})
+
+	t.Run("query block", func(t *testing.T) {
+		block := cliJSON(t, append([]string{"query", "block", "--type=height", "1"}, queryFlags...)...)
+		header := block["header"].(map[string]any)
+		require.Equal(t, chainID, header["chain_id"])
+		require.Equal(t, "1", header["height"])
+	})
+
+	t.Run("envoy lock", func(t *testing.T) {
+		res := cliJSON(t, append([]string{"tx", "envoy", "create", "lock1", valAddr, "666", "12", "--from", "node0", "--yes"}, txFlags...)...)
+		if !leases.ManualLocks {
+			// the locks are only created by hand in dev builds
+			require.NotEqualValues(t, 0, res["code"])
+			require.Contains(t, res["raw_log"], "dev builds")
+			return
+		}
+		require.EqualValues(t, 0, res["code"], res["raw_log"])
+
+		network.WaitForBlocks(t, net, 2)
+
+		lock := cliJSON(t, append([]string{"query", "envoy", "get-lock", "lock1"}, queryFlags...)...)
+		require.Equal(t, map[string]any{
+			"name":       "lock1",
+			"envoy":      valAddr,
+			"at_block":   "666",
+			"num_blocks": "12",
+		}, lock["lock"])
+	})
+
+	t.Run("action lease", func(t *testing.T) {
+		actions, err := app.LockableActions()
+		require.NoError(t, err)
+		require.NotEmpty(t, actions)
+
+		// the lock of the action is leased to one of the validators
+		res := cliJSON(t, append([]string{"query", "envoy", "get-lock", actions[0].Name}, queryFlags...)...)
+		lock := res["lock"].(map[string]any)
+
+		holders := make([]any, 0, len(net.Validators))
+		for _, v := range net.Validators {
+			holders = append(holders, v.Address.String())
+		}
+		require.Contains(t, holders, lock["envoy"])
+		require.Equal(t, strconv.FormatUint(actions[0].LeaseBlocks, 10), lock["num_blocks"])
+
+		history := cliJSON(t, append([]string{"query", "leases", "history", actions[0].Name}, queryFlags...)...)
+		require.Contains(t, history, "history")
+
+		params := cliJSON(t, append([]string{"query", "leases", "params"}, queryFlags...)...)
+		require.Equal(t, map[string]any{
+			"miss_threshold": "20",
+			"slash_fraction": "0.010000000000000000",
+			"jail":           true,
+		}, params["params"])
+	})
+}
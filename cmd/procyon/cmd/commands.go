@@ -23,9 +23,11 @@ import (
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/cosmos-sdk/types/module"
 	authcmd "github.com/cosmos/cosmos-sdk/x/auth/client/cli"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
 	genutilcli "github.com/cosmos/cosmos-sdk/x/genutil/client/cli"
 
 	"github.com/polygon/procyon/app"
+	"github.com/polygon/procyon/app/leases"
 )
 
 func initRootCmd(rootCmd *cobra.Command, txConfig client.TxConfig, basicManager module.BasicManager) {
@@ -34,13 +36,17 @@ func initRootCmd(rootCmd *cobra.Command, txConfig client.TxConfig, basicManager
 
 	rootCmd.AddCommand(
 		genutilcli.InitCmd(basicManager, app.DefaultNodeHome),
+		NewTestnetCmd(basicManager, banktypes.GenesisBalancesIterator{}),
+		inPlaceTestnetCmd(),
+		devnetCmd(basicManager),
 		debug.Cmd(),
 		confixcmd.ConfigCommand(),
 		pruning.Cmd(newApp, app.DefaultNodeHome),
 		snapshot.Cmd(newApp),
 	)
 
-	server.AddCommands(rootCmd, app.DefaultNodeHome, newApp, appExport, func(startCmd *cobra.Command) {})
+	server.AddCommands(rootCmd, app.DefaultNodeHome, newApp, appExport, addModuleInitFlags)
+	extendExportCmd(rootCmd)
 
 	// add keybase, auxiliary RPC, query, genesis, and tx child commands
 	rootCmd.AddCommand(
@@ -52,6 +58,10 @@ func initRootCmd(rootCmd *cobra.Command, txConfig client.TxConfig, basicManager
 	)
 }
 
+func addModuleInitFlags(startCmd *cobra.Command) {
+	startCmd.Flags().String(app.FlagGenesisDir, "", "Read the genesis of each module from its own file in this directory, the genesis file having an empty app state")
+}
+
 func queryCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:                        "query",
@@ -69,6 +79,7 @@ func queryCommand() *cobra.Command {
 		server.QueryBlocksCmd(),
 		authcmd.QueryTxCmd(),
 		server.QueryBlockResultsCmd(),
+		leases.GetQueryCmd(),
 	)
 
 	return cmd
@@ -120,42 +131,51 @@ func appExport(
 	appOpts servertypes.AppOptions,
 	modulesToExport []string,
 ) (servertypes.ExportedApp, error) {
-	var (
-		miniApp *app.MiniApp
-		err     error
-	)
+	miniApp, err := loadExportApp(logger, db, traceStore, height, appOpts)
+	if err != nil {
+		return servertypes.ExportedApp{}, err
+	}
 
+	return miniApp.ExportAppStateAndValidators(forZeroHeight, jailAllowedAddrs, modulesToExport)
+}
+
+// loadExportApp creates a new app for export, loaded at the given height
+// unless it is -1.
+func loadExportApp(
+	logger log.Logger,
+	db dbm.DB,
+	traceStore io.Writer,
+	height int64,
+	appOpts servertypes.AppOptions,
+) (*app.MiniApp, error) {
 	// this check is necessary as we use the flag in x/upgrade.
 	// we can exit more gracefully by checking the flag here.
 	homePath, ok := appOpts.Get(flags.FlagHome).(string)
 	if !ok || homePath == "" {
-		return servertypes.ExportedApp{}, errors.New("application home not set")
+		return nil, errors.New("application home not set")
 	}
 
 	viperAppOpts, ok := appOpts.(*viper.Viper)
 	if !ok {
-		return servertypes.ExportedApp{}, errors.New("appOpts is not viper.Viper")
+		return nil, errors.New("appOpts is not viper.Viper")
 	}
 
 	// overwrite the FlagInvCheckPeriod
 	viperAppOpts.Set(server.FlagInvCheckPeriod, 1)
 	appOpts = viperAppOpts
 
-	if height != -1 {
-		miniApp, err = app.NewMiniApp(logger, db, traceStore, false, appOpts)
-		if err != nil {
-			return servertypes.ExportedApp{}, err
-		}
-
-		if err := miniApp.LoadHeight(height); err != nil {
-			return servertypes.ExportedApp{}, err
-		}
-	} else {
-		miniApp, err = app.NewMiniApp(logger, db, traceStore, true, appOpts)
-		if err != nil {
-			return servertypes.ExportedApp{}, err
-		}
+	if height == -1 {
+		return app.NewMiniApp(logger, db, traceStore, true, appOpts)
 	}
 
-	return miniApp.ExportAppStateAndValidators(forZeroHeight, jailAllowedAddrs, modulesToExport)
+	miniApp, err := app.NewMiniApp(logger, db, traceStore, false, appOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := miniApp.LoadHeight(height); err != nil {
+		return nil, err
+	}
+
+	return miniApp, nil
 }
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"github.com/spf13/viper"
+
+	serverconfig "github.com/cosmos/cosmos-sdk/server/config"
+
+	"github.com/polygon/procyon/app"
+	"github.com/polygon/procyon/app/params"
+)
+
+// CustomAppConfig is the app.toml config of procyon: the server config, with
+// the envoy section read by the app.
+type CustomAppConfig struct {
+	serverconfig.Config `mapstructure:",squash"`
+
+	Envoy app.EnvoyConfig `mapstructure:"envoy"`
+}
+
+const envoyConfigTemplate = `
+###############################################################################
+###                           Envoy Configuration                           ###
+###############################################################################
+
+[envoy]
+
+# Attest the envoy locks held by the operator of the local validator in its
+# votes. A disabled node still verifies the attestations of the others.
+enable = {{ .Envoy.Enable }}
+
+# Lockable actions this node is willing to take, named as their locks. The
+# locks of other actions are not attested. Empty means every action.
+actions = [{{ range $i, $action := .Envoy.Actions }}{{ if $i }}, {{ end }}"{{ $action }}"{{ end }}]
+
+# Time allowed to look up the held locks when extending a vote, past which the
+# vote carries no attestation, e.g. "500ms". "0s" means no limit.
+attest-timeout = "{{ .Envoy.AttestTimeout }}"
+`
+
+// initAppConfig returns the template and the default values of app.toml.
+func initAppConfig() (string, interface{}) {
+	return serverconfig.DefaultConfigTemplate + envoyConfigTemplate, defaultAppConfig()
+}
+
+// defaultAppConfig returns the default app config, accepting txs without fees.
+func defaultAppConfig() CustomAppConfig {
+	srvCfg := serverconfig.DefaultConfig()
+	srvCfg.MinGasPrices = params.DefaultMinGasPrices()
+
+	return CustomAppConfig{
+		Config: *srvCfg,
+		Envoy:  app.DefaultEnvoyConfig(),
+	}
+}
+
+// readAppConfig returns the app config read by v.
+func readAppConfig(v *viper.Viper) (CustomAppConfig, error) {
+	srvCfg, err := serverconfig.GetConfig(v)
+	if err != nil {
+		return CustomAppConfig{}, err
+	}
+
+	envoyCfg, err := app.ReadEnvoyConfig(v)
+	if err != nil {
+		return CustomAppConfig{}, err
+	}
+
+	return CustomAppConfig{Config: srvCfg, Envoy: envoyCfg}, nil
+}
+
+// writeAppConfig writes the app config to app.toml with the procyon template.
+func writeAppConfig(path string, cfg CustomAppConfig) {
+	template, _ := initAppConfig()
+	serverconfig.SetConfigTemplate(template)
+	serverconfig.WriteConfigFile(path, cfg)
+}
@@ -0,0 +1,635 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	cmtcfg "github.com/cometbft/cometbft/config"
+	cmted25519 "github.com/cometbft/cometbft/crypto/ed25519"
+	"github.com/cometbft/cometbft/privval"
+	cmttypes "github.com/cometbft/cometbft/types"
+	"github.com/cosmos/go-bip39"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"sigs.k8s.io/yaml"
+
+	"cosmossdk.io/math"
+
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/client/flags"
+	"github.com/cosmos/cosmos-sdk/client/tx"
+	cryptocodec "github.com/cosmos/cosmos-sdk/crypto/codec"
+	"github.com/cosmos/cosmos-sdk/crypto/hd"
+	"github.com/cosmos/cosmos-sdk/crypto/keyring"
+	"github.com/cosmos/cosmos-sdk/server"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/cosmos/cosmos-sdk/types/module"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	"github.com/cosmos/cosmos-sdk/x/genutil"
+	genutiltypes "github.com/cosmos/cosmos-sdk/x/genutil/types"
+	govtypes "github.com/cosmos/cosmos-sdk/x/gov/types"
+	govv1 "github.com/cosmos/cosmos-sdk/x/gov/types/v1"
+	minttypes "github.com/cosmos/cosmos-sdk/x/mint/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+
+	"github.com/polygon/envoy"
+
+	"github.com/polygon/procyon/app/params"
+)
+
+const flagForce = "force"
+
+// DevnetSpec describes a devnet, from which devnet init builds the same
+// genesis every time.
+type DevnetSpec struct {
+	ChainID string `json:"chain_id"`
+	// DefaultDenom is the bond denom, the one of the build when empty. The
+	// coin amounts without a denom are in the bond denom.
+	DefaultDenom string `json:"default_denom"`
+	// GenesisTime is fixed, for the genesis to be the same on every run.
+	GenesisTime time.Time `json:"genesis_time"`
+	// VoteExtensionsEnableHeight is the height from which validators attach
+	// envoy lock attestations to their votes, 0 to disable them.
+	VoteExtensionsEnableHeight int64 `json:"vote_extensions_enable_height"`
+
+	Mint *DevnetMint `json:"mint,omitempty"`
+	Gov  *DevnetGov  `json:"gov,omitempty"`
+
+	Accounts []DevnetAccount `json:"accounts"`
+	// Validators are created by gentxs of their accounts. The first one is
+	// the validator of the initialized node.
+	Validators []DevnetValidator `json:"validators"`
+	Envoy      DevnetEnvoy       `json:"envoy"`
+}
+
+// DevnetMint overrides the mint params.
+type DevnetMint struct {
+	InflationRateChange string `json:"inflation_rate_change"`
+	InflationMax        string `json:"inflation_max"`
+	InflationMin        string `json:"inflation_min"`
+	GoalBonded          string `json:"goal_bonded"`
+	BlocksPerYear       uint64 `json:"blocks_per_year"`
+}
+
+// apply overrides the mint params, which must be valid once overridden.
+func (m DevnetMint) apply(params *minttypes.Params) error {
+	decs := []struct {
+		name  string
+		value string
+		dec   *math.LegacyDec
+	}{
+		{"inflation_rate_change", m.InflationRateChange, &params.InflationRateChange},
+		{"inflation_max", m.InflationMax, &params.InflationMax},
+		{"inflation_min", m.InflationMin, &params.InflationMin},
+		{"goal_bonded", m.GoalBonded, &params.GoalBonded},
+	}
+	for _, d := range decs {
+		dec, err := math.LegacyNewDecFromStr(d.value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", d.name, err)
+		}
+		*d.dec = dec
+	}
+	params.BlocksPerYear = m.BlocksPerYear
+
+	return params.Validate()
+}
+
+// DevnetGov overrides the gov voting periods.
+type DevnetGov struct {
+	VotingPeriod          string `json:"voting_period"`
+	ExpeditedVotingPeriod string `json:"expedited_voting_period"`
+}
+
+// DevnetAccount is a genesis account, added to the keyring under its name.
+type DevnetAccount struct {
+	Name     string `json:"name"`
+	Mnemonic string `json:"mnemonic"`
+	Coins    string `json:"coins"`
+}
+
+// DevnetValidator is a genesis validator operated by one of the accounts.
+type DevnetValidator struct {
+	Account string `json:"account"`
+	Moniker string `json:"moniker"`
+	// ConsensusMnemonic derives the consensus key of the validator.
+	ConsensusMnemonic string `json:"consensus_mnemonic"`
+	SelfDelegation    string `json:"self_delegation"`
+}
+
+// DevnetEnvoy is the envoy genesis.
+type DevnetEnvoy struct {
+	Locks []DevnetLock `json:"locks"`
+}
+
+// DevnetLock is a genesis envoy lock, held by an account name or address.
+type DevnetLock struct {
+	Name      string `json:"name"`
+	Holder    string `json:"holder"`
+	AtBlock   uint64 `json:"at_block"`
+	NumBlocks uint64 `json:"num_blocks"`
+}
+
+// LoadDevnetSpec reads and validates a devnet spec file.
+func LoadDevnetSpec(path string) (DevnetSpec, error) {
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		return DevnetSpec{}, err
+	}
+
+	var spec DevnetSpec
+	if err := yaml.UnmarshalStrict(bz, &spec); err != nil {
+		return DevnetSpec{}, fmt.Errorf("failed to decode devnet spec %s: %w", path, err)
+	}
+
+	return spec, spec.Validate()
+}
+
+// BondDenom returns the bond denom of the devnet.
+func (s DevnetSpec) BondDenom() string {
+	if s.DefaultDenom != "" {
+		return s.DefaultDenom
+	}
+	return params.DefaultBondDenom
+}
+
+// parseDevnetCoins parses the coins of a spec, an amount without a denom
+// being in the bond denom.
+func parseDevnetCoins(coins, bondDenom string) (sdk.Coins, error) {
+	if _, ok := math.NewIntFromString(coins); ok {
+		coins += bondDenom
+	}
+	return sdk.ParseCoinsNormalized(coins)
+}
+
+// parseDevnetCoin parses a coin of a spec like parseDevnetCoins.
+func parseDevnetCoin(coin, bondDenom string) (sdk.Coin, error) {
+	if _, ok := math.NewIntFromString(coin); ok {
+		coin += bondDenom
+	}
+	return sdk.ParseCoinNormalized(coin)
+}
+
+// Validate checks the spec, except for what the genesis validation catches.
+func (s DevnetSpec) Validate() error {
+	if s.ChainID == "" {
+		return errors.New("chain_id is required")
+	}
+	if s.GenesisTime.IsZero() {
+		return errors.New("genesis_time is required for the genesis to be the same on every run")
+	}
+	if len(s.Validators) == 0 {
+		return errors.New("at least one validator is required")
+	}
+
+	accounts := make(map[string]bool, len(s.Accounts))
+	for _, account := range s.Accounts {
+		if account.Name == "" {
+			return errors.New("account name is required")
+		}
+		if accounts[account.Name] {
+			return fmt.Errorf("duplicate account %s", account.Name)
+		}
+		accounts[account.Name] = true
+
+		if !bip39.IsMnemonicValid(account.Mnemonic) {
+			return fmt.Errorf("invalid mnemonic of account %s", account.Name)
+		}
+		if _, err := parseDevnetCoins(account.Coins, s.BondDenom()); err != nil {
+			return fmt.Errorf("invalid coins of account %s: %w", account.Name, err)
+		}
+	}
+
+	validators := make(map[string]bool, len(s.Validators))
+	for _, val := range s.Validators {
+		if !accounts[val.Account] {
+			return fmt.Errorf("validator account %s is not a genesis account", val.Account)
+		}
+		if validators[val.Account] {
+			return fmt.Errorf("duplicate validator %s", val.Account)
+		}
+		validators[val.Account] = true
+
+		if !bip39.IsMnemonicValid(val.ConsensusMnemonic) {
+			return fmt.Errorf("invalid consensus mnemonic of validator %s", val.Account)
+		}
+		if _, err := parseDevnetCoin(val.SelfDelegation, s.BondDenom()); err != nil {
+			return fmt.Errorf("invalid self delegation of validator %s: %w", val.Account, err)
+		}
+	}
+
+	if s.Mint != nil {
+		params := minttypes.DefaultParams()
+		if err := s.Mint.apply(&params); err != nil {
+			return fmt.Errorf("invalid mint params: %w", err)
+		}
+	}
+
+	locks := make(map[string]bool, len(s.Envoy.Locks))
+	for _, lock := range s.Envoy.Locks {
+		if lock.Name == "" {
+			return errors.New("lock name is required")
+		}
+		if locks[lock.Name] {
+			return fmt.Errorf("duplicate lock %s", lock.Name)
+		}
+		locks[lock.Name] = true
+
+		if _, err := sdk.AccAddressFromBech32(lock.Holder); err != nil && !accounts[lock.Holder] {
+			return fmt.Errorf("holder %s of lock %s is neither an address nor a genesis account", lock.Holder, lock.Name)
+		}
+	}
+
+	return nil
+}
+
+// devnetCmd returns the devnet command, with the subcommand initializing a
+// node home from a devnet spec.
+func devnetCmd(mbm module.BasicManager) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                        "devnet",
+		Short:                      "subcommands for local devnets described by a spec",
+		DisableFlagParsing:         true,
+		SuggestionsMinimumDistance: 2,
+		RunE:                       client.ValidateCmd,
+	}
+
+	cmd.AddCommand(devnetInitCmd(mbm))
+
+	return cmd
+}
+
+func devnetInitCmd(mbm module.BasicManager) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "init [spec-file]",
+		Short: "Initialize the node home of a devnet from a YAML spec",
+		Long: `Initialize the node home of a devnet from a YAML spec holding the chain ID,
+the accounts with their mnemonics and balances, the validators and the envoy genesis locks.
+
+The same spec always gives the same genesis, byte for byte, and the same keys. Running
+it again on a home initialized from the same spec changes nothing; a home initialized
+otherwise is only deleted and initialized again with --force.
+
+Example:
+	procyon devnet init scripts/devnet.yaml
+	`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clientCtx, err := client.GetClientQueryContext(cmd)
+			if err != nil {
+				return err
+			}
+
+			serverCtx := server.GetServerContextFromCmd(cmd)
+			config := serverCtx.Config
+			keyringBackend, _ := cmd.Flags().GetString(flags.FlagKeyringBackend)
+			force, _ := cmd.Flags().GetBool(flagForce)
+
+			spec, err := LoadDevnetSpec(args[0])
+			if err != nil {
+				return err
+			}
+
+			genesis, err := devnetGenesis(clientCtx, mbm, spec)
+			if err != nil {
+				return err
+			}
+
+			existing, err := os.ReadFile(config.GenesisFile())
+			switch {
+			case errors.Is(err, os.ErrNotExist):
+			case err != nil:
+				return err
+			case bytes.Equal(existing, genesis):
+				cmd.PrintErrf("%s is already initialized from this spec\n", config.RootDir)
+				return initDevnetHome(cmd, clientCtx, serverCtx.Viper, config, spec, keyringBackend, nil)
+			case !force:
+				return fmt.Errorf("%s is initialized with another genesis, use --%s to delete it", config.RootDir, flagForce)
+			default:
+				if err := os.RemoveAll(config.RootDir); err != nil {
+					return err
+				}
+			}
+
+			if err := initDevnetHome(cmd, clientCtx, serverCtx.Viper, config, spec, keyringBackend, genesis); err != nil {
+				return err
+			}
+
+			cmd.PrintErrf("initialized %s for chain %s\n", config.RootDir, spec.ChainID)
+			return nil
+		},
+	}
+
+	cmd.Flags().Bool(flagForce, false, "Delete a home initialized with another genesis")
+	cmd.Flags().String(flags.FlagKeyringBackend, keyring.BackendTest, "Select keyring's backend (os|file|test)")
+
+	return cmd
+}
+
+// devnetGenesis builds the genesis file of the spec, with gentxs signed on an
+// in-memory keyring.
+func devnetGenesis(clientCtx client.Context, mbm module.BasicManager, spec DevnetSpec) ([]byte, error) {
+	cdc := clientCtx.Codec
+
+	// as with init --default-denom, the default genesis of the modules uses it
+	sdk.DefaultBondDenom = spec.BondDenom()
+	appGenState := mbm.DefaultGenesis(cdc)
+
+	kb := keyring.NewInMemory(cdc)
+	addrs, err := addDevnetAccounts(kb, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	/* Handle auth and bank state. */
+
+	var (
+		genAccounts []authtypes.GenesisAccount
+		balances    []banktypes.Balance
+	)
+	for _, account := range spec.Accounts {
+		coins, err := parseDevnetCoins(account.Coins, spec.BondDenom())
+		if err != nil {
+			return nil, err
+		}
+
+		addr := addrs[account.Name]
+		genAccounts = append(genAccounts, authtypes.NewBaseAccount(addr, nil, 0, 0))
+		balances = append(balances, banktypes.Balance{Address: addr.String(), Coins: coins})
+	}
+
+	var authGenState authtypes.GenesisState
+	cdc.MustUnmarshalJSON(appGenState[authtypes.ModuleName], &authGenState)
+	if authGenState.Accounts, err = authtypes.PackAccounts(genAccounts); err != nil {
+		return nil, err
+	}
+	appGenState[authtypes.ModuleName] = cdc.MustMarshalJSON(&authGenState)
+
+	var bankGenState banktypes.GenesisState
+	cdc.MustUnmarshalJSON(appGenState[banktypes.ModuleName], &bankGenState)
+	bankGenState.Balances = banktypes.SanitizeGenesisBalances(balances)
+	for _, bal := range bankGenState.Balances {
+		bankGenState.Supply = bankGenState.Supply.Add(bal.Coins...)
+	}
+	appGenState[banktypes.ModuleName] = cdc.MustMarshalJSON(&bankGenState)
+
+	/* Handle mint and gov params. */
+
+	if spec.Mint != nil {
+		var mintGenState minttypes.GenesisState
+		cdc.MustUnmarshalJSON(appGenState[minttypes.ModuleName], &mintGenState)
+		if err := spec.Mint.apply(&mintGenState.Params); err != nil {
+			return nil, fmt.Errorf("invalid mint params: %w", err)
+		}
+		appGenState[minttypes.ModuleName] = cdc.MustMarshalJSON(&mintGenState)
+	}
+
+	if spec.Gov != nil {
+		votingPeriod, err := time.ParseDuration(spec.Gov.VotingPeriod)
+		if err != nil {
+			return nil, fmt.Errorf("invalid voting period: %w", err)
+		}
+		expeditedVotingPeriod, err := time.ParseDuration(spec.Gov.ExpeditedVotingPeriod)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expedited voting period: %w", err)
+		}
+
+		var govGenState govv1.GenesisState
+		cdc.MustUnmarshalJSON(appGenState[govtypes.ModuleName], &govGenState)
+		govGenState.Params.VotingPeriod = &votingPeriod
+		govGenState.Params.ExpeditedVotingPeriod = &expeditedVotingPeriod
+		appGenState[govtypes.ModuleName] = cdc.MustMarshalJSON(&govGenState)
+	}
+
+	/* Handle envoy state. */
+
+	var envoyGenState envoy.GenesisState
+	cdc.MustUnmarshalJSON(appGenState[envoy.ModuleName], &envoyGenState)
+	for _, lock := range spec.Envoy.Locks {
+		holder := lock.Holder
+		if addr, ok := addrs[holder]; ok {
+			holder = addr.String()
+		}
+
+		envoyGenState.Locks = append(envoyGenState.Locks, envoy.Lock{
+			Name:      lock.Name,
+			Envoy:     holder,
+			AtBlock:   lock.AtBlock,
+			NumBlocks: lock.NumBlocks,
+		})
+	}
+	appGenState[envoy.ModuleName] = cdc.MustMarshalJSON(&envoyGenState)
+
+	/* Handle genesis validators. */
+
+	genTxs := make([]sdk.Tx, 0, len(spec.Validators))
+	for _, val := range spec.Validators {
+		genTx, err := devnetGenTx(clientCtx, kb, spec.ChainID, spec.BondDenom(), addrs[val.Account], val)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gentx of validator %s: %w", val.Account, err)
+		}
+		genTxs = append(genTxs, genTx)
+	}
+
+	appGenState, err = genutil.SetGenTxsInAppGenesisState(cdc, clientCtx.TxConfig.TxJSONEncoder(), appGenState, genTxs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := mbm.ValidateGenesis(cdc, clientCtx.TxConfig, appGenState); err != nil {
+		return nil, fmt.Errorf("invalid genesis: %w", err)
+	}
+
+	appState, err := json.MarshalIndent(appGenState, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	consensusParams := cmttypes.DefaultConsensusParams()
+	consensusParams.ABCI.VoteExtensionsEnableHeight = spec.VoteExtensionsEnableHeight
+
+	appGenesis := genutiltypes.NewAppGenesisWithVersion(spec.ChainID, appState)
+	appGenesis.GenesisTime = spec.GenesisTime.UTC()
+	appGenesis.InitialHeight = 1
+	// NewConsensusGenesis would drop the ABCI params, enabling vote extensions
+	appGenesis.Consensus = &genutiltypes.ConsensusGenesis{Params: consensusParams}
+	if err := appGenesis.ValidateAndComplete(); err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(appGenesis, "", "  ")
+}
+
+// addDevnetAccounts adds the accounts of the spec to the keyring, and returns
+// their addresses by name. Accounts already in the keyring must match.
+func addDevnetAccounts(kb keyring.Keyring, spec DevnetSpec) (map[string]sdk.AccAddress, error) {
+	addrs := make(map[string]sdk.AccAddress, len(spec.Accounts))
+	for _, account := range spec.Accounts {
+		record, err := kb.Key(account.Name)
+		if errors.Is(err, sdkerrors.ErrKeyNotFound) {
+			record, err = kb.NewAccount(account.Name, account.Mnemonic, "", sdk.GetConfig().GetFullBIP44Path(), hd.Secp256k1)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to add account %s: %w", account.Name, err)
+		}
+
+		addr, err := record.GetAddress()
+		if err != nil {
+			return nil, err
+		}
+
+		// a key of another mnemonic may already have the name
+		if pubKey, err := record.GetPubKey(); err != nil || !bytes.Equal(devnetAccountAddress(account), pubKey.Address()) {
+			return nil, fmt.Errorf("key %s is already in the keyring for another mnemonic", account.Name)
+		}
+
+		addrs[account.Name] = addr
+	}
+
+	return addrs, nil
+}
+
+// devnetAccountAddress derives the address of an account from its mnemonic.
+func devnetAccountAddress(account DevnetAccount) sdk.AccAddress {
+	derivedPriv, err := hd.Secp256k1.Derive()(account.Mnemonic, "", sdk.GetConfig().GetFullBIP44Path())
+	if err != nil {
+		return nil
+	}
+
+	return sdk.AccAddress(hd.Secp256k1.Generate()(derivedPriv).PubKey().Address())
+}
+
+// devnetGenTx returns the signed gentx creating a validator. The memo is left
+// empty, as the node ID is not part of the spec.
+func devnetGenTx(
+	clientCtx client.Context,
+	kb keyring.Keyring,
+	chainID, bondDenom string,
+	operator sdk.AccAddress,
+	val DevnetValidator,
+) (sdk.Tx, error) {
+	pubKey, err := cryptocodec.FromCmtPubKeyInterface(cmted25519.GenPrivKeyFromSecret([]byte(val.ConsensusMnemonic)).PubKey())
+	if err != nil {
+		return nil, err
+	}
+
+	selfDelegation, err := parseDevnetCoin(val.SelfDelegation, bondDenom)
+	if err != nil {
+		return nil, err
+	}
+
+	valStr, err := clientCtx.TxConfig.SigningContext().ValidatorAddressCodec().BytesToString(sdk.ValAddress(operator))
+	if err != nil {
+		return nil, err
+	}
+
+	moniker := val.Moniker
+	if moniker == "" {
+		moniker = val.Account
+	}
+
+	createValMsg, err := stakingtypes.NewMsgCreateValidator(
+		valStr,
+		pubKey,
+		selfDelegation,
+		stakingtypes.NewDescription(moniker, "", "", "", ""),
+		stakingtypes.NewCommissionRates(math.LegacyNewDecWithPrec(1, 1), math.LegacyNewDecWithPrec(2, 1), math.LegacyNewDecWithPrec(1, 2)),
+		math.OneInt(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	txBuilder := clientCtx.TxConfig.NewTxBuilder()
+	if err := txBuilder.SetMsgs(createValMsg); err != nil {
+		return nil, err
+	}
+
+	txFactory := tx.Factory{}.
+		WithChainID(chainID).
+		WithKeybase(kb).
+		WithTxConfig(clientCtx.TxConfig)
+
+	if err := tx.Sign(clientCtx.CmdContext, txFactory, val.Account, txBuilder, true); err != nil {
+		return nil, err
+	}
+
+	return txBuilder.GetTx(), nil
+}
+
+// initDevnetHome writes the keys and the validator key of the node to the node
+// home, then the config files and the genesis file unless it is nil.
+func initDevnetHome(
+	cmd *cobra.Command,
+	clientCtx client.Context,
+	v *viper.Viper,
+	config *cmtcfg.Config,
+	spec DevnetSpec,
+	keyringBackend string,
+	genesis []byte,
+) error {
+	if err := os.MkdirAll(filepath.Join(config.RootDir, "config"), nodeDirPerm); err != nil {
+		return err
+	}
+
+	kb, err := keyring.New(sdk.KeyringServiceName(), keyringBackend, config.RootDir, cmd.InOrStdin(), clientCtx.Codec)
+	if err != nil {
+		return err
+	}
+
+	if _, err := addDevnetAccounts(kb, spec); err != nil {
+		return err
+	}
+
+	// the node runs the first validator, whose sign state is kept when its key
+	// is already there
+	nodeVal := spec.Validators[0]
+	pubKey := cmted25519.GenPrivKeyFromSecret([]byte(nodeVal.ConsensusMnemonic)).PubKey()
+	if _, err := os.Stat(config.PrivValidatorKeyFile()); err == nil {
+		filePV := privval.LoadFilePVEmptyState(config.PrivValidatorKeyFile(), config.PrivValidatorStateFile())
+		if !filePV.Key.PubKey.Equals(pubKey) {
+			return fmt.Errorf("the priv validator key of %s is not the key of validator %s", config.RootDir, nodeVal.Account)
+		}
+	} else if _, _, err := genutil.InitializeNodeValidatorFilesFromMnemonic(config, nodeVal.ConsensusMnemonic); err != nil {
+		return err
+	}
+
+	// the config files are left as they are on a home already initialized
+	if genesis == nil {
+		return nil
+	}
+
+	config.Moniker = nodeVal.Moniker
+	if config.Moniker == "" {
+		config.Moniker = nodeVal.Account
+	}
+	cmtcfg.WriteConfigFile(filepath.Join(config.RootDir, "config", "config.toml"), config)
+
+	appConfig, err := readAppConfig(v)
+	if err != nil {
+		return err
+	}
+	writeAppConfig(filepath.Join(config.RootDir, "config", "app.toml"), appConfig)
+
+	// point the client at the devnet
+	clientConfig := viper.New()
+	clientConfigFile := filepath.Join(config.RootDir, "config", "client.toml")
+	clientConfig.SetConfigFile(clientConfigFile)
+	if err := clientConfig.ReadInConfig(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	clientConfig.Set(flags.FlagChainID, spec.ChainID)
+	clientConfig.Set(flags.FlagKeyringBackend, keyringBackend)
+	if err := clientConfig.WriteConfigAs(clientConfigFile); err != nil {
+		return err
+	}
+
+	return os.WriteFile(config.GenesisFile(), genesis, 0o644)
+}
@@ -0,0 +1,103 @@
+package cmd_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	genutiltypes "github.com/cosmos/cosmos-sdk/x/genutil/types"
+
+	"github.com/polygon/procyon/cmd/procyon/cmd"
+)
+
+// devnetSpec is the spec of the devnet initialized by make init.
+var devnetSpec = filepath.Join("..", "..", "..", "scripts", "devnet.yaml")
+
+func TestDevnetInit(t *testing.T) {
+	home, otherHome := t.TempDir(), t.TempDir()
+	genesisFile := filepath.Join(home, "config", "genesis.json")
+
+	cli(t, "devnet", "init", devnetSpec, "--home", home)
+	cli(t, "devnet", "init", devnetSpec, "--home", otherHome)
+
+	// the same spec gives the same genesis, byte for byte
+	genesis, err := os.ReadFile(genesisFile)
+	require.NoError(t, err)
+	otherGenesis, err := os.ReadFile(filepath.Join(otherHome, "config", "genesis.json"))
+	require.NoError(t, err)
+	require.Equal(t, genesis, otherGenesis)
+
+	appGenesis, err := genutiltypes.AppGenesisFromFile(genesisFile)
+	require.NoError(t, err)
+	require.Equal(t, "demo", appGenesis.ChainID)
+	require.Equal(t, int64(1), appGenesis.Consensus.Params.ABCI.VoteExtensionsEnableHeight)
+
+	// running it again on the home changes nothing
+	_, stderr, err := execute("devnet", "init", devnetSpec, "--home", home)
+	require.NoError(t, err)
+	require.Contains(t, string(stderr), "already initialized")
+
+	regenesis, err := os.ReadFile(genesisFile)
+	require.NoError(t, err)
+	require.Equal(t, genesis, regenesis)
+
+	// a home of another genesis is only initialized again with --force
+	spec, err := os.ReadFile(devnetSpec)
+	require.NoError(t, err)
+	otherSpec := filepath.Join(t.TempDir(), "devnet.yaml")
+	require.NoError(t, os.WriteFile(otherSpec, []byte(strings.Replace(string(spec), "chain_id: demo", "chain_id: other", 1)), 0o600))
+
+	_, _, err = execute("devnet", "init", otherSpec, "--home", home)
+	require.ErrorContains(t, err, "--force")
+
+	regenesis, err = os.ReadFile(genesisFile)
+	require.NoError(t, err)
+	require.Equal(t, genesis, regenesis)
+
+	marker := filepath.Join(home, "config", "marker")
+	require.NoError(t, os.WriteFile(marker, nil, 0o600))
+
+	cli(t, "devnet", "init", otherSpec, "--home", home, "--force")
+
+	regenesis, err = os.ReadFile(genesisFile)
+	require.NoError(t, err)
+	require.NotEqual(t, genesis, regenesis)
+	require.Contains(t, string(regenesis), `"chain_id": "other"`)
+
+	// the home was deleted first
+	require.NoFileExists(t, marker)
+}
+
+func TestLoadDevnetSpecMint(t *testing.T) {
+	spec, err := os.ReadFile(devnetSpec)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		err  string
+	}{
+		{name: "typo", old: `inflation_max: "0.200000000000000000"`, new: `inflation_max: "0.2O"`, err: "inflation_max"},
+		{name: "out of range", old: `goal_bonded: "0.670000000000000000"`, new: `goal_bonded: "1.5"`, err: "goal bonded"},
+		{name: "min above max", old: `inflation_min: "0.070000000000000000"`, new: `inflation_min: "0.3"`, err: "max inflation"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Contains(t, string(spec), tc.old)
+			specFile := filepath.Join(t.TempDir(), "devnet.yaml")
+			require.NoError(t, os.WriteFile(specFile, []byte(strings.Replace(string(spec), tc.old, tc.new, 1)), 0o600))
+
+			_, err := cmd.LoadDevnetSpec(specFile)
+			require.ErrorContains(t, err, "invalid mint params")
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+
+	_, err = cmd.LoadDevnetSpec(devnetSpec)
+	require.NoError(t, err)
+}
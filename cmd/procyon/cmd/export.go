@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	cmttypes "github.com/cometbft/cometbft/types"
+	dbm "github.com/cosmos/cosmos-db"
+	"github.com/spf13/cobra"
+
+	"github.com/cosmos/cosmos-sdk/client/flags"
+	"github.com/cosmos/cosmos-sdk/server"
+	genutiltypes "github.com/cosmos/cosmos-sdk/x/genutil/types"
+
+	"github.com/polygon/procyon/app"
+	"github.com/polygon/procyon/app/exportdiff"
+)
+
+const (
+	flagDryRun    = "dry-run"
+	flagCanonical = "canonical"
+)
+
+// extendExportCmd adds the flags of the export modes which do not produce a
+// single in-memory genesis to the export command:
+//   - --dry-run runs the zero height genesis preparation without exporting and
+//     reports what it would change along with every failure.
+//   - --genesis-dir streams the genesis of each module to its own file, as
+//     canonical JSON with a manifest of their hashes with --canonical.
+//
+// It also adds the export diff subcommand.
+func extendExportCmd(rootCmd *cobra.Command) {
+	exportCmd, _, err := rootCmd.Find([]string{"export"})
+	if err != nil || exportCmd == rootCmd {
+		return
+	}
+
+	exportCmd.Flags().Bool(flagDryRun, false, "Report the changes of a zero height export and its failures without exporting")
+	exportCmd.Flags().String(app.FlagGenesisDir, "", "Write the genesis of each module to its own file in this directory, along with a genesis.json with an empty app state")
+	exportCmd.Flags().Bool(flagCanonical, false, "Write the module files of --genesis-dir as canonical JSON, with a manifest of their hashes for export diff")
+	exportCmd.AddCommand(exportDiffCmd())
+
+	runE := exportCmd.RunE
+	exportCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		dryRun, _ := cmd.Flags().GetBool(flagDryRun)
+		dir, _ := cmd.Flags().GetString(app.FlagGenesisDir)
+		if !dryRun && dir == "" {
+			return runE(cmd, args)
+		}
+
+		serverCtx := server.GetServerContextFromCmd(cmd)
+		config := serverCtx.Config
+
+		height, _ := cmd.Flags().GetInt64(server.FlagHeight)
+		forZeroHeight, _ := cmd.Flags().GetBool(server.FlagForZeroHeight)
+		jailAllowedAddrs, _ := cmd.Flags().GetStringSlice(server.FlagJailAllowedAddrs)
+		modulesToExport, _ := cmd.Flags().GetStringSlice(server.FlagModulesToExport)
+
+		db, err := dbm.NewDB("application", server.GetAppDBBackend(serverCtx.Viper), filepath.Join(config.RootDir, "data"))
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		miniApp, err := loadExportApp(serverCtx.Logger, db, nil, height, serverCtx.Viper)
+		if err != nil {
+			return fmt.Errorf("error loading application: %w", err)
+		}
+
+		if dryRun {
+			report, prepErr := miniApp.ZeroHeightDryRun(jailAllowedAddrs)
+
+			out, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+			cmd.Println(string(out))
+
+			return prepErr
+		}
+
+		appGenesis, err := genutiltypes.AppGenesisFromFile(config.GenesisFile())
+		if err != nil {
+			return err
+		}
+
+		export := miniApp.ExportAppStateToDir
+		if canonical, _ := cmd.Flags().GetBool(flagCanonical); canonical {
+			export = miniApp.ExportSnapshot
+		}
+
+		exported, err := export(dir, forZeroHeight, jailAllowedAddrs, modulesToExport)
+		if err != nil {
+			return fmt.Errorf("error exporting state: %w", err)
+		}
+
+		appGenesis.AppState = json.RawMessage("{}")
+		appGenesis.InitialHeight = exported.Height
+		// NewConsensusGenesis would drop the ABCI params, enabling vote extensions
+		consensusParams := cmttypes.ConsensusParamsFromProto(exported.ConsensusParams)
+		appGenesis.Consensus = &genutiltypes.ConsensusGenesis{Params: &consensusParams, Validators: exported.Validators}
+
+		return appGenesis.SaveAs(filepath.Join(dir, app.GenesisDocFile))
+	}
+}
+
+// exportDiffCmd returns the command diffing two exports.
+func exportDiffCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff [a] [b]",
+		Short: "Print the per-module, per-key diff of two exports",
+		Long: `Print the per-module, per-key diff of export b from export a.
+
+Each export is a snapshot directory written by export --genesis-dir --canonical,
+a genesis file, or a height of the local application database which is then
+exported to a temporary snapshot. Modules with the same hash are not compared.`,
+		Example: `procyon export diff ./snapshot-100 ./snapshot-200
+procyon export diff 100 200 --output json`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			output, _ := cmd.Flags().GetString(flags.FlagOutput)
+
+			tmpDir, err := os.MkdirTemp("", "procyon-export-diff")
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(tmpDir)
+
+			snapshots := make([]exportdiff.Snapshot, len(args))
+			for i, arg := range args {
+				if snapshots[i], err = openExport(cmd, arg, filepath.Join(tmpDir, strconv.Itoa(i))); err != nil {
+					return fmt.Errorf("failed to open export %s: %w", arg, err)
+				}
+			}
+
+			diffs, err := exportdiff.Diff(snapshots[0], snapshots[1])
+			if err != nil {
+				return err
+			}
+
+			if output == flags.OutputFormatJSON {
+				out, err := json.MarshalIndent(diffs, "", "  ")
+				if err != nil {
+					return err
+				}
+				cmd.Println(string(out))
+				return nil
+			}
+
+			return exportdiff.WriteText(cmd.OutOrStdout(), diffs)
+		},
+	}
+
+	cmd.Flags().StringP(flags.FlagOutput, "o", "text", "Output format (text|json)")
+
+	return cmd
+}
+
+// openExport opens an export given as a path, or as a height of the local
+// application database which it exports to a snapshot in dir.
+func openExport(cmd *cobra.Command, arg, dir string) (exportdiff.Snapshot, error) {
+	if _, err := os.Stat(arg); err == nil {
+		return exportdiff.Open(arg)
+	}
+
+	height, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return exportdiff.Snapshot{}, errors.New("neither an existing path nor a height")
+	}
+
+	serverCtx := server.GetServerContextFromCmd(cmd)
+
+	db, err := dbm.NewDB("application", server.GetAppDBBackend(serverCtx.Viper), filepath.Join(serverCtx.Config.RootDir, "data"))
+	if err != nil {
+		return exportdiff.Snapshot{}, err
+	}
+	defer db.Close()
+
+	miniApp, err := loadExportApp(serverCtx.Logger, db, nil, height, serverCtx.Viper)
+	if err != nil {
+		return exportdiff.Snapshot{}, fmt.Errorf("error loading application: %w", err)
+	}
+
+	if _, err := miniApp.ExportSnapshot(dir, false, nil, nil); err != nil {
+		return exportdiff.Snapshot{}, err
+	}
+
+	return exportdiff.OpenSnapshot(dir)
+}
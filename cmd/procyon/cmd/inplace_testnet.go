@@ -0,0 +1,365 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	abci "github.com/cometbft/cometbft/abci/types"
+	cmtcfg "github.com/cometbft/cometbft/config"
+	cmtjson "github.com/cometbft/cometbft/libs/json"
+	"github.com/cometbft/cometbft/node"
+	pvm "github.com/cometbft/cometbft/privval"
+	cmtstate "github.com/cometbft/cometbft/proto/tendermint/state"
+	cmtproto "github.com/cometbft/cometbft/proto/tendermint/types"
+	sm "github.com/cometbft/cometbft/state"
+	"github.com/cometbft/cometbft/store"
+	cmttypes "github.com/cometbft/cometbft/types"
+	dbm "github.com/cosmos/cosmos-db"
+	"github.com/spf13/cobra"
+
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/client/flags"
+	"github.com/cosmos/cosmos-sdk/server"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	genutiltypes "github.com/cosmos/cosmos-sdk/x/genutil/types"
+
+	"github.com/polygon/procyon/app"
+	"github.com/polygon/procyon/app/params"
+)
+
+const (
+	flagAccountsToFund     = "accounts-to-fund"
+	flagFundCoins          = "fund-coins"
+	flagReassignEnvoyLocks = "reassign-envoy-locks"
+	flagSkipConfirmation   = "skip-confirmation"
+)
+
+// inPlaceTestnetCmd returns a cmd forking the state of the node into a single
+// validator devnet, and starting it.
+func inPlaceTestnetCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "in-place-testnet [newChainID] [operator]",
+		Short: "Fork the state of this node into a single validator devnet and start it",
+		Long: `in-place-testnet rewrites the data directory of this node, e.g. a copy of a
+node of a shared testnet, into a local devnet with a new chain ID, whose only validator
+is this node's priv validator key operated by the given account (address or key name).
+
+The consensus state is rewritten for the new validator set, the given accounts are
+funded, and the envoy locks are optionally reassigned to the operator, so that a bug
+seen on the shared network can be reproduced locally. The node is then started
+in-process until it is interrupted; later runs use "procyon start".
+
+This changes the data directory for good, run it on a copy.
+
+Example:
+	procyon in-place-testnet procyon-devnet mini1... --accounts-to-fund mini1...,mini1... --reassign-envoy-locks
+	`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			serverCtx := server.GetServerContextFromCmd(cmd)
+			cmtCfg := serverCtx.Config
+
+			clientCtx, err := client.GetClientQueryContext(cmd)
+			if err != nil {
+				return err
+			}
+
+			newChainID := args[0]
+			operator, err := resolveAccount(clientCtx, args[1])
+			if err != nil {
+				return fmt.Errorf("invalid operator: %w", err)
+			}
+
+			opts := app.InPlaceTestnetOptions{Operator: operator}
+			opts.ReassignEnvoyLocks, _ = cmd.Flags().GetBool(flagReassignEnvoyLocks)
+
+			accounts, _ := cmd.Flags().GetStringSlice(flagAccountsToFund)
+			for _, account := range accounts {
+				addr, err := resolveAccount(clientCtx, account)
+				if err != nil {
+					return fmt.Errorf("invalid account to fund %s: %w", account, err)
+				}
+				opts.FundAccounts = append(opts.FundAccounts, addr)
+			}
+
+			fundCoins, _ := cmd.Flags().GetString(flagFundCoins)
+			if opts.FundCoins, err = sdk.ParseCoinsNormalized(fundCoins); err != nil {
+				return fmt.Errorf("invalid fund coins: %w", err)
+			}
+
+			if skip, _ := cmd.Flags().GetBool(flagSkipConfirmation); !skip {
+				cmd.Printf("This will rewrite the data directory %s for good. Do you want to continue? (y/n) ", cmtCfg.DBDir())
+				answer, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+				if err != nil {
+					return err
+				}
+				if strings.ToLower(strings.TrimSpace(answer)) != "y" {
+					return errors.New("aborted")
+				}
+			}
+
+			if err := rewriteGenesisChainID(cmtCfg, newChainID); err != nil {
+				return err
+			}
+
+			db, err := dbm.NewDB("application", server.GetAppDBBackend(serverCtx.Viper), filepath.Join(cmtCfg.RootDir, "data"))
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			miniApp, ok := newApp(serverCtx.Logger, db, nil, serverCtx.Viper).(*app.MiniApp)
+			if !ok {
+				return errors.New("app is not a MiniApp")
+			}
+
+			info, err := miniApp.Info(&abci.RequestInfo{})
+			if err != nil {
+				return err
+			}
+
+			privValidator := pvm.LoadOrGenFilePV(cmtCfg.PrivValidatorKeyFile(), cmtCfg.PrivValidatorStateFile())
+			if opts.ValidatorPubKey, err = privValidator.GetPubKey(); err != nil {
+				return err
+			}
+
+			if err := forkConsensusState(cmtCfg, newChainID, privValidator, info.LastBlockHeight, info.LastBlockAppHash); err != nil {
+				return fmt.Errorf("failed to rewrite the consensus state: %w", err)
+			}
+
+			report, err := miniApp.InitForInPlaceTestnet(opts)
+			if err != nil {
+				return fmt.Errorf("failed to rewrite the app state: %w", err)
+			}
+
+			out, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+			cmd.Println(string(out))
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			cmtNode, err := startNode(cmtCfg, miniApp, serverCtx.Logger)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = cmtNode.Stop()
+				cmtNode.Wait()
+			}()
+
+			cmd.Printf("started %s, rpc %s\n", newChainID, cmtCfg.RPC.ListenAddress)
+			<-ctx.Done()
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSlice(flagAccountsToFund, nil, "Comma-separated accounts (addresses or key names) to fund with --fund-coins")
+	cmd.Flags().String(flagFundCoins, fmt.Sprintf("1000000000000%s", params.DefaultBondDenom), "Coins minted to each account to fund")
+	cmd.Flags().Bool(flagReassignEnvoyLocks, false, "Give every envoy lock to the operator of the local validator")
+	cmd.Flags().Bool(flagSkipConfirmation, false, "Skip the confirmation prompt")
+	cmd.Flags().String(flags.FlagKeyringBackend, flags.DefaultKeyringBackend, "Select keyring's backend (os|file|kwallet|pass|test)")
+
+	return cmd
+}
+
+// resolveAccount returns the account of a bech32 address or of a key name.
+func resolveAccount(clientCtx client.Context, account string) (sdk.AccAddress, error) {
+	if addr, err := sdk.AccAddressFromBech32(account); err == nil {
+		return addr, nil
+	}
+
+	record, err := clientCtx.Keyring.Key(account)
+	if err != nil {
+		return nil, err
+	}
+
+	return record.GetAddress()
+}
+
+// rewriteGenesisChainID sets the chain ID of the genesis file, and empties the
+// address book so that the peers of the forked network are not dialed.
+func rewriteGenesisChainID(cmtCfg *cmtcfg.Config, chainID string) error {
+	appGenesis, err := genutiltypes.AppGenesisFromFile(cmtCfg.GenesisFile())
+	if err != nil {
+		return err
+	}
+
+	appGenesis.ChainID = chainID
+	if err := appGenesis.ValidateAndComplete(); err != nil {
+		return err
+	}
+
+	if err := appGenesis.SaveAs(cmtCfg.GenesisFile()); err != nil {
+		return err
+	}
+
+	return os.WriteFile(cmtCfg.P2P.AddrBookFile(), []byte("{}"), 0o600)
+}
+
+// forkConsensusState rewrites the CometBFT state and block stores so that the
+// chain continues from the app height under the new chain ID, with the priv
+// validator as its only validator, which signs the last commit.
+func forkConsensusState(cmtCfg *cmtcfg.Config, chainID string, privValidator *pvm.FilePV, appHeight int64, appHash []byte) error {
+	blockStoreDB, err := cmtcfg.DefaultDBProvider(&cmtcfg.DBContext{ID: "blockstore", Config: cmtCfg})
+	if err != nil {
+		return err
+	}
+	blockStore := store.NewBlockStore(blockStoreDB)
+	defer blockStore.Close()
+
+	stateDB, err := cmtcfg.DefaultDBProvider(&cmtcfg.DBContext{ID: "state", Config: cmtCfg})
+	if err != nil {
+		return err
+	}
+	defer stateDB.Close()
+
+	stateStore := sm.NewStore(stateDB, sm.StoreOptions{
+		DiscardABCIResponses: cmtCfg.Storage.DiscardABCIResponses,
+	})
+
+	state, genDoc, err := node.LoadStateFromDBOrGenesisDocProvider(stateDB, genDocProvider(cmtCfg))
+	if err != nil {
+		return err
+	}
+
+	userPubKey, err := privValidator.GetPubKey()
+	if err != nil {
+		return err
+	}
+	validatorAddress := userPubKey.Address()
+
+	// the app height differs from the block store height depending on how the
+	// node was stopped
+	switch {
+	case appHeight == blockStore.Height():
+		if state.LastBlockHeight != appHeight {
+			// stopped with the halt height flag
+			state.LastBlockHeight = appHeight
+			state.AppHash = appHash
+		} else if err := blockStoreDB.Delete([]byte(fmt.Sprintf("SC:%v", blockStore.Height()+1))); err != nil {
+			// stopped with SIGTERM, the seen commit of the next block is dropped
+			return err
+		}
+	case blockStore.Height() > state.LastBlockHeight:
+		return fmt.Errorf("block %d is saved but not applied, start and stop the node once before forking it", blockStore.Height())
+	}
+
+	state.ChainID = chainID
+
+	// sign the last block with the local validator
+	vote := cmttypes.Vote{
+		Type:             cmtproto.PrecommitType,
+		Height:           state.LastBlockHeight,
+		Round:            0,
+		BlockID:          state.LastBlockID,
+		Timestamp:        time.Now(),
+		ValidatorAddress: validatorAddress,
+		ValidatorIndex:   0,
+		Signature:        []byte{},
+	}
+
+	voteProto := vote.ToProto()
+	if err := privValidator.SignVote(chainID, voteProto); err != nil {
+		return err
+	}
+	vote.Signature = voteProto.Signature
+	vote.Timestamp = voteProto.Timestamp
+
+	commitSig := cmttypes.CommitSig{
+		BlockIDFlag:      cmttypes.BlockIDFlagCommit,
+		ValidatorAddress: validatorAddress,
+		Timestamp:        vote.Timestamp,
+		Signature:        vote.Signature,
+	}
+
+	seenCommit := blockStore.LoadSeenCommit(state.LastBlockHeight)
+	if seenCommit == nil {
+		return fmt.Errorf("no seen commit at height %d", state.LastBlockHeight)
+	}
+	seenCommit.BlockID = state.LastBlockID
+	seenCommit.Round = vote.Round
+	seenCommit.Signatures = []cmttypes.CommitSig{commitSig}
+	if err := blockStore.SaveSeenCommit(state.LastBlockHeight, seenCommit); err != nil {
+		return err
+	}
+
+	// with vote extensions, the next proposal is built from the extended commit,
+	// which holds an empty extension of the local validator
+	if extCommit := blockStore.LoadBlockExtendedCommit(state.LastBlockHeight); extCommit != nil {
+		extCommit.BlockID = state.LastBlockID
+		extCommit.Round = vote.Round
+		extCommit.ExtendedSignatures = []cmttypes.ExtendedCommitSig{{
+			CommitSig:          commitSig,
+			ExtensionSignature: voteProto.ExtensionSignature,
+		}}
+
+		bz, err := extCommit.ToProto().Marshal()
+		if err != nil {
+			return err
+		}
+		if err := blockStoreDB.Set([]byte(fmt.Sprintf("EC:%v", state.LastBlockHeight)), bz); err != nil {
+			return err
+		}
+	}
+
+	// replace all the validator sets with the local validator
+	newVal := &cmttypes.Validator{
+		Address:     validatorAddress,
+		PubKey:      userPubKey,
+		VotingPower: app.InPlaceTestnetPower,
+	}
+	newValSet := &cmttypes.ValidatorSet{
+		Validators: []*cmttypes.Validator{newVal},
+		Proposer:   newVal,
+	}
+
+	state.Validators = newValSet
+	state.LastValidators = newValSet
+	state.NextValidators = newValSet
+	state.LastHeightValidatorsChanged = blockStore.Height()
+
+	if err := stateStore.Save(state); err != nil {
+		return err
+	}
+
+	valSet, err := state.Validators.ToProto()
+	if err != nil {
+		return err
+	}
+
+	buf, err := (&cmtstate.ValidatorsInfo{
+		ValidatorSet:      valSet,
+		LastHeightChanged: state.LastBlockHeight,
+	}).Marshal()
+	if err != nil {
+		return err
+	}
+
+	// the validators of the last, current and next heights
+	for _, height := range []int64{blockStore.Height() - 1, blockStore.Height(), blockStore.Height() + 1} {
+		if err := stateDB.Set([]byte(fmt.Sprintf("validatorsKey:%v", height)), buf); err != nil {
+			return err
+		}
+	}
+
+	// the genesis doc is kept in the state database, with the old chain ID
+	genDoc.ChainID = chainID
+	bz, err := cmtjson.Marshal(genDoc)
+	if err != nil {
+		return err
+	}
+
+	return stateDB.SetSync([]byte("genesisDoc"), bz)
+}
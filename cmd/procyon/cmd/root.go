@@ -19,7 +19,6 @@ import (
 	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
 	"github.com/cosmos/cosmos-sdk/crypto/keyring"
 	"github.com/cosmos/cosmos-sdk/server"
-	serverconfig "github.com/cosmos/cosmos-sdk/server/config"
 	"github.com/cosmos/cosmos-sdk/types/module"
 	"github.com/cosmos/cosmos-sdk/types/tx/signing"
 	"github.com/cosmos/cosmos-sdk/x/auth/tx"
@@ -27,6 +26,7 @@ import (
 	"github.com/cosmos/cosmos-sdk/x/auth/types"
 
 	"github.com/polygon/procyon/app"
+	"github.com/polygon/procyon/app/leases"
 )
 
 // NewRootCmd creates a new root command for procyon. It is called once in the
@@ -57,6 +57,13 @@ func NewRootCmd() *cobra.Command {
 		panic(err)
 	}
 
+	// the leases module is registered by the app, not by the app config
+	actions, err := app.LockableActions()
+	if err != nil {
+		panic(err)
+	}
+	moduleBasicManager[leases.ModuleName] = leases.NewAppModuleBasic(actions)
+
 	rootCmd := &cobra.Command{
 		Use:   "procyon",
 		Short: "procyon - the minimal chain app",
@@ -65,7 +72,8 @@ func NewRootCmd() *cobra.Command {
 			cmd.SetOut(cmd.OutOrStdout())
 			cmd.SetErr(cmd.ErrOrStderr())
 
-			clientCtx = clientCtx.WithCmdContext(cmd.Context())
+			// print to the command outputs, not to the process ones
+			clientCtx = clientCtx.WithCmdContext(cmd.Context()).WithOutput(cmd.OutOrStdout())
 			clientCtx, err := client.ReadPersistentCommandFlags(clientCtx, cmd.Flags())
 			if err != nil {
 				return err
@@ -93,20 +101,15 @@ func NewRootCmd() *cobra.Command {
 				return err
 			}
 
-			if err := client.SetCmdClientContextHandler(clientCtx, cmd); err != nil {
-				return err
-			}
-
-			// overwrite the minimum gas price from the app configuration
-			srvCfg := serverconfig.DefaultConfig()
-			srvCfg.MinGasPrices = "0mini"
+			// app.toml with the envoy section
+			customAppTemplate, customAppConfig := initAppConfig()
 
 			// overwrite the block timeout
 			cmtCfg := cmtcfg.DefaultConfig()
 			cmtCfg.Consensus.TimeoutCommit = 3 * time.Second
 			cmtCfg.LogLevel = "*:error,p2p:info,state:info" // better default logging
 
-			return server.InterceptConfigsPreRunHandler(cmd, serverconfig.DefaultConfigTemplate, srvCfg, cmtCfg)
+			return server.InterceptConfigsPreRunHandler(cmd, customAppTemplate, customAppConfig, cmtCfg)
 		},
 	}
 	initRootCmd(rootCmd, clientCtx.TxConfig, moduleBasicManager)
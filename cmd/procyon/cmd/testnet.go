@@ -0,0 +1,643 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	cmtcfg "github.com/cometbft/cometbft/config"
+	"github.com/cometbft/cometbft/node"
+	"github.com/cometbft/cometbft/p2p"
+	pvm "github.com/cometbft/cometbft/privval"
+	"github.com/cometbft/cometbft/proxy"
+	cmttypes "github.com/cometbft/cometbft/types"
+	cmttime "github.com/cometbft/cometbft/types/time"
+	dbm "github.com/cosmos/cosmos-db"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	"cosmossdk.io/log"
+	"cosmossdk.io/math"
+
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/client/flags"
+	"github.com/cosmos/cosmos-sdk/client/tx"
+	"github.com/cosmos/cosmos-sdk/crypto/hd"
+	"github.com/cosmos/cosmos-sdk/crypto/keyring"
+	cryptotypes "github.com/cosmos/cosmos-sdk/crypto/types"
+	"github.com/cosmos/cosmos-sdk/runtime"
+	"github.com/cosmos/cosmos-sdk/server"
+	servercmtlog "github.com/cosmos/cosmos-sdk/server/log"
+	servertypes "github.com/cosmos/cosmos-sdk/server/types"
+	"github.com/cosmos/cosmos-sdk/testutil"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/module"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	"github.com/cosmos/cosmos-sdk/x/genutil"
+	genutiltypes "github.com/cosmos/cosmos-sdk/x/genutil/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+
+	"github.com/polygon/procyon/app"
+	"github.com/polygon/procyon/app/params"
+)
+
+var (
+	flagNodeDirPrefix              = "node-dir-prefix"
+	flagNumValidators              = "v"
+	flagOutputDir                  = "output-dir"
+	flagNodeDaemonHome             = "node-daemon-home"
+	flagHost                       = "host"
+	flagDefaultDenom               = "default-denom"
+	flagVoteExtensionsEnableHeight = "vote-extensions-enable-height"
+	flagEnableLogging              = "enable-logging"
+)
+
+const (
+	nodeDirPerm = 0o755
+
+	// portStride is the offset between the CometBFT ports of two consecutive
+	// nodes, so that the nodes of a testnet all listen on one host.
+	portStride = 10
+
+	baseP2PPort   = 26656
+	baseRPCPort   = 26657
+	baseABCIPort  = 26658
+	basePprofPort = 6060
+	baseGRPCPort  = 9090
+	baseAPIPort   = 1317
+)
+
+type initArgs struct {
+	algo                       string
+	chainID                    string
+	defaultDenom               string
+	host                       string
+	keyringBackend             string
+	minGasPrices               string
+	nodeDaemonHome             string
+	nodeDirPrefix              string
+	numValidators              int
+	outputDir                  string
+	voteExtensionsEnableHeight int64
+}
+
+type startArgs struct {
+	enableLogging  bool
+	nodeDaemonHome string
+	nodeDirPrefix  string
+	outputDir      string
+}
+
+func addTestnetFlagsToCmd(cmd *cobra.Command) {
+	cmd.Flags().String(flagOutputDir, "./.testnets", "Directory to store initialization data for the testnet")
+	cmd.Flags().String(flagNodeDirPrefix, "node", "Prefix the directory name for each node with (node results in node0, node1, ...)")
+	cmd.Flags().String(flagNodeDaemonHome, "procyon", "Home directory of the node's daemon configuration")
+}
+
+// NewTestnetCmd creates a root testnet command with subcommands to initialize
+// the validator home directories of a multi-validator testnet on one host, and
+// to run them in-process.
+func NewTestnetCmd(mbm module.BasicManager, genBalIterator banktypes.GenesisBalancesIterator) *cobra.Command {
+	testnetCmd := &cobra.Command{
+		Use:                        "testnet",
+		Short:                      "subcommands for starting or configuring local multi-validator testnets",
+		DisableFlagParsing:         true,
+		SuggestionsMinimumDistance: 2,
+		RunE:                       client.ValidateCmd,
+	}
+
+	testnetCmd.AddCommand(testnetInitFilesCmd(mbm, genBalIterator))
+	testnetCmd.AddCommand(testnetStartCmd())
+
+	return testnetCmd
+}
+
+// testnetInitFilesCmd returns a cmd to initialize all files for a testnet on one host
+func testnetInitFilesCmd(mbm module.BasicManager, genBalIterator banktypes.GenesisBalancesIterator) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "init-files",
+		Short: "Initialize the home directories of a multi-validator testnet running on one host",
+		Long: `init-files will setup "v" number of directories and populate each with
+necessary files (private validator, genesis, config, keyring, etc.) for running "v" validator nodes.
+
+Every node gets its own ports, offset by 10 per node from the CometBFT defaults
+(p2p 26656, rpc 26657, ...) and by 1 per node for gRPC and the API, and lists the
+other nodes as persistent peers, so all of them can run side by side on one host,
+either with "procyon start --home <dir>" each or with "procyon testnet start".
+
+Example:
+	procyon testnet init-files --v 4 --output-dir ./.testnets
+	`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			clientCtx, err := client.GetClientQueryContext(cmd)
+			if err != nil {
+				return err
+			}
+
+			serverCtx := server.GetServerContextFromCmd(cmd)
+			config := serverCtx.Config
+
+			args := initArgs{}
+			args.outputDir, _ = cmd.Flags().GetString(flagOutputDir)
+			args.keyringBackend, _ = cmd.Flags().GetString(flags.FlagKeyringBackend)
+			args.chainID, _ = cmd.Flags().GetString(flags.FlagChainID)
+			args.minGasPrices, _ = cmd.Flags().GetString(server.FlagMinGasPrices)
+			args.nodeDirPrefix, _ = cmd.Flags().GetString(flagNodeDirPrefix)
+			args.nodeDaemonHome, _ = cmd.Flags().GetString(flagNodeDaemonHome)
+			args.host, _ = cmd.Flags().GetString(flagHost)
+			args.numValidators, _ = cmd.Flags().GetInt(flagNumValidators)
+			args.algo, _ = cmd.Flags().GetString(flags.FlagKeyType)
+			args.defaultDenom, _ = cmd.Flags().GetString(flagDefaultDenom)
+			args.voteExtensionsEnableHeight, _ = cmd.Flags().GetInt64(flagVoteExtensionsEnableHeight)
+
+			if args.numValidators < 1 {
+				return errors.New("the testnet needs at least one validator")
+			}
+
+			return initTestnetFiles(clientCtx, cmd, config, mbm, genBalIterator, clientCtx.TxConfig.SigningContext().ValidatorAddressCodec(), args)
+		},
+	}
+
+	addTestnetFlagsToCmd(cmd)
+	cmd.Flags().Int(flagNumValidators, 4, "Number of validators to initialize the testnet with")
+	cmd.Flags().String(flags.FlagChainID, "procyon-testnet", "genesis file chain-id")
+	cmd.Flags().String(server.FlagMinGasPrices, params.DefaultMinGasPrices(), "Minimum gas prices to accept for transactions; All fees in a tx must meet this minimum (e.g. 0.01photino,0.001stake)")
+	cmd.Flags().String(flags.FlagKeyType, string(hd.Secp256k1Type), "Key signing algorithm to generate keys for")
+	cmd.Flags().String(flags.FlagKeyringBackend, keyring.BackendTest, "Select keyring's backend (os|file|test)")
+	cmd.Flags().String(flagHost, "127.0.0.1", "Host of the nodes in the persistent peers list")
+	cmd.Flags().String(flagDefaultDenom, params.DefaultBondDenom, "Genesis file default denomination")
+	cmd.Flags().Int64(flagVoteExtensionsEnableHeight, 1, "Height from which validators attach envoy lock attestations to their votes, 0 to disable")
+
+	// support old flags name for backwards compatibility
+	cmd.Flags().SetNormalizeFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "algo" {
+			name = flags.FlagKeyType
+		}
+		return pflag.NormalizedName(name)
+	})
+
+	return cmd
+}
+
+// testnetStartCmd returns a cmd to run the nodes of a testnet in-process
+func testnetStartCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "start",
+		Short: "Run the nodes of a testnet initialized with init-files in-process",
+		Long: `start runs every node of the testnet initialized with init-files in
+the output directory in this process, until it is interrupted. The state of the
+nodes is kept in their home directories, so the testnet can be stopped and started again.
+
+Example:
+	procyon testnet start --output-dir ./.testnets
+	`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			args := startArgs{}
+			args.outputDir, _ = cmd.Flags().GetString(flagOutputDir)
+			args.nodeDirPrefix, _ = cmd.Flags().GetString(flagNodeDirPrefix)
+			args.nodeDaemonHome, _ = cmd.Flags().GetString(flagNodeDaemonHome)
+			args.enableLogging, _ = cmd.Flags().GetBool(flagEnableLogging)
+
+			return startTestnet(cmd, args)
+		},
+	}
+
+	addTestnetFlagsToCmd(cmd)
+	cmd.Flags().Bool(flagEnableLogging, false, "Enable logging of the testnet nodes")
+
+	return cmd
+}
+
+// nodePorts returns the CometBFT p2p, rpc, abci and pprof ports and the gRPC and
+// API ports of the i-th node of a testnet.
+func nodePorts(i int) (p2pPort, rpcPort, abciPort, pprofPort, grpcPort, apiPort int) {
+	return baseP2PPort + i*portStride, baseRPCPort + i*portStride, baseABCIPort + i*portStride,
+		basePprofPort + i, baseGRPCPort + i, baseAPIPort + i
+}
+
+// initTestnetFiles initializes testnet files for a testnet to be run on one host
+func initTestnetFiles(
+	clientCtx client.Context,
+	cmd *cobra.Command,
+	nodeConfig *cmtcfg.Config,
+	mbm module.BasicManager,
+	genBalIterator banktypes.GenesisBalancesIterator,
+	valAddrCodec runtime.ValidatorAddressCodec,
+	args initArgs,
+) error {
+	// as with init --default-denom, the default genesis of the modules uses it
+	sdk.DefaultBondDenom = args.defaultDenom
+
+	nodeIDs := make([]string, args.numValidators)
+	valPubKeys := make([]cryptotypes.PubKey, args.numValidators)
+	nodeConfigs := make([]*cmtcfg.Config, args.numValidators)
+
+	var (
+		genAccounts []authtypes.GenesisAccount
+		genBalances []banktypes.Balance
+		genFiles    []string
+	)
+
+	inBuf := bufio.NewReader(cmd.InOrStdin())
+	// generate private keys, node IDs, and initial transactions
+	for i := 0; i < args.numValidators; i++ {
+		nodeDirName := fmt.Sprintf("%s%d", args.nodeDirPrefix, i)
+		nodeDir := filepath.Join(args.outputDir, nodeDirName, args.nodeDaemonHome)
+		gentxsDir := filepath.Join(args.outputDir, "gentxs")
+		p2pPort, rpcPort, abciPort, pprofPort, grpcPort, apiPort := nodePorts(i)
+
+		config := copyConfig(nodeConfig)
+		config.SetRoot(nodeDir)
+		config.Moniker = nodeDirName
+		config.ProxyApp = fmt.Sprintf("tcp://127.0.0.1:%d", abciPort)
+		config.RPC.ListenAddress = fmt.Sprintf("tcp://127.0.0.1:%d", rpcPort)
+		config.RPC.PprofListenAddress = fmt.Sprintf("localhost:%d", pprofPort)
+		config.P2P.ListenAddress = fmt.Sprintf("tcp://0.0.0.0:%d", p2pPort)
+		config.P2P.AddrBookStrict = false
+		config.P2P.AllowDuplicateIP = true
+		nodeConfigs[i] = config
+
+		if err := os.MkdirAll(filepath.Join(nodeDir, "config"), nodeDirPerm); err != nil {
+			_ = os.RemoveAll(args.outputDir)
+			return err
+		}
+
+		var err error
+		nodeIDs[i], valPubKeys[i], err = genutil.InitializeNodeValidatorFiles(config)
+		if err != nil {
+			_ = os.RemoveAll(args.outputDir)
+			return err
+		}
+
+		memo := fmt.Sprintf("%s@%s:%d", nodeIDs[i], args.host, p2pPort)
+		genFiles = append(genFiles, config.GenesisFile())
+
+		kb, err := keyring.New(sdk.KeyringServiceName(), args.keyringBackend, nodeDir, inBuf, clientCtx.Codec)
+		if err != nil {
+			return err
+		}
+
+		keyringAlgos, _ := kb.SupportedAlgorithms()
+		algo, err := keyring.NewSigningAlgoFromString(args.algo, keyringAlgos)
+		if err != nil {
+			return err
+		}
+
+		addr, secret, err := testutil.GenerateSaveCoinKey(kb, nodeDirName, "", true, algo)
+		if err != nil {
+			_ = os.RemoveAll(args.outputDir)
+			return err
+		}
+
+		info := map[string]string{"secret": secret}
+
+		cliPrint, err := json.Marshal(info)
+		if err != nil {
+			return err
+		}
+
+		// save private key seed words
+		if err := writeFile(fmt.Sprintf("%v.json", "key_seed"), nodeDir, cliPrint); err != nil {
+			return err
+		}
+
+		accStakingTokens := sdk.TokensFromConsensusPower(500, sdk.DefaultPowerReduction)
+		coins := sdk.NewCoins(sdk.NewCoin(sdk.DefaultBondDenom, accStakingTokens))
+
+		addrStr, err := clientCtx.TxConfig.SigningContext().AddressCodec().BytesToString(addr)
+		if err != nil {
+			return err
+		}
+
+		genBalances = append(genBalances, banktypes.Balance{Address: addrStr, Coins: coins})
+		genAccounts = append(genAccounts, authtypes.NewBaseAccount(addr, nil, 0, 0))
+
+		valStr, err := valAddrCodec.BytesToString(sdk.ValAddress(addr))
+		if err != nil {
+			return err
+		}
+		valTokens := sdk.TokensFromConsensusPower(100, sdk.DefaultPowerReduction)
+		createValMsg, err := stakingtypes.NewMsgCreateValidator(
+			valStr,
+			valPubKeys[i],
+			sdk.NewCoin(sdk.DefaultBondDenom, valTokens),
+			stakingtypes.NewDescription(nodeDirName, "", "", "", ""),
+			stakingtypes.NewCommissionRates(math.LegacyNewDecWithPrec(1, 1), math.LegacyNewDecWithPrec(2, 1), math.LegacyNewDecWithPrec(1, 2)),
+			math.OneInt(),
+		)
+		if err != nil {
+			return err
+		}
+
+		txBuilder := clientCtx.TxConfig.NewTxBuilder()
+		if err := txBuilder.SetMsgs(createValMsg); err != nil {
+			return err
+		}
+
+		txBuilder.SetMemo(memo)
+
+		txFactory := tx.Factory{}
+		txFactory = txFactory.
+			WithChainID(args.chainID).
+			WithMemo(memo).
+			WithKeybase(kb).
+			WithTxConfig(clientCtx.TxConfig)
+
+		if err := tx.Sign(cmd.Context(), txFactory, nodeDirName, txBuilder, true); err != nil {
+			return err
+		}
+
+		txBz, err := clientCtx.TxConfig.TxJSONEncoder()(txBuilder.GetTx())
+		if err != nil {
+			return err
+		}
+
+		if err := writeFile(fmt.Sprintf("%v.json", nodeDirName), gentxsDir, txBz); err != nil {
+			return err
+		}
+
+		appConfig := defaultAppConfig()
+		appConfig.MinGasPrices = args.minGasPrices
+		appConfig.API.Enable = true
+		appConfig.API.Address = fmt.Sprintf("tcp://localhost:%d", apiPort)
+		appConfig.GRPC.Address = fmt.Sprintf("localhost:%d", grpcPort)
+		writeAppConfig(filepath.Join(nodeDir, "config", "app.toml"), appConfig)
+	}
+
+	if err := initGenFiles(clientCtx, mbm, args.chainID, genAccounts, genBalances, genFiles, args.numValidators); err != nil {
+		return err
+	}
+
+	err := collectGenFiles(
+		clientCtx, nodeConfigs, args.chainID, nodeIDs, valPubKeys, args.numValidators,
+		args.outputDir, args.voteExtensionsEnableHeight, genBalIterator, valAddrCodec,
+	)
+	if err != nil {
+		return err
+	}
+
+	cmd.PrintErrf("Successfully initialized %d node directories\n", args.numValidators)
+	return nil
+}
+
+// copyConfig returns a copy of the config whose sections changed per node
+// are not shared with the original.
+func copyConfig(cfg *cmtcfg.Config) *cmtcfg.Config {
+	c := *cfg
+	rpc, p2pCfg, mempool, consensus := *cfg.RPC, *cfg.P2P, *cfg.Mempool, *cfg.Consensus
+	c.RPC, c.P2P, c.Mempool, c.Consensus = &rpc, &p2pCfg, &mempool, &consensus
+
+	return &c
+}
+
+func initGenFiles(
+	clientCtx client.Context, mbm module.BasicManager, chainID string,
+	genAccounts []authtypes.GenesisAccount, genBalances []banktypes.Balance,
+	genFiles []string, numValidators int,
+) error {
+	appGenState := mbm.DefaultGenesis(clientCtx.Codec)
+
+	// set the accounts in the genesis state
+	var authGenState authtypes.GenesisState
+	clientCtx.Codec.MustUnmarshalJSON(appGenState[authtypes.ModuleName], &authGenState)
+
+	accounts, err := authtypes.PackAccounts(genAccounts)
+	if err != nil {
+		return err
+	}
+
+	authGenState.Accounts = accounts
+	appGenState[authtypes.ModuleName] = clientCtx.Codec.MustMarshalJSON(&authGenState)
+
+	// set the balances in the genesis state
+	var bankGenState banktypes.GenesisState
+	clientCtx.Codec.MustUnmarshalJSON(appGenState[banktypes.ModuleName], &bankGenState)
+
+	bankGenState.Balances = banktypes.SanitizeGenesisBalances(genBalances)
+	for _, bal := range bankGenState.Balances {
+		bankGenState.Supply = bankGenState.Supply.Add(bal.Coins...)
+	}
+	appGenState[banktypes.ModuleName] = clientCtx.Codec.MustMarshalJSON(&bankGenState)
+
+	appGenStateJSON, err := json.MarshalIndent(appGenState, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	appGenesis := genutiltypes.NewAppGenesisWithVersion(chainID, appGenStateJSON)
+	// generate empty genesis files for each validator and save
+	for i := 0; i < numValidators; i++ {
+		if err := appGenesis.SaveAs(genFiles[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func collectGenFiles(
+	clientCtx client.Context, nodeConfigs []*cmtcfg.Config, chainID string,
+	nodeIDs []string, valPubKeys []cryptotypes.PubKey, numValidators int,
+	outputDir string, voteExtensionsEnableHeight int64,
+	genBalIterator banktypes.GenesisBalancesIterator, valAddrCodec runtime.ValidatorAddressCodec,
+) error {
+	var appState json.RawMessage
+	genTime := cmttime.Now()
+	gentxsDir := filepath.Join(outputDir, "gentxs")
+
+	consensusParams := cmttypes.DefaultConsensusParams()
+	consensusParams.ABCI.VoteExtensionsEnableHeight = voteExtensionsEnableHeight
+
+	for i := 0; i < numValidators; i++ {
+		nodeConfig := nodeConfigs[i]
+
+		nodeID, valPubKey := nodeIDs[i], valPubKeys[i]
+		initCfg := genutiltypes.NewInitConfig(chainID, gentxsDir, nodeID, valPubKey)
+
+		appGenesis, err := genutiltypes.AppGenesisFromFile(nodeConfig.GenesisFile())
+		if err != nil {
+			return err
+		}
+
+		// this also writes the node config, with the other nodes as persistent
+		// peers at the addresses in the memos of their gentxs
+		nodeAppState, err := genutil.GenAppStateFromConfig(clientCtx.Codec, clientCtx.TxConfig, nodeConfig, initCfg, appGenesis, genBalIterator, genutiltypes.DefaultMessageValidator,
+			valAddrCodec)
+		if err != nil {
+			return err
+		}
+
+		if appState == nil {
+			// set the canonical application state (they should not differ)
+			appState = nodeAppState
+		}
+
+		// overwrite each validator's genesis file to have a canonical genesis time
+		appGenesis.AppState = appState
+		appGenesis.GenesisTime = genTime
+		// NewConsensusGenesis would drop the ABCI params, enabling vote extensions
+		appGenesis.Consensus = &genutiltypes.ConsensusGenesis{Params: consensusParams}
+		if err := appGenesis.SaveAs(nodeConfig.GenesisFile()); err != nil {
+			return err
+		}
+
+	}
+
+	return nil
+}
+
+func writeFile(name, dir string, contents []byte) error {
+	file := filepath.Join(dir, name)
+
+	if err := os.MkdirAll(dir, nodeDirPerm); err != nil {
+		return fmt.Errorf("could not create directory %q: %w", dir, err)
+	}
+
+	if err := os.WriteFile(file, contents, 0o600); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// startTestnet runs the nodes of the testnet in the output directory in-process
+func startTestnet(cmd *cobra.Command, args startArgs) error {
+	var homes []string
+	for i := 0; ; i++ {
+		home := filepath.Join(args.outputDir, fmt.Sprintf("%s%d", args.nodeDirPrefix, i), args.nodeDaemonHome)
+		if _, err := os.Stat(filepath.Join(home, "config", "genesis.json")); err != nil {
+			break
+		}
+		homes = append(homes, home)
+	}
+
+	if len(homes) == 0 {
+		return fmt.Errorf("no testnet nodes in %s, run testnet init-files first", args.outputDir)
+	}
+
+	var logger log.Logger
+	if args.enableLogging {
+		logger = log.NewLogger(os.Stdout)
+	} else {
+		logger = log.NewNopLogger()
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var nodes []*node.Node
+	var dbs []dbm.DB
+	defer func() {
+		for _, n := range nodes {
+			_ = n.Stop()
+			n.Wait()
+		}
+		for _, db := range dbs {
+			_ = db.Close()
+		}
+	}()
+
+	for _, home := range homes {
+		n, db, err := startTestnetNode(home, logger)
+		if db != nil {
+			dbs = append(dbs, db)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to start node %s: %w", home, err)
+		}
+		nodes = append(nodes, n)
+
+		cmd.Printf("started %s, rpc %s\n", home, n.Config().RPC.ListenAddress)
+	}
+
+	cmd.Println("press Ctrl+C to stop the testnet")
+	<-ctx.Done()
+
+	return nil
+}
+
+// startTestnetNode starts a CometBFT node with the app of the node home
+// directory, and returns it with the app database.
+func startTestnetNode(home string, logger log.Logger) (*node.Node, dbm.DB, error) {
+	v := viper.New()
+	v.SetConfigFile(filepath.Join(home, "config", "config.toml"))
+	if err := v.ReadInConfig(); err != nil {
+		return nil, nil, err
+	}
+
+	cmtCfg := cmtcfg.DefaultConfig()
+	if err := v.Unmarshal(cmtCfg); err != nil {
+		return nil, nil, err
+	}
+	cmtCfg.SetRoot(home)
+	if err := cmtCfg.ValidateBasic(); err != nil {
+		return nil, nil, err
+	}
+
+	appOpts := viper.New()
+	appOpts.SetConfigFile(filepath.Join(home, "config", "app.toml"))
+	if err := appOpts.ReadInConfig(); err != nil {
+		return nil, nil, err
+	}
+	appOpts.Set(flags.FlagHome, home)
+
+	db, err := dbm.NewDB("application", server.GetAppDBBackend(appOpts), filepath.Join(home, "data"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nodeLogger := logger.With("module", cmtCfg.Moniker)
+	miniApp, err := app.NewMiniApp(nodeLogger, db, nil, true, appOpts, server.DefaultBaseappOptions(appOpts)...)
+	if err != nil {
+		return nil, db, err
+	}
+
+	cmtNode, err := startNode(cmtCfg, miniApp, nodeLogger)
+	return cmtNode, db, err
+}
+
+// startNode starts a CometBFT node running app in-process.
+func startNode(cmtCfg *cmtcfg.Config, app servertypes.Application, logger log.Logger) (*node.Node, error) {
+	nodeKey, err := p2p.LoadOrGenNodeKey(cmtCfg.NodeKeyFile())
+	if err != nil {
+		return nil, err
+	}
+
+	cmtNode, err := node.NewNode(
+		cmtCfg,
+		pvm.LoadOrGenFilePV(cmtCfg.PrivValidatorKeyFile(), cmtCfg.PrivValidatorStateFile()),
+		nodeKey,
+		proxy.NewLocalClientCreator(server.NewCometABCIWrapper(app)),
+		genDocProvider(cmtCfg),
+		cmtcfg.DefaultDBProvider,
+		node.DefaultMetricsProvider(cmtCfg.Instrumentation),
+		servercmtlog.CometLoggerWrapper{Logger: logger},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmtNode.Start(); err != nil {
+		return nil, err
+	}
+
+	return cmtNode, nil
+}
+
+// genDocProvider returns the CometBFT genesis of the app genesis file.
+func genDocProvider(cmtCfg *cmtcfg.Config) node.GenesisDocProvider {
+	return func() (*cmttypes.GenesisDoc, error) {
+		appGenesis, err := genutiltypes.AppGenesisFromFile(cmtCfg.GenesisFile())
+		if err != nil {
+			return nil, err
+		}
+
+		return appGenesis.ToGenesisDoc()
+	}
+}
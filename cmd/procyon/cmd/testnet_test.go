@@ -0,0 +1,83 @@
+package cmd_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cmtcfg "github.com/cometbft/cometbft/config"
+	"github.com/cometbft/cometbft/p2p"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	genutiltypes "github.com/cosmos/cosmos-sdk/x/genutil/types"
+)
+
+func TestTestnetInitFiles(t *testing.T) {
+	outputDir := t.TempDir()
+	cli(t, "testnet", "init-files", "--v", "2", "--output-dir", outputDir, "--vote-extensions-enable-height", "3", "--home", t.TempDir())
+
+	var (
+		genesis []byte
+		nodeIDs []string
+		configs []*cmtcfg.Config
+	)
+	addrs := make(map[string]string)
+	for i := 0; i < 2; i++ {
+		home := filepath.Join(outputDir, fmt.Sprintf("node%d", i), "procyon")
+
+		// every node has the same genesis
+		bz, err := os.ReadFile(filepath.Join(home, "config", "genesis.json"))
+		require.NoError(t, err)
+		if genesis == nil {
+			genesis = bz
+		}
+		require.Equal(t, genesis, bz, "genesis of node%d", i)
+
+		nodeKey, err := p2p.LoadNodeKey(filepath.Join(home, "config", "node_key.json"))
+		require.NoError(t, err)
+		nodeIDs = append(nodeIDs, string(nodeKey.ID()))
+
+		v := viper.New()
+		v.SetConfigFile(filepath.Join(home, "config", "config.toml"))
+		require.NoError(t, v.ReadInConfig())
+		config := cmtcfg.DefaultConfig()
+		require.NoError(t, v.Unmarshal(config))
+		configs = append(configs, config)
+
+		appConfig := viper.New()
+		appConfig.SetConfigFile(filepath.Join(home, "config", "app.toml"))
+		require.NoError(t, appConfig.ReadInConfig())
+
+		// and listens on its own ports
+		for name, addr := range map[string]string{
+			"p2p":   config.P2P.ListenAddress,
+			"rpc":   config.RPC.ListenAddress,
+			"abci":  config.ProxyApp,
+			"pprof": config.RPC.PprofListenAddress,
+			"grpc":  appConfig.GetString("grpc.address"),
+			"api":   appConfig.GetString("api.address"),
+		} {
+			port := addr[strings.LastIndex(addr, ":")+1:]
+			require.NotContains(t, addrs, port, "%s port of node%d", name, i)
+			addrs[port] = name
+		}
+	}
+
+	// the nodes are the persistent peers of each other
+	for i, config := range configs {
+		for j, nodeID := range nodeIDs {
+			if i == j {
+				require.NotContains(t, config.P2P.PersistentPeers, nodeID)
+				continue
+			}
+			require.Contains(t, config.P2P.PersistentPeers, nodeID+"@")
+		}
+	}
+
+	appGenesis, err := genutiltypes.AppGenesisFromFile(filepath.Join(outputDir, "node0", "procyon", "config", "genesis.json"))
+	require.NoError(t, err)
+	require.Equal(t, int64(3), appGenesis.Consensus.Params.ABCI.VoteExtensionsEnableHeight)
+}
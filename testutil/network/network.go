@@ -0,0 +1,175 @@
+// Package network starts networks of in-process procyon validators, each a
+// MiniApp behind a CometBFT node listening on loopback, for integration tests
+// that need real blocks, RPC and gRPC endpoints without the installed binary.
+//
+// It configures the cosmos-sdk test network for MiniApp. Vote extensions are
+// disabled in its genesis, unless enabled with EnableVoteExtensions to
+// exercise the envoy lock attestations.
+package network
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	abci "github.com/cometbft/cometbft/abci/types"
+	cmtproto "github.com/cometbft/cometbft/proto/tendermint/types"
+	cmttypes "github.com/cometbft/cometbft/types"
+	dbm "github.com/cosmos/cosmos-db"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"cosmossdk.io/log"
+	pruningtypes "cosmossdk.io/store/pruning/types"
+
+	"github.com/cosmos/cosmos-sdk/baseapp"
+	"github.com/cosmos/cosmos-sdk/client/flags"
+	serverconfig "github.com/cosmos/cosmos-sdk/server/config"
+	servertypes "github.com/cosmos/cosmos-sdk/server/types"
+	"github.com/cosmos/cosmos-sdk/testutil/network"
+	simtestutil "github.com/cosmos/cosmos-sdk/testutil/sims"
+	moduletestutil "github.com/cosmos/cosmos-sdk/types/module/testutil"
+
+	"github.com/polygon/procyon/app"
+)
+
+type (
+	Network   = network.Network
+	Config    = network.Config
+	Validator = network.Validator
+)
+
+// New starts a network of cfg, or of DefaultConfig when none is given, and
+// stops it at the end of the test. Only one network runs at a time.
+func New(t *testing.T, configs ...Config) *Network {
+	t.Helper()
+
+	cfg := DefaultConfig()
+	if len(configs) > 0 {
+		cfg = configs[0]
+	}
+
+	// the validators write the app.toml of the server config, with the
+	// template the procyon commands may have replaced in the process
+	serverconfig.SetConfigTemplate(serverconfig.DefaultConfigTemplate)
+
+	net, err := network.New(t, t.TempDir(), cfg)
+	require.NoError(t, err)
+
+	t.Cleanup(net.Cleanup)
+	return net
+}
+
+// DefaultConfig returns the config of a network of MiniApp validators, with
+// the default genesis of the app.
+func DefaultConfig() Config {
+	return network.DefaultConfig(NewTestNetworkFixture)
+}
+
+// EnableVoteExtensions returns cfg with vote extensions enabled from height.
+// The genesis of the test network has no consensus params, so the validators
+// set them in InitChain, and return them for CometBFT to use.
+func EnableVoteExtensions(cfg Config, height int64) Config {
+	appCtr := cfg.AppConstructor
+	cfg.AppConstructor = func(val network.ValidatorI) servertypes.Application {
+		return voteExtensionsApp{Application: appCtr(val), height: height}
+	}
+
+	return cfg
+}
+
+// voteExtensionsApp enables vote extensions in the consensus params of the
+// genesis.
+type voteExtensionsApp struct {
+	servertypes.Application
+	height int64
+}
+
+func (a voteExtensionsApp) InitChain(req *abci.RequestInitChain) (*abci.ResponseInitChain, error) {
+	params := cmttypes.DefaultConsensusParams().ToProto()
+	if req.ConsensusParams != nil {
+		params = *req.ConsensusParams
+	}
+	params.Abci = &cmtproto.ABCIParams{VoteExtensionsEnableHeight: a.height}
+	req.ConsensusParams = &params
+
+	resp, err := a.Application.InitChain(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.ConsensusParams = &params
+	return resp, nil
+}
+
+// NewTestNetworkFixture returns the MiniApp constructor of the validators, and
+// the codecs and default genesis of the app.
+func NewTestNetworkFixture() network.TestFixture {
+	dir, err := os.MkdirTemp("", "procyon")
+	if err != nil {
+		panic(fmt.Sprintf("failed creating temporary directory: %v", err))
+	}
+	defer os.RemoveAll(dir)
+
+	tempApp, err := app.NewMiniApp(log.NewNopLogger(), dbm.NewMemDB(), nil, true, simtestutil.NewAppOptionsWithFlagHome(dir))
+	if err != nil {
+		panic(fmt.Sprintf("failed creating app: %v", err))
+	}
+
+	appCtr := func(val network.ValidatorI) servertypes.Application {
+		// the home holds the priv validator key the vote extension handler reads
+		miniApp, err := app.NewMiniApp(
+			val.GetCtx().Logger, dbm.NewMemDB(), nil, true,
+			simtestutil.NewAppOptionsWithFlagHome(val.GetCtx().Config.RootDir),
+			baseapp.SetPruning(pruningtypes.NewPruningOptionsFromString(val.GetAppConfig().Pruning)),
+			baseapp.SetMinGasPrices(val.GetAppConfig().MinGasPrices),
+			baseapp.SetChainID(val.GetCtx().Viper.GetString(flags.FlagChainID)),
+		)
+		if err != nil {
+			panic(fmt.Sprintf("failed creating app: %v", err))
+		}
+
+		return miniApp
+	}
+
+	return network.TestFixture{
+		AppConstructor: appCtr,
+		GenesisState:   tempApp.DefaultGenesis(),
+		EncodingConfig: moduletestutil.TestEncodingConfig{
+			InterfaceRegistry: tempApp.InterfaceRegistry(),
+			Codec:             tempApp.AppCodec(),
+			TxConfig:          tempApp.TxConfig(),
+			Amino:             tempApp.LegacyAmino(),
+		},
+	}
+}
+
+// GRPCConn dials the gRPC server of a validator, and closes the connection at
+// the end of the test.
+func GRPCConn(t *testing.T, val *Validator) *grpc.ClientConn {
+	t.Helper()
+
+	require.True(t, val.AppConfig.GRPC.Enable, "gRPC is disabled on validator %s", val.Moniker)
+
+	conn, err := grpc.Dial(val.AppConfig.GRPC.Address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// WaitForBlocks waits for n more blocks to be committed, and returns the
+// latest height. Each block gets the commit timeout and a few seconds more.
+func WaitForBlocks(t *testing.T, net *Network, n int64) int64 {
+	t.Helper()
+
+	height, err := net.LatestHeight()
+	require.NoError(t, err)
+
+	height, err = net.WaitForHeightWithTimeout(height+n, time.Duration(n)*(net.Config.TimeoutCommit+5*time.Second))
+	require.NoError(t, err)
+
+	return height
+}
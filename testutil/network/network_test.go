@@ -0,0 +1,77 @@
+package network_test
+
+import (
+	"context"
+	"testing"
+
+	abci "github.com/cometbft/cometbft/abci/types"
+	"github.com/stretchr/testify/require"
+
+	cmtservice "github.com/cosmos/cosmos-sdk/client/grpc/cmtservice"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+
+	"github.com/polygon/procyon/app"
+	"github.com/polygon/procyon/app/leases"
+	"github.com/polygon/procyon/testutil/network"
+)
+
+func TestNetwork(t *testing.T) {
+	cfg := network.DefaultConfig()
+	cfg.NumValidators = 2
+
+	net := network.New(t, cfg)
+	height := network.WaitForBlocks(t, net, 2)
+	require.GreaterOrEqual(t, height, int64(3))
+
+	val := net.Validators[0]
+
+	// queries through the RPC client of the client context
+	res, err := banktypes.NewQueryClient(val.ClientCtx).Balance(context.Background(), &banktypes.QueryBalanceRequest{
+		Address: val.Address.String(),
+		Denom:   cfg.BondDenom,
+	})
+	require.NoError(t, err)
+	require.True(t, res.Balance.IsPositive())
+
+	// and through the gRPC server, which sees both validators
+	conn := network.GRPCConn(t, val)
+	vals, err := cmtservice.NewServiceClient(conn).GetLatestValidatorSet(context.Background(), &cmtservice.GetLatestValidatorSetRequest{})
+	require.NoError(t, err)
+	require.Len(t, vals.Validators, 2)
+}
+
+func TestNetworkVoteExtensions(t *testing.T) {
+	cfg := network.EnableVoteExtensions(network.DefaultConfig(), 2)
+	cfg.NumValidators = 2
+
+	net := network.New(t, cfg)
+	height := network.WaitForBlocks(t, net, 6)
+
+	val := net.Validators[0]
+	var completed []string
+	for h := int64(3); h <= height; h++ {
+		block, err := val.RPCClient.Block(context.Background(), &h)
+		require.NoError(t, err)
+
+		// the extended commit of the previous height is injected first
+		require.NotEmpty(t, block.Block.Txs, "height %d", h)
+		var commit abci.ExtendedCommitInfo
+		require.NoError(t, commit.Unmarshal(block.Block.Txs[0]), "height %d", h)
+
+		attestations, err := app.DecodeLockAttestations(commit)
+		require.NoError(t, err)
+		require.NotEmpty(t, attestations, "height %d", h)
+		for _, attestation := range attestations {
+			require.Equal(t, h-1, attestation.Height)
+			completed = append(completed, attestation.Completed...)
+		}
+	}
+
+	// the holder of the checkpoint lock runs its job
+	require.Contains(t, completed, "checkpoint")
+
+	// and misses no block
+	bz, _, err := val.ClientCtx.QueryStore(append(leases.MissesPrefix.Bytes(), "checkpoint"...), leases.StoreKey)
+	require.NoError(t, err)
+	require.Empty(t, bz)
+}